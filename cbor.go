@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/romanzac/json-mp/mp"
+)
+
+// Formats of --from and --to
+const (
+	formatJSON    = "json"
+	formatMsgpack = "msgpack"
+	formatCBOR    = "cbor"
+	formatCSV     = "csv"
+)
+
+// runTranscode converts between the formats of --from and --to. JSON and
+// MessagePack are converted like without the flags, CBOR, CSV and MessagePack
+// directly without the shape, unless --csv-shape is given.
+func runTranscode() {
+
+	if fromFormat == "" || toFormat == "" {
+		fail(exitUsage, "--from and --to must be given together")
+	}
+	for _, f := range []string{fromFormat, toFormat} {
+		if f != formatJSON && f != formatMsgpack && f != formatCBOR && f != formatCSV {
+			fail(exitUsage, "Unknown format: %s, use json, msgpack, cbor or csv", f)
+		}
+	}
+	if fromFormat == toFormat {
+		fail(exitUsage, "--from and --to must differ")
+	}
+	if fromFormat != formatMsgpack && toFormat != formatMsgpack {
+		fail(exitUsage, "Converting %s to %s goes through msgpack, e.g. --from %s --to msgpack | json-mp --from msgpack --to %s",
+			fromFormat, toFormat, fromFormat, toFormat)
+	}
+
+	if fromFormat == formatJSON || toFormat == formatJSON {
+		isDecoding = toFormat == formatJSON
+		fromFormat, toFormat = "", ""
+		runJsonMp(nil, nil)
+		return
+	}
+
+	if fromFormat == formatCSV || toFormat == formatCSV {
+		runCSV()
+		return
+	}
+
+	opts, err := cborOptions()
+	if err != nil {
+		fail(exitUsage, "%v", err)
+	}
+	dataIn, err := readInput(inputFile)
+	if err != nil {
+		fail(exitIO, "Error during reading the input file: %v", err)
+	}
+
+	var dataOut []byte
+	if fromFormat == formatCBOR {
+		dataOut, err = mp.FromCBOR(dataIn, opts)
+	} else {
+		dataOut, err = mp.ToCBOR(dataIn, opts)
+	}
+	if err != nil {
+		fail(exitData, "Error during converting %s to %s: %v", fromFormat, toFormat, err)
+	}
+	if err = writeOutput(outputFile, dataOut); err != nil {
+		fail(exitIO, "Error during writing the output file: %v", err)
+	}
+}
+
+// cborOptions returns the tag mapping of the --tag flags, e.g. 37=1
+func cborOptions() (mp.CBOROptions, error) {
+	opts := mp.CBOROptions{Tags: map[uint64]int8{}}
+	for _, t := range cborTags {
+		number, typ, ok := strings.Cut(t, "=")
+		n, err := strconv.ParseUint(number, 10, 64)
+		if !ok || err != nil {
+			return opts, fmt.Errorf("invalid tag mapping %q, use tag=ext e.g. 37=1", t)
+		}
+		e, err := strconv.ParseInt(typ, 10, 8)
+		if err != nil {
+			return opts, fmt.Errorf("invalid ext type in %q: %v", t, err)
+		}
+		opts.Tags[n] = int8(e)
+	}
+	return opts, nil
+}
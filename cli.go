@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// Exit codes, status 1 is the negative result of diff and validate
+const (
+	exitUsage = 2 // invalid flags or arguments
+	exitIO    = 3 // files or streams cannot be read or written
+	exitData  = 4 // input is not valid or cannot be converted
+)
+
+// stdio is the file name standing for stdin or stdout
+const stdio = "-"
+
+// fail prints the message to stderr and exits with code
+func fail(code int, format string, a ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", a...)
+	os.Exit(code)
+}
+
+// exitCode returns exitIO for file system errors and exitData for all others
+func exitCode(err error) int {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return exitIO
+	}
+	return exitData
+}
+
+// openInput opens file name for reading, "-" is stdin
+func openInput(name string) (*os.File, error) {
+	if name == stdio {
+		return os.Stdin, nil
+	}
+	return os.Open(name)
+}
+
+// readInput reads file name, "-" is stdin
+func readInput(name string) ([]byte, error) {
+	if name == stdio {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(name)
+}
+
+// writeOutput writes data to file name, "-" is stdout
+func writeOutput(name string, data []byte) error {
+	if name == stdio {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(name, data, 0666)
+}
+
+// createOutput creates file name for writing, "-" is stdout
+func createOutput(name string) (*os.File, error) {
+	if name == stdio {
+		return os.Stdout, nil
+	}
+	return os.Create(name)
+}
+
+// closeOutput closes f from createOutput and removes it when err is not nil,
+// so a failed conversion leaves no partial file behind
+func closeOutput(f *os.File, err error) error {
+	if f == os.Stdout {
+		return err
+	}
+	cerr := f.Close()
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return cerr
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/romanzac/json-mp/mp"
+	"github.com/spf13/cobra"
+)
+
+var (
+	inputDir, outputDir string
+	convertJobs         int
+	forceConvert        bool
+
+	// ConvertCmd converts many files with a pool of workers
+	ConvertCmd = &cobra.Command{
+		Use:   "convert [pattern]...",
+		Short: "Converts directories and glob patterns of files in parallel",
+		Long: `Converts all .json files of --input-dir and files matching the glob patterns
+(e.g. "logs/*.json") to .mp files in --output-dir, or .mp files to .json with -d.
+The directory structure below --input-dir (or the current directory for patterns)
+is preserved, files outside of it are named by their base name and inputs which
+would share an output are rejected. Outputs newer than their input are skipped
+unless --force is given.`,
+		Run: runConvert,
+	}
+)
+
+func init() {
+	ConvertCmd.Flags().StringVar(&inputDir, "input-dir", "", "directory converted recursively")
+	ConvertCmd.Flags().StringVar(&outputDir, "output-dir", "", "directory the outputs are written to")
+	ConvertCmd.Flags().IntVarP(&convertJobs, "jobs", "j", runtime.NumCPU(), "number of files converted in parallel")
+	ConvertCmd.Flags().BoolVar(&forceConvert, "force", false, "converts files whose output is up to date")
+	ConvertCmd.Flags().BoolVarP(&isDecoding, "decode", "d", false, "decodes MessagePack to JSON format")
+	ConvertCmd.Flags().BoolVarP(&isStreaming, "stream", "s", false, "streams without the shape, in constant memory")
+	ConvertCmd.Flags().BoolVarP(&isPretty, "pretty", "p", false, "indents JSON output")
+	ConvertCmd.MarkFlagRequired("output-dir")
+
+	JsonMpCmd.AddCommand(ConvertCmd)
+}
+
+// conversion is a file to convert and its outcome
+type conversion struct {
+	in, out string
+	skipped bool
+	err     error
+}
+
+func runConvert(cmd *cobra.Command, args []string) {
+
+	if inputDir == "" && len(args) == 0 {
+		fail(exitUsage, "Nothing to convert, give --input-dir or patterns")
+	}
+	if convertJobs < 1 {
+		fail(exitUsage, "Jobs must be at least 1")
+	}
+
+	inExt, outExt := ".json", ".mp"
+	if isDecoding {
+		inExt, outExt = outExt, inExt
+	}
+
+	inputs, err := convertInputs(args, inExt)
+	if err != nil {
+		fail(exitIO, "Error during listing the input files: %v", err)
+	}
+
+	base := inputDir
+	if base == "" {
+		base = "."
+	}
+	work, err := convertOutputs(inputs, base, outputDir, outExt)
+	if err != nil {
+		fail(exitUsage, "%v", err)
+	}
+
+	jobs := make(chan *conversion)
+	var wg sync.WaitGroup
+	for i := 0; i < convertJobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range jobs {
+				c.skipped, c.err = convertFile(c.in, c.out)
+				if c.err != nil {
+					fmt.Fprintf(os.Stderr, "%s: %v\n", c.in, c.err)
+				}
+			}
+		}()
+	}
+	for _, c := range work {
+		jobs <- c
+	}
+	close(jobs)
+	wg.Wait()
+
+	var converted, skipped, failed int
+	var firstErr error
+	for _, c := range work {
+		switch {
+		case c.err != nil:
+			failed++
+			if firstErr == nil {
+				firstErr = c.err
+			}
+		case c.skipped:
+			skipped++
+		default:
+			converted++
+		}
+	}
+	fmt.Printf("%d converted, %d up to date, %d failed\n", converted, skipped, failed)
+
+	if firstErr != nil {
+		os.Exit(exitCode(firstErr))
+	}
+}
+
+// convertInputs returns the sorted files with extension ext below inputDir
+// and the files matching patterns
+func convertInputs(patterns []string, ext string) ([]string, error) {
+	seen := map[string]bool{}
+	var inputs []string
+	add := func(name string) {
+		if name = filepath.Clean(name); !seen[name] {
+			seen[name] = true
+			inputs = append(inputs, name)
+		}
+	}
+
+	if inputDir != "" {
+		err := filepath.WalkDir(inputDir, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.Type().IsRegular() && strings.EqualFold(filepath.Ext(name), ext) {
+				add(name)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range patterns {
+		names, err := filepath.Glob(p)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %v", p, err)
+		}
+		for _, name := range names {
+			if info, err := os.Stat(name); err == nil && info.Mode().IsRegular() {
+				add(name)
+			}
+		}
+	}
+
+	sort.Strings(inputs)
+	return inputs, nil
+}
+
+// convertOutputs pairs the inputs with their outputs in outDir, named by the
+// path relative to base or by the base name for inputs outside of it. Inputs
+// sharing an output are an error, as their conversions would overwrite each other.
+func convertOutputs(inputs []string, base, outDir, outExt string) ([]*conversion, error) {
+	work := make([]*conversion, len(inputs))
+	ins := make(map[string]string, len(inputs))
+	for i, in := range inputs {
+		rel, err := filepath.Rel(base, in)
+		if err != nil || !filepath.IsLocal(rel) {
+			rel = filepath.Base(in)
+		}
+		out := filepath.Join(outDir, strings.TrimSuffix(rel, filepath.Ext(rel))+outExt)
+		if other, ok := ins[out]; ok {
+			return nil, fmt.Errorf("%s and %s are both converted to %s", other, in, out)
+		}
+		ins[out] = in
+		work[i] = &conversion{in: in, out: out}
+	}
+	return work, nil
+}
+
+// convertFile converts in to out unless out is newer than in. The output is
+// written to a temporary file renamed when complete, so a failed or
+// interrupted conversion leaves no partial output to be taken as up to date.
+func convertFile(in, out string) (skipped bool, err error) {
+	inInfo, err := os.Stat(in)
+	if err != nil {
+		return false, err
+	}
+	if outInfo, err := os.Stat(out); err == nil && !forceConvert && !outInfo.ModTime().Before(inInfo.ModTime()) {
+		return true, nil
+	}
+
+	dir := filepath.Dir(out)
+	if err = os.MkdirAll(dir, 0777); err != nil {
+		return false, err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(out)+".*")
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if err = convertTo(tmp, in); err != nil {
+		return false, err
+	}
+	if err = tmp.Chmod(0644); err != nil {
+		return false, err
+	}
+	if err = tmp.Close(); err != nil {
+		return false, err
+	}
+	return false, os.Rename(tmp.Name(), out)
+}
+
+// convertTo writes the conversion of file in to w as set by the flags
+func convertTo(w io.Writer, in string) error {
+	if isStreaming {
+		f, err := os.Open(in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if !isDecoding {
+			return mp.FromJSON(w, f)
+		}
+		return mp.ToJSON(w, f, jsonOptions())
+	}
+
+	data, err := os.ReadFile(in)
+	if err != nil {
+		return err
+	}
+	if !isDecoding {
+		data, err = encodeMessagePack(data)
+	} else {
+		data, err = decodeMessagePack(data)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
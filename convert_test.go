@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/romanzac/json-mp/mp"
+)
+
+func TestConvertOutputs(t *testing.T) {
+	work, err := convertOutputs([]string{"in/a.json", filepath.Join("in", "sub", "b.json"), "other/c.json"}, "in", "out", ".mp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, expected := range []string{"out/a.mp", "out/sub/b.mp", "out/c.mp"} {
+		if work[i].out != filepath.FromSlash(expected) {
+			t.Errorf("%s: %s, expected %s", work[i].in, work[i].out, expected)
+		}
+	}
+
+	// inputs outside the base are named by their base name
+	_, err = convertOutputs([]string{"a/x.json", "b/x.json"}, "in", "out", ".mp")
+	if err == nil || !strings.Contains(err.Error(), "a/x.json and b/x.json") {
+		t.Error("error must occur for a shared output", err)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	sample, err := os.ReadFile("data/sample.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, out := filepath.Join(t.TempDir(), "in"), filepath.Join(t.TempDir(), "out")
+	inputs := []string{"a.json", filepath.Join("sub", "b.json"), "c.json"}
+	for _, name := range inputs {
+		name = filepath.Join(in, name)
+		if err = os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(name, sample, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// more files than workers
+	stdout, code := runCommand(t, "convert", "--input-dir", in, "--output-dir", out, "-j", "2")
+	if code != 0 || stdout != "3 converted, 0 up to date, 0 failed\n" {
+		t.Errorf("status %d: %s", code, stdout)
+	}
+	for _, name := range inputs {
+		data, err := os.ReadFile(filepath.Join(out, strings.TrimSuffix(name, ".json")+".mp"))
+		if err != nil || mp.Validate(data) != nil {
+			t.Errorf("%s: output must be valid MessagePack, %v", name, err)
+		}
+	}
+
+	stdout, code = runCommand(t, "convert", "--input-dir", in, "--output-dir", out)
+	if code != 0 || stdout != "0 converted, 3 up to date, 0 failed\n" {
+		t.Errorf("status %d: %s", code, stdout)
+	}
+
+	if err = os.WriteFile(filepath.Join(in, "bad.json"), []byte("{"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	stdout, code = runCommand(t, "convert", "--input-dir", in, "--output-dir", out, "--force")
+	if code != exitData || stdout != "3 converted, 0 up to date, 1 failed\n" {
+		t.Errorf("status %d: %s", code, stdout)
+	}
+	// neither the output nor its temporary file is left
+	entries, err := os.ReadDir(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.Contains(e.Name(), "bad.mp") {
+			t.Error("failed conversion left", e.Name())
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"reflect"
+
+	"github.com/romanzac/json-mp/mp"
+	"github.com/romanzac/json-mp/shape"
+)
+
+// runCSV converts CSV to a MessagePack array of records or back
+func runCSV() {
+
+	fileIn, err := openInput(inputFile)
+	if err != nil {
+		fail(exitIO, "Error during opening the input file: %v", err)
+	}
+	defer fileIn.Close()
+
+	if fromFormat == formatCSV {
+		opts := mp.CSVOptions{Arrays: csvArrays}
+		if csvShape {
+			opts.Shape = reflect.TypeOf(shape.DataShape{})
+		}
+		dataOut, err := mp.FromCSV(fileIn, opts)
+		if err != nil {
+			fail(exitCode(err), "Error during converting csv to msgpack: %v", err)
+		}
+		if err = writeOutput(outputFile, dataOut); err != nil {
+			fail(exitIO, "Error during writing the output file: %v", err)
+		}
+		return
+	}
+
+	dataIn, err := readInput(inputFile)
+	if err != nil {
+		fail(exitIO, "Error during reading the input file: %v", err)
+	}
+	fileOut, err := createOutput(outputFile)
+	if err != nil {
+		fail(exitIO, "Error during creating the output file: %v", err)
+	}
+	if err = closeOutput(fileOut, mp.ToCSV(fileOut, dataIn)); err != nil {
+		fail(exitCode(err), "Error during converting msgpack to csv: %v", err)
+	}
+}
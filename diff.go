@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/romanzac/json-mp/mp"
+	"github.com/romanzac/json-mp/mp/diff"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ignoreWidth bool
+	diffFormat  string
+
+	// DiffCmd reports structural differences between two MessagePack files
+	DiffCmd = &cobra.Command{
+		Use:   "diff <a.mp> <b.mp>",
+		Short: "Reports structural differences between two MessagePack files",
+		Long:  `Reports structural differences between two MessagePack files, exits with status 1 when they differ`,
+		Args:  cobra.ExactArgs(2),
+		Run:   runDiff,
+	}
+)
+
+func init() {
+	DiffCmd.Flags().BoolVar(&ignoreWidth, "ignore-width", false, "numbers of different widths are equal (uint8 1 == int64 1)")
+	DiffCmd.Flags().StringVarP(&diffFormat, "format", "f", "text", "output format: text or json")
+
+	JsonMpCmd.AddCommand(DiffCmd)
+}
+
+func runDiff(cmd *cobra.Command, args []string) {
+
+	a, err := readInput(args[0])
+	if err != nil {
+		fail(exitIO, "Error during reading the MessagePack file: %v", err)
+	}
+	b, err := readInput(args[1])
+	if err != nil {
+		fail(exitIO, "Error during reading the MessagePack file: %v", err)
+	}
+
+	ds, err := mp.Diff(a, b, mp.DiffOptions{IgnoreNumericWidth: ignoreWidth})
+	if err != nil {
+		fail(exitData, "Error during comparing: %v", err)
+	}
+
+	switch diffFormat {
+	case "text":
+		fmt.Print(diff.Text(ds))
+	case "json":
+		if ds == nil {
+			ds = []mp.Difference{}
+		}
+		out, err := json.MarshalIndent(ds, "", "  ")
+		if err != nil {
+			fail(exitData, "Error during encoding to JSON: %v", err)
+		}
+		fmt.Println(string(out))
+	default:
+		fail(exitUsage, "Unknown output format: %s", diffFormat)
+	}
+
+	if len(ds) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/romanzac/json-mp/mp/gen"
+	"github.com/spf13/cobra"
+)
+
+var (
+	genTypes  []string
+	genOutput string
+
+	// GenCmd writes reflection-free MessagePack methods for Go struct types
+	GenCmd = &cobra.Command{
+		Use:   "gen [dir]",
+		Short: "Generates MessagePack methods for Go struct types",
+		Long: `Generates MarshalMsgpack, AppendMsgpack, UnmarshalMsgpack and Msgsize methods
+for struct types of the Go package in dir (default current directory).
+mp.Marshal and mp.Unmarshal use them instead of reflection.`,
+		Args: cobra.MaximumNArgs(1),
+		Run:  runGen,
+	}
+)
+
+func init() {
+	GenCmd.Flags().StringSliceVarP(&genTypes, "types", "t", nil, "struct types to generate for, all when empty")
+	GenCmd.Flags().StringVarP(&genOutput, "output", "o", "msgpack_gen.go", "output file name in dir")
+
+	JsonMpCmd.AddCommand(GenCmd)
+}
+
+func runGen(cmd *cobra.Command, args []string) {
+
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	src, err := gen.Generate(dir, gen.Options{Types: genTypes, Output: genOutput})
+	if err != nil {
+		fail(exitCode(err), "Error during code generation: %v", err)
+	}
+
+	if err = os.WriteFile(filepath.Join(dir, genOutput), src, 0644); err != nil {
+		fail(exitIO, "Error during writing output file: %v", err)
+	}
+}
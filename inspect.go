@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+
+	"github.com/romanzac/json-mp/mp/inspect"
+	"github.com/spf13/cobra"
+)
+
+var (
+	inspectDepth int
+	inspectColor string
+
+	// InspectCmd prints MessagePack file as annotated tree
+	InspectCmd = &cobra.Command{
+		Use:   "inspect <file.mp>",
+		Short: "Prints MessagePack file as annotated tree with offsets and format codes",
+		Args:  cobra.ExactArgs(1),
+		Run:   runInspect,
+	}
+)
+
+func init() {
+	InspectCmd.Flags().IntVar(&inspectDepth, "depth", 0, "collapses containers nested deeper (0 shows all)")
+	InspectCmd.Flags().StringVar(&inspectColor, "color", "auto", "colorizes output: auto, always or never")
+
+	JsonMpCmd.AddCommand(InspectCmd)
+}
+
+func runInspect(cmd *cobra.Command, args []string) {
+
+	data, err := readInput(args[0])
+	if err != nil {
+		fail(exitIO, "Error during reading the MessagePack file: %v", err)
+	}
+
+	opts := inspect.Options{MaxDepth: inspectDepth}
+	switch inspectColor {
+	case "always":
+		opts.Color = true
+	case "auto":
+		stat, err := os.Stdout.Stat()
+		opts.Color = err == nil && stat.Mode()&os.ModeCharDevice != 0
+	case "never":
+	default:
+		fail(exitUsage, "Unknown color mode: %s", inspectColor)
+	}
+
+	// errors in data are printed as part of the tree
+	if err = inspect.Fprint(os.Stdout, data, opts); err != nil {
+		os.Exit(exitCode(err))
+	}
+}
@@ -1,70 +1,78 @@
 package main
 
 import (
-	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/romanzac/json-mp/mp"
 	"github.com/romanzac/json-mp/shape"
 	"github.com/spf13/cobra"
-	"io"
 	"os"
 )
 
 var (
 	isDecoding            bool
+	isStreaming           bool
+	isPretty              bool
+	isNDJSON, skipInvalid bool
 	inputFile, outputFile string
+	fromFormat, toFormat  string
+	cborTags              []string
+	csvArrays, csvShape   bool
 
 	// JsonMpCmd to starts the application
 	JsonMpCmd = &cobra.Command{
-		Use:     "json-mp",
-		Short:   "JSON <-> MessagePack encoding tool",
-		Long:    `Encodes file from JSON to MessagePack format (default mode)`,
+		Use:   "json-mp",
+		Short: "JSON <-> MessagePack encoding tool",
+		Long: `Encodes file from JSON to MessagePack format (default mode)
+
+Input and output default to stdin and stdout, "-" names them explicitly.
+Exit status is 2 for usage errors, 3 for I/O errors and 4 for invalid input.`,
 		Version: "1.0.0",
 		Run:     runJsonMp,
 	}
 )
 
 func init() {
-	JsonMpCmd.PersistentFlags().BoolVarP(&isDecoding, "decode", "d", false, "decodes MessagePack to JSON format")
-	JsonMpCmd.PersistentFlags().StringVarP(&inputFile, "input", "i", "", "input file path")
-	JsonMpCmd.PersistentFlags().StringVarP(&outputFile, "output", "o", "", "output file path")
-	JsonMpCmd.MarkPersistentFlagRequired("input")
-	JsonMpCmd.MarkPersistentFlagRequired("output")
-	JsonMpCmd.MarkFlagsRequiredTogether("input", "output")
-
+	JsonMpCmd.Flags().BoolVarP(&isDecoding, "decode", "d", false, "decodes MessagePack to JSON format")
+	JsonMpCmd.Flags().BoolVarP(&isStreaming, "stream", "s", false, "streams without the shape, in constant memory")
+	JsonMpCmd.Flags().BoolVarP(&isPretty, "pretty", "p", false, "indents JSON output")
+	JsonMpCmd.Flags().BoolVar(&isNDJSON, "ndjson", false, "converts JSON Lines, one MessagePack value per line")
+	JsonMpCmd.Flags().BoolVar(&skipInvalid, "skip-invalid", false, "skips invalid records in --ndjson mode")
+	JsonMpCmd.Flags().StringVar(&fromFormat, "from", "", "input format: json, msgpack, cbor or csv, with --to")
+	JsonMpCmd.Flags().StringVar(&toFormat, "to", "", "output format: json, msgpack, cbor or csv, with --from")
+	JsonMpCmd.Flags().StringSliceVar(&cborTags, "tag", nil, "maps a CBOR tag to a MessagePack ext type, e.g. 37=1")
+	JsonMpCmd.Flags().BoolVar(&csvArrays, "csv-arrays", false, "writes CSV records as arrays instead of maps")
+	JsonMpCmd.Flags().BoolVar(&csvShape, "csv-shape", false, "takes CSV column types from the shape instead of inferring them")
+	JsonMpCmd.Flags().StringVarP(&inputFile, "input", "i", stdio, "input file path, - for stdin")
+	JsonMpCmd.Flags().StringVarP(&outputFile, "output", "o", stdio, "output file path, - for stdout")
 }
 
 func main() {
-	err := JsonMpCmd.Execute()
-	if err != nil && err.Error() != "" {
-		fmt.Println(err)
+	// cobra has printed the error and usage to stderr
+	if err := JsonMpCmd.Execute(); err != nil {
+		os.Exit(exitUsage)
 	}
 }
 
-func encodeMessagePack(fileIn *os.File) ([]byte, error) {
-
-	inStat, err := fileIn.Stat()
-	if err != nil {
-		return nil, err
-	}
+func encodeMessagePack(dataIn []byte) ([]byte, error) {
+	return encodeShape(dataIn, &shape.DataShape{})
+}
 
-	// Read the file into a byte slice
-	dataIn := make([]byte, inStat.Size())
-	_, err = bufio.NewReader(fileIn).Read(dataIn)
-	if err != nil && err != io.EOF {
-		return nil, err
-	}
+// encodeShape deserializes JSON into result, a pointer to the shape, and
+// encodes it to MessagePack
+func encodeShape(dataIn []byte, result interface{}) ([]byte, error) {
 
 	// Validate json input
 	if !json.Valid(dataIn) {
 		return nil, errors.New("invalid JSON input")
 	}
 
-	// Assign data shape and deserialize JSON
-	var result shape.DataShape
-	err = json.Unmarshal(dataIn, &result)
+	// Deserialize JSON, numbers in interface fields of the shape are kept as
+	// json.Number so integers above 2^53 are not rounded to float64
+	dec := json.NewDecoder(bytes.NewReader(dataIn))
+	dec.UseNumber()
+	err := dec.Decode(result)
 	if err != nil {
 		return nil, err
 	}
@@ -78,30 +86,57 @@ func encodeMessagePack(fileIn *os.File) ([]byte, error) {
 	return dataOut, nil
 }
 
-func decodeMessagePack(fileIn *os.File) ([]byte, error) {
+func streamMessagePack(fileIn *os.File) error {
+	fileOut, err := createOutput(outputFile)
+	if err != nil {
+		return err
+	}
+	return closeOutput(fileOut, mp.FromJSON(fileOut, fileIn))
+}
 
-	// Get the file size
-	inStat, err := fileIn.Stat()
+func streamJSON(fileIn *os.File) error {
+	fileOut, err := createOutput(outputFile)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	return closeOutput(fileOut, mp.ToJSON(fileOut, fileIn, jsonOptions()))
+}
+
+// jsonOptions returns the streaming JSON options set by the flags
+func jsonOptions() mp.JSONOptions {
+	opts := mp.JSONOptions{}
+	if isPretty {
+		opts.Indent = "  "
 	}
+	return opts
+}
 
-	// Read the file into a byte slice
-	dataIn := make([]byte, inStat.Size())
-	_, err = bufio.NewReader(fileIn).Read(dataIn)
-	if err != nil && err != io.EOF {
+func decodeMessagePack(dataIn []byte) ([]byte, error) {
+	return decodeShape(dataIn, &shape.DataShape{})
+}
+
+// decodeShape deserializes MessagePack into result, a pointer to the shape,
+// and encodes it to JSON
+func decodeShape(dataIn []byte, result interface{}) ([]byte, error) {
+
+	// Validate MessagePack input
+	if err := mp.Validate(dataIn); err != nil {
 		return nil, err
 	}
 
-	// Assign data shape and deserialize MessagePack
-	result := shape.DataShape{}
-	err = mp.Unmarshal(dataIn, &result)
+	// Deserialize MessagePack
+	err := mp.Unmarshal(dataIn, result)
 	if err != nil {
 		return nil, err
 	}
 
 	// Encode to JSON
-	dataOut, err := json.Marshal(result)
+	var dataOut []byte
+	if isPretty {
+		dataOut, err = json.MarshalIndent(result, "", "  ")
+	} else {
+		dataOut, err = json.Marshal(result)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -111,32 +146,79 @@ func decodeMessagePack(fileIn *os.File) ([]byte, error) {
 
 func runJsonMp(cmd *cobra.Command, args []string) {
 
-	fileIn, err := os.Open(inputFile)
+	if fromFormat != "" || toFormat != "" {
+		runTranscode()
+		return
+	}
+
+	if isNDJSON {
+		runNDJSON()
+		return
+	}
+
+	if isStreaming {
+		fileIn, err := openInput(inputFile)
+		if err != nil {
+			fail(exitIO, "Error during opening the input file: %v", err)
+		}
+		defer fileIn.Close()
+
+		if !isDecoding {
+			if err = streamMessagePack(fileIn); err != nil {
+				fail(exitCode(err), "Error during encoding to MessagePack: %v", err)
+			}
+		} else if err = streamJSON(fileIn); err != nil {
+			fail(exitCode(err), "Error during decoding to JSON: %v", err)
+		}
+		return
+	}
+
+	dataIn, err := readInput(inputFile)
 	if err != nil {
-		panic(err)
+		fail(exitIO, "Error during reading the input file: %v", err)
 	}
-	defer fileIn.Close()
 
 	if !isDecoding {
-		mpData, err := encodeMessagePack(fileIn)
+		mpData, err := encodeMessagePack(dataIn)
 		if err != nil {
-			fmt.Printf("Error during encoding to MessagePack: %v", err)
-			return
+			fail(exitData, "Error during encoding to MessagePack: %v", err)
 		}
-		if err = os.WriteFile(outputFile, mpData, 0666); err != nil {
-			fmt.Printf("Error during writing the MessagePack file: %v", err)
-			return
+		if err = writeOutput(outputFile, mpData); err != nil {
+			fail(exitIO, "Error during writing the MessagePack file: %v", err)
 		}
 
 	} else {
-		jsonData, err := decodeMessagePack(fileIn)
+		jsonData, err := decodeMessagePack(dataIn)
 		if err != nil {
-			fmt.Printf("Error during decoding to JSON: %v", err)
-			return
+			fail(exitData, "Error during decoding to JSON: %v", err)
 		}
-		if err = os.WriteFile(outputFile, jsonData, 0666); err != nil {
-			fmt.Printf("Error during writing the JSON file: %v", err)
-			return
+		if err = writeOutput(outputFile, jsonData); err != nil {
+			fail(exitIO, "Error during writing the JSON file: %v", err)
+		}
+	}
+}
+
+func runNDJSON() {
+
+	if isPretty {
+		fail(exitUsage, "JSON Lines cannot be pretty-printed")
+	}
+
+	fileIn, err := openInput(inputFile)
+	if err != nil {
+		fail(exitIO, "Error during opening the input file: %v", err)
+	}
+	defer fileIn.Close()
+	fileOut, err := createOutput(outputFile)
+	if err != nil {
+		fail(exitIO, "Error during creating the output file: %v", err)
+	}
+
+	if !isDecoding {
+		if err = closeOutput(fileOut, encodeNDJSON(fileIn, fileOut)); err != nil {
+			fail(exitCode(err), "Error during encoding to MessagePack: %v", err)
 		}
+	} else if err = closeOutput(fileOut, decodeNDJSON(fileIn, fileOut)); err != nil {
+		fail(exitCode(err), "Error during decoding to JSON: %v", err)
 	}
 }
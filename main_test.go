@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestShapeInterfaceNumbers(t *testing.T) {
+	// interface fields keep integers above 2^53, which float64 would round
+	type withAny struct {
+		ID    interface{} `json:"id"`
+		Items interface{} `json:"items"`
+	}
+	in := `{"id":9007199254740993,"items":[18446744073709551615,-9223372036854775808,1.5]}`
+
+	d, err := encodeShape([]byte(in), &withAny{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := decodeShape(d, &withAny{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != in {
+		t.Errorf("round trip %s, expected %s", out, in)
+	}
+
+	if _, err = encodeShape([]byte(`{"id":123456789012345678901234}`), &withAny{}); err == nil {
+		t.Error("error must occur for an integer beyond 64 bits")
+	}
+}
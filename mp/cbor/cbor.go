@@ -0,0 +1,330 @@
+// Package cbor encodes and decodes CBOR (RFC 8949) and transcodes it to and
+// from MessagePack. Struct fields follow the rules of def.CheckStructField, so
+// a type encodes to the same keys in CBOR, MessagePack and JSON.
+package cbor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/romanzac/json-mp/mp/validate"
+)
+
+// Major types
+const (
+	majorUint   = 0
+	majorNeg    = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorArray  = 4
+	majorMap    = 5
+	majorTag    = 6
+	majorSimple = 7
+)
+
+// Additional information of the initial byte
+const (
+	info8          = 24
+	info16         = 25
+	info32         = 26
+	info64         = 27
+	infoIndefinite = 31
+)
+
+// Simple values and the break stop code
+const (
+	simpleFalse     = 20
+	simpleTrue      = 21
+	simpleNull      = 22
+	simpleUndefined = 23
+
+	breakCode = 0xff
+)
+
+// Tag numbers with a meaning in this package
+const (
+	TagDateTime     = 0     // RFC 3339 date/time string
+	TagEpoch        = 1     // seconds since the epoch, integer or float
+	TagPosBignum    = 2     // unsigned bignum byte string
+	TagNegBignum    = 3     // negative bignum byte string
+	TagSelfDescribe = 55799 // marks CBOR data, carries no meaning
+)
+
+// Tag is a tagged data item of a tag number without a Go type. Unmarshal
+// returns it in interface{} values, Marshal writes it as the tag.
+type Tag struct {
+	Number  uint64
+	Content interface{}
+}
+
+var errShort = errors.New("unexpected end of CBOR data")
+
+// maxDepth limits the nesting of arrays, maps and tags
+var maxDepth = validate.DefaultLimits.MaxDepth
+
+// appendHead appends the initial byte of major type major with argument n in
+// its shortest form
+func appendHead(b []byte, major byte, n uint64) []byte {
+	m := major << 5
+	switch {
+	case n < info8:
+		return append(b, m|byte(n))
+	case n <= math.MaxUint8:
+		return append(b, m|info8, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, m|info16), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, m|info32), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(b, m|info64), n)
+}
+
+func appendInt(b []byte, v int64) []byte {
+	if v < 0 {
+		return appendHead(b, majorNeg, uint64(-1-v))
+	}
+	return appendHead(b, majorUint, uint64(v))
+}
+
+func appendFloat32(b []byte, v float32) []byte {
+	return binary.BigEndian.AppendUint32(append(b, majorSimple<<5|info32), math.Float32bits(v))
+}
+
+func appendFloat64(b []byte, v float64) []byte {
+	return binary.BigEndian.AppendUint64(append(b, majorSimple<<5|info64), math.Float64bits(v))
+}
+
+// appendTime appends t as epoch seconds when it has no fraction of a second,
+// and as RFC 3339 string otherwise, which keeps nanoseconds a float would lose
+func appendTime(b []byte, t time.Time) []byte {
+	if t.Nanosecond() == 0 {
+		return appendInt(appendHead(b, majorTag, TagEpoch), t.Unix())
+	}
+	s := t.Format(time.RFC3339Nano)
+	b = appendHead(appendHead(b, majorTag, TagDateTime), majorText, uint64(len(s)))
+	return append(b, s...)
+}
+
+// reader reads data items from b, off is the offset of the next byte
+type reader struct {
+	b   []byte
+	off int
+}
+
+func (r *reader) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s at offset %d", fmt.Sprintf(format, args...), r.off)
+}
+
+// head reads an initial byte and its argument. For floats the argument holds
+// the bits, for indefinite lengths info is infoIndefinite and arg 0.
+func (r *reader) head() (major, info byte, arg uint64, err error) {
+	if r.off >= len(r.b) {
+		return 0, 0, 0, errShort
+	}
+	c := r.b[r.off]
+	major, info = c>>5, c&0x1f
+	switch {
+	case info < info8:
+		r.off++
+		return major, info, uint64(info), nil
+	case info <= info64:
+		size := 1 << (info - info8)
+		if r.off+1+size > len(r.b) {
+			return 0, 0, 0, errShort
+		}
+		bs := r.b[r.off+1 : r.off+1+size]
+		switch size {
+		case 1:
+			arg = uint64(bs[0])
+		case 2:
+			arg = uint64(binary.BigEndian.Uint16(bs))
+		case 4:
+			arg = uint64(binary.BigEndian.Uint32(bs))
+		default:
+			arg = binary.BigEndian.Uint64(bs)
+		}
+		r.off += 1 + size
+		return major, info, arg, nil
+	case info == infoIndefinite:
+		if major == majorUint || major == majorNeg || major == majorTag {
+			return 0, 0, 0, r.errorf("invalid initial byte %x", c)
+		}
+		r.off++
+		return major, info, 0, nil
+	}
+	return 0, 0, 0, r.errorf("reserved additional information in %x", c)
+}
+
+// atBreak consumes the break stop code when it is next
+func (r *reader) atBreak() bool {
+	if r.off < len(r.b) && r.b[r.off] == breakCode {
+		r.off++
+		return true
+	}
+	return false
+}
+
+// bytes returns the next n bytes, aliasing b
+func (r *reader) bytes(n uint64) ([]byte, error) {
+	if n > uint64(len(r.b)-r.off) {
+		return nil, errShort
+	}
+	bs := r.b[r.off : r.off+int(n)]
+	r.off += int(n)
+	return bs, nil
+}
+
+// str returns the content of a byte or text string whose head was read.
+// Chunks of an indefinite length string are joined into a new slice.
+func (r *reader) str(major, info byte, arg uint64) ([]byte, error) {
+	if info != infoIndefinite {
+		return r.bytes(arg)
+	}
+	s := []byte{}
+	for !r.atBreak() {
+		m, i, l, err := r.head()
+		if err != nil {
+			return nil, err
+		}
+		if m != major || i == infoIndefinite {
+			return nil, r.errorf("invalid chunk of indefinite length string")
+		}
+		chunk, err := r.bytes(l)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, chunk...)
+	}
+	return s, nil
+}
+
+// length returns the number of elements of an array or entries of a map whose
+// head was read, -1 when it ends with a break
+func (r *reader) length(info byte, arg uint64) (int, error) {
+	if info == infoIndefinite {
+		return -1, nil
+	}
+	// every element takes at least one byte
+	if arg > uint64(len(r.b)-r.off) {
+		return 0, errShort
+	}
+	return int(arg), nil
+}
+
+// more reports whether the container with length n read by length has
+// another element after i
+func (r *reader) more(i, n int) bool {
+	if n < 0 {
+		return !r.atBreak()
+	}
+	return i < n
+}
+
+// skip reads over the next data item and checks that it is well-formed
+func (r *reader) skip(depth int) error {
+	if depth > maxDepth {
+		return r.errorf("nesting exceeds max depth %d", maxDepth)
+	}
+	major, info, arg, err := r.head()
+	if err != nil {
+		return err
+	}
+	switch major {
+	case majorBytes, majorText:
+		_, err = r.str(major, info, arg)
+		return err
+	case majorArray, majorMap:
+		n, err := r.length(info, arg)
+		if err != nil {
+			return err
+		}
+		per := 1
+		if major == majorMap {
+			per = 2
+		}
+		for i := 0; r.more(i, n); i++ {
+			for j := 0; j < per; j++ {
+				if err = r.skip(depth + 1); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case majorTag:
+		return r.skip(depth + 1)
+	case majorSimple:
+		if info == infoIndefinite {
+			return r.errorf("unexpected break")
+		}
+	}
+	return nil
+}
+
+// float returns the float of a major type 7 head with info 25, 26 or 27 and
+// its size in bits, 16 bit floats are returned as float32 which holds them exactly
+func float(info byte, arg uint64) (float64, int) {
+	switch info {
+	case info16:
+		return float64(halfToFloat32(uint16(arg))), 32
+	case info32:
+		return float64(math.Float32frombits(uint32(arg))), 32
+	}
+	return math.Float64frombits(arg), 64
+}
+
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h) & 0x3ff
+	switch {
+	case exp == 0x1f:
+		// infinity and NaN
+		return math.Float32frombits(sign | 0xff<<23 | mant<<13)
+	case exp != 0:
+		return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+	case mant == 0:
+		return math.Float32frombits(sign)
+	}
+	// subnormal, mant * 2^-24
+	f := float32(mant) / (1 << 24)
+	if sign != 0 {
+		f = -f
+	}
+	return f
+}
+
+// parseTime returns the time of the content of tag 0 or 1
+func parseTime(number uint64, content interface{}) (time.Time, error) {
+	if number == TagDateTime {
+		s, ok := content.(string)
+		if !ok {
+			return time.Time{}, fmt.Errorf("tag 0 content is %T, not a string", content)
+		}
+		return time.Parse(time.RFC3339Nano, s)
+	}
+	switch v := content.(type) {
+	case uint64:
+		if v > math.MaxInt64 {
+			return time.Time{}, fmt.Errorf("epoch time %d out of range", v)
+		}
+		return time.Unix(int64(v), 0).UTC(), nil
+	case int64:
+		return time.Unix(v, 0).UTC(), nil
+	case float32:
+		return epochFloat(float64(v))
+	case float64:
+		return epochFloat(v)
+	}
+	return time.Time{}, fmt.Errorf("tag 1 content is %T, not a number", content)
+}
+
+func epochFloat(f float64) (time.Time, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) || math.Abs(f) > math.MaxInt64/2 {
+		return time.Time{}, fmt.Errorf("epoch time %v out of range", f)
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(math.Round(frac*1e9))).UTC(), nil
+}
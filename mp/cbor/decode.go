@@ -0,0 +1,357 @@
+package cbor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/romanzac/json-mp/mp/def"
+)
+
+// Unmarshal decodes the single CBOR data item in data into the value pointed
+// to by v. Into interface{} values it decodes unsigned integers as uint64,
+// negative ones as int64, floats as float32 or float64 by width, byte strings
+// as []byte, arrays as []interface{}, maps as map[interface{}]interface{},
+// tags 0 and 1 as time.Time and other tags as Tag. Into typed values tags other
+// than 0 and 1 are ignored, undefined is decoded like null.
+func Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("unmarshal needs a non-nil pointer")
+	}
+	r := reader{b: data}
+	if err := r.decode(rv.Elem(), 0); err != nil {
+		return err
+	}
+	if r.off != len(data) {
+		return r.errorf("trailing bytes after data item")
+	}
+	return nil
+}
+
+// decode decodes the next data item into rv
+func (r *reader) decode(rv reflect.Value, depth int) error {
+	if depth > maxDepth {
+		return r.errorf("nesting exceeds max depth %d", maxDepth)
+	}
+
+	if rv.Kind() == reflect.Interface && rv.NumMethod() == 0 {
+		v, err := r.decodeAny(depth)
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			rv.Set(reflect.Zero(rv.Type()))
+		} else {
+			rv.Set(reflect.ValueOf(v))
+		}
+		return nil
+	}
+
+	start := r.off
+	major, info, arg, err := r.head()
+	if err != nil {
+		return err
+	}
+
+	if major == majorSimple && (arg == simpleNull || arg == simpleUndefined) && info < info8 {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		r.off = start
+		return r.decode(rv.Elem(), depth)
+	}
+
+	switch rv.Type() {
+	case timeType:
+		if major != majorTag || (arg != TagDateTime && arg != TagEpoch) {
+			return r.errorf("cannot decode major type %d into time.Time", major)
+		}
+		content, err := r.decodeAny(depth + 1)
+		if err != nil {
+			return err
+		}
+		t, err := parseTime(arg, content)
+		if err != nil {
+			return err
+		}
+		rv.Set(reflect.ValueOf(t))
+		return nil
+	case tagType:
+		if major != majorTag {
+			return r.errorf("cannot decode major type %d into cbor.Tag", major)
+		}
+		content, err := r.decodeAny(depth + 1)
+		if err != nil {
+			return err
+		}
+		rv.Set(reflect.ValueOf(Tag{Number: arg, Content: content}))
+		return nil
+	}
+
+	if major == majorTag {
+		return r.decode(rv, depth+1)
+	}
+
+	switch major {
+	case majorUint, majorNeg:
+		return r.setInt(rv, major, arg)
+	case majorBytes, majorText:
+		s, err := r.str(major, info, arg)
+		if err != nil {
+			return err
+		}
+		switch {
+		case rv.Kind() == reflect.String:
+			rv.SetString(string(s))
+		case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+			rv.SetBytes(append([]byte{}, s...))
+		default:
+			return r.errorf("cannot decode string into %v", rv.Type())
+		}
+		return nil
+	case majorArray:
+		return r.decodeArray(rv, info, arg, depth)
+	case majorMap:
+		return r.decodeMap(rv, info, arg, depth)
+	}
+
+	switch {
+	case info == infoIndefinite:
+		return r.errorf("unexpected break")
+	case info >= info16:
+		f, _ := float(info, arg)
+		switch rv.Kind() {
+		case reflect.Float32, reflect.Float64:
+			rv.SetFloat(f)
+			return nil
+		}
+	case (arg == simpleFalse || arg == simpleTrue) && rv.Kind() == reflect.Bool:
+		rv.SetBool(arg == simpleTrue)
+		return nil
+	}
+	return r.errorf("cannot decode simple value or float into %v", rv.Type())
+}
+
+// setInt stores the integer of a major type 0 or 1 head with argument arg into rv
+func (r *reader) setInt(rv reflect.Value, major byte, arg uint64) error {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if arg > math.MaxInt64 {
+			return r.errorf("integer overflows %v", rv.Type())
+		}
+		i := int64(arg)
+		if major == majorNeg {
+			i = -1 - i
+		}
+		if rv.OverflowInt(i) {
+			return r.errorf("integer %d overflows %v", i, rv.Type())
+		}
+		rv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if major == majorNeg || rv.OverflowUint(arg) {
+			return r.errorf("integer overflows %v", rv.Type())
+		}
+		rv.SetUint(arg)
+	case reflect.Float32, reflect.Float64:
+		f := float64(arg)
+		if major == majorNeg {
+			f = -1 - f
+		}
+		rv.SetFloat(f)
+	default:
+		return r.errorf("cannot decode integer into %v", rv.Type())
+	}
+	return nil
+}
+
+func (r *reader) decodeArray(rv reflect.Value, info byte, arg uint64, depth int) error {
+	n, err := r.length(info, arg)
+	if err != nil {
+		return err
+	}
+	switch rv.Kind() {
+	case reflect.Slice:
+		if n >= 0 {
+			rv.Set(reflect.MakeSlice(rv.Type(), n, n))
+		} else {
+			rv.Set(reflect.MakeSlice(rv.Type(), 0, 0))
+		}
+	case reflect.Array:
+		if n > rv.Len() {
+			return r.errorf("array of %d elements is longer than %v", n, rv.Type())
+		}
+	default:
+		return r.errorf("cannot decode array into %v", rv.Type())
+	}
+
+	for i := 0; r.more(i, n); i++ {
+		if n < 0 {
+			if rv.Kind() == reflect.Array && i >= rv.Len() {
+				return r.errorf("array is longer than %v", rv.Type())
+			}
+			if rv.Kind() == reflect.Slice {
+				rv.Set(reflect.Append(rv, reflect.Zero(rv.Type().Elem())))
+			}
+		}
+		if err := r.decode(rv.Index(i), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *reader) decodeMap(rv reflect.Value, info byte, arg uint64, depth int) error {
+	n, err := r.length(info, arg)
+	if err != nil {
+		return err
+	}
+
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMap(rv.Type()))
+		}
+		kt, vt := rv.Type().Key(), rv.Type().Elem()
+		for i := 0; r.more(i, n); i++ {
+			k := reflect.New(kt).Elem()
+			if err := r.decode(k, depth+1); err != nil {
+				return err
+			}
+			v := reflect.New(vt).Elem()
+			if err := r.decode(v, depth+1); err != nil {
+				return err
+			}
+			rv.SetMapIndex(k, v)
+		}
+		return nil
+
+	case reflect.Struct:
+		t := rv.Type()
+		fields := make(map[string]int, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			if ok, name := def.CheckStructField(t.Field(i)); ok {
+				fields[name] = i
+			}
+		}
+		for i := 0; r.more(i, n); i++ {
+			var name string
+			if err := r.decode(reflect.ValueOf(&name).Elem(), depth+1); err != nil {
+				return err
+			}
+			if f, ok := fields[name]; ok {
+				if err := r.decode(rv.Field(f), depth+1); err != nil {
+					return fmt.Errorf("field %s: %w", name, err)
+				}
+			} else if err := r.skip(depth + 1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return r.errorf("cannot decode map into %v", rv.Type())
+}
+
+// decodeAny decodes the next data item into the types listed by Unmarshal
+func (r *reader) decodeAny(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, r.errorf("nesting exceeds max depth %d", maxDepth)
+	}
+	major, info, arg, err := r.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case majorUint:
+		return arg, nil
+	case majorNeg:
+		if arg > math.MaxInt64 {
+			return nil, r.errorf("negative integer -1-%d out of range", arg)
+		}
+		return -1 - int64(arg), nil
+	case majorBytes, majorText:
+		s, err := r.str(major, info, arg)
+		if err != nil {
+			return nil, err
+		}
+		if major == majorText {
+			return string(s), nil
+		}
+		return append([]byte{}, s...), nil
+
+	case majorArray:
+		n, err := r.length(info, arg)
+		if err != nil {
+			return nil, err
+		}
+		a := []interface{}{}
+		for i := 0; r.more(i, n); i++ {
+			v, err := r.decodeAny(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		return a, nil
+	case majorMap:
+		n, err := r.length(info, arg)
+		if err != nil {
+			return nil, err
+		}
+		m := map[interface{}]interface{}{}
+		for i := 0; r.more(i, n); i++ {
+			k, err := r.decodeAny(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if k != nil && !reflect.TypeOf(k).Comparable() {
+				return nil, r.errorf("map key of type %T", k)
+			}
+			v, err := r.decodeAny(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = v
+		}
+		return m, nil
+
+	case majorTag:
+		content, err := r.decodeAny(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		switch arg {
+		case TagDateTime, TagEpoch:
+			return parseTime(arg, content)
+		case TagSelfDescribe:
+			return content, nil
+		}
+		return Tag{Number: arg, Content: content}, nil
+	}
+
+	switch {
+	case info == infoIndefinite:
+		return nil, r.errorf("unexpected break")
+	case info >= info16:
+		f, bits := float(info, arg)
+		if bits == 32 {
+			return float32(f), nil
+		}
+		return f, nil
+	}
+	switch arg {
+	case simpleFalse:
+		return false, nil
+	case simpleTrue:
+		return true, nil
+	case simpleNull, simpleUndefined:
+		return nil, nil
+	}
+	return nil, r.errorf("unassigned simple value %d", arg)
+}
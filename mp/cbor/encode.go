@@ -0,0 +1,172 @@
+package cbor
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/romanzac/json-mp/mp/def"
+)
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	tagType  = reflect.TypeOf(Tag{})
+)
+
+// Marshal returns the CBOR encoding of v. Integers take their shortest form,
+// float32 and float64 keep their width, []byte is a byte string, time.Time is
+// tagged as epoch or date/time (see appendTime) and structs are maps keyed by
+// field name. Map keys are sorted by their encoding, so the output is
+// deterministic. Nil pointers, slices, maps and interfaces are null.
+func Marshal(v interface{}) ([]byte, error) {
+	e := encoder{}
+	if err := e.encode(reflect.ValueOf(v), 0); err != nil {
+		return nil, err
+	}
+	return e.b, nil
+}
+
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) encode(rv reflect.Value, depth int) error {
+	if depth > maxDepth {
+		return fmt.Errorf("nesting exceeds max depth %d", maxDepth)
+	}
+	if !rv.IsValid() {
+		e.b = append(e.b, majorSimple<<5|simpleNull)
+		return nil
+	}
+
+	switch rv.Type() {
+	case timeType:
+		e.b = appendTime(e.b, rv.Interface().(time.Time))
+		return nil
+	case tagType:
+		tag := rv.Interface().(Tag)
+		e.b = appendHead(e.b, majorTag, tag.Number)
+		return e.encode(reflect.ValueOf(tag.Content), depth+1)
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			e.b = append(e.b, majorSimple<<5|simpleNull)
+			return nil
+		}
+		return e.encode(rv.Elem(), depth)
+
+	case reflect.Bool:
+		if rv.Bool() {
+			e.b = append(e.b, majorSimple<<5|simpleTrue)
+		} else {
+			e.b = append(e.b, majorSimple<<5|simpleFalse)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.b = appendInt(e.b, rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.b = appendHead(e.b, majorUint, rv.Uint())
+	case reflect.Float32:
+		e.b = appendFloat32(e.b, float32(rv.Float()))
+	case reflect.Float64:
+		e.b = appendFloat64(e.b, rv.Float())
+	case reflect.String:
+		e.b = appendHead(e.b, majorText, uint64(rv.Len()))
+		e.b = append(e.b, rv.String()...)
+
+	case reflect.Slice:
+		if rv.IsNil() {
+			e.b = append(e.b, majorSimple<<5|simpleNull)
+			return nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			e.b = appendHead(e.b, majorBytes, uint64(rv.Len()))
+			e.b = append(e.b, rv.Bytes()...)
+			return nil
+		}
+		return e.array(rv, depth)
+	case reflect.Array:
+		return e.array(rv, depth)
+
+	case reflect.Map:
+		if rv.IsNil() {
+			e.b = append(e.b, majorSimple<<5|simpleNull)
+			return nil
+		}
+		return e.mapping(rv, depth)
+	case reflect.Struct:
+		return e.structure(rv, depth)
+
+	default:
+		return fmt.Errorf("type %v is not supported", rv.Type())
+	}
+	return nil
+}
+
+func (e *encoder) array(rv reflect.Value, depth int) error {
+	e.b = appendHead(e.b, majorArray, uint64(rv.Len()))
+	for i := 0; i < rv.Len(); i++ {
+		if err := e.encode(rv.Index(i), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mapping writes the entries of rv ordered by their encoded keys
+func (e *encoder) mapping(rv reflect.Value, depth int) error {
+	type entry struct {
+		key, value []byte
+	}
+	entries := make([]entry, 0, rv.Len())
+	sub := encoder{}
+	iter := rv.MapRange()
+	for iter.Next() {
+		sub.b = nil
+		if err := sub.encode(iter.Key(), depth+1); err != nil {
+			return err
+		}
+		key := sub.b
+		sub.b = nil
+		if err := sub.encode(iter.Value(), depth+1); err != nil {
+			return err
+		}
+		entries = append(entries, entry{key, sub.b})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+
+	e.b = appendHead(e.b, majorMap, uint64(len(entries)))
+	for _, en := range entries {
+		e.b = append(e.b, en.key...)
+		e.b = append(e.b, en.value...)
+	}
+	return nil
+}
+
+// structure writes the fields selected by def.CheckStructField in declaration order
+func (e *encoder) structure(rv reflect.Value, depth int) error {
+	t := rv.Type()
+	var fields []int
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		if ok, name := def.CheckStructField(t.Field(i)); ok {
+			fields = append(fields, i)
+			names = append(names, name)
+		}
+	}
+
+	e.b = appendHead(e.b, majorMap, uint64(len(fields)))
+	for j, i := range fields {
+		e.b = appendHead(e.b, majorText, uint64(len(names[j])))
+		e.b = append(e.b, names[j]...)
+		if err := e.encode(rv.Field(i), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,312 @@
+package cbor
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/romanzac/json-mp/mp/def"
+	"github.com/romanzac/json-mp/mp/wire"
+)
+
+// Options controls the transcoding of tags
+type Options struct {
+	// Tags maps CBOR tag numbers to MessagePack ext types. The ext payload is
+	// the CBOR encoding of the tag content, so the tag is restored on the way back.
+	Tags map[uint64]int8
+}
+
+// ToMsgpack transcodes a sequence of CBOR data items to MessagePack values:
+//
+//   - byte strings are bin, text strings str, indefinite lengths are resolved
+//   - 16 bit floats are float32, which holds them exactly
+//   - undefined is nil
+//   - tags 0 and 1 are timestamp ext, bignums (tags 2 and 3) integers when
+//     they fit, tags in opts.Tags ext and tag 55799 is dropped
+//
+// Other tags, simple values and integers below the int64 range are errors.
+func ToMsgpack(data []byte, opts Options) ([]byte, error) {
+	t := toMsgpack{r: reader{b: data}, opts: opts}
+	for t.r.off < len(t.r.b) {
+		if err := t.value(0); err != nil {
+			return nil, err
+		}
+	}
+	return t.out, nil
+}
+
+type toMsgpack struct {
+	r    reader
+	out  []byte
+	opts Options
+}
+
+func (t *toMsgpack) value(depth int) error {
+	if depth > maxDepth {
+		return t.r.errorf("nesting exceeds max depth %d", maxDepth)
+	}
+	start := t.r.off
+	major, info, arg, err := t.r.head()
+	if err != nil {
+		return err
+	}
+
+	switch major {
+	case majorUint:
+		t.out = wire.AppendUint(t.out, arg)
+	case majorNeg:
+		if arg > math.MaxInt64 {
+			return t.r.errorf("negative integer -1-%d out of range", arg)
+		}
+		t.out = wire.AppendInt(t.out, -1-int64(arg))
+	case majorBytes, majorText:
+		s, err := t.r.str(major, info, arg)
+		if err != nil {
+			return err
+		}
+		if major == majorBytes {
+			t.out = wire.AppendBytes(t.out, s)
+		} else {
+			t.out = wire.AppendStringBytes(t.out, s)
+		}
+	case majorArray, majorMap:
+		return t.container(major, info, arg, depth)
+	case majorTag:
+		return t.tag(arg, start, depth)
+
+	default:
+		switch {
+		case info == infoIndefinite:
+			return t.r.errorf("unexpected break")
+		case info >= info16:
+			f, bits := float(info, arg)
+			if bits == 32 {
+				t.out = wire.AppendFloat32(t.out, float32(f))
+			} else {
+				t.out = wire.AppendFloat64(t.out, f)
+			}
+		case arg == simpleFalse || arg == simpleTrue:
+			t.out = wire.AppendBool(t.out, arg == simpleTrue)
+		case arg == simpleNull || arg == simpleUndefined:
+			t.out = wire.AppendNil(t.out)
+		default:
+			return t.r.errorf("unassigned simple value %d", arg)
+		}
+	}
+	return nil
+}
+
+// container transcodes an array or map. The elements of indefinite length
+// containers are written after the header once they are counted.
+func (t *toMsgpack) container(major, info byte, arg uint64, depth int) error {
+	n, err := t.r.length(info, arg)
+	if err != nil {
+		return err
+	}
+	per := 1
+	if major == majorMap {
+		per = 2
+	}
+	if int64(n) > math.MaxUint32 {
+		return t.r.errorf("length %d exceeds MessagePack limits", n)
+	}
+
+	var outer []byte
+	if n < 0 {
+		outer, t.out = t.out, nil
+	} else if major == majorMap {
+		t.out = wire.AppendMapHeader(t.out, n)
+	} else {
+		t.out = wire.AppendArrayHeader(t.out, n)
+	}
+
+	i := 0
+	for ; t.r.more(i, n); i++ {
+		for j := 0; j < per; j++ {
+			if err := t.value(depth + 1); err != nil {
+				return err
+			}
+		}
+	}
+
+	if n < 0 {
+		if major == majorMap {
+			outer = wire.AppendMapHeader(outer, i)
+		} else {
+			outer = wire.AppendArrayHeader(outer, i)
+		}
+		t.out = append(outer, t.out...)
+	}
+	return nil
+}
+
+// tag transcodes the tag number whose head starts at offset start
+func (t *toMsgpack) tag(number uint64, start, depth int) error {
+	if typ, ok := t.opts.Tags[number]; ok {
+		from := t.r.off
+		if err := t.r.skip(depth + 1); err != nil {
+			return err
+		}
+		t.out = wire.AppendExt(t.out, typ, t.r.b[from:t.r.off])
+		return nil
+	}
+
+	switch number {
+	case TagSelfDescribe:
+		return t.value(depth + 1)
+	case TagDateTime, TagEpoch:
+		content, err := t.r.decodeAny(depth + 1)
+		if err != nil {
+			return err
+		}
+		tm, err := parseTime(number, content)
+		if err != nil {
+			return fmt.Errorf("%v at offset %d", err, start)
+		}
+		t.out = wire.AppendTime(t.out, tm)
+		return nil
+	case TagPosBignum, TagNegBignum:
+		content, err := t.r.decodeAny(depth + 1)
+		if err != nil {
+			return err
+		}
+		bs, ok := content.([]byte)
+		if !ok {
+			return fmt.Errorf("bignum content is %T, not a byte string at offset %d", content, start)
+		}
+		n := new(big.Int).SetBytes(bs)
+		if number == TagNegBignum {
+			n.Neg(n).Sub(n, big.NewInt(1))
+		}
+		switch {
+		case n.IsInt64():
+			t.out = wire.AppendInt(t.out, n.Int64())
+		case n.IsUint64():
+			t.out = wire.AppendUint(t.out, n.Uint64())
+		default:
+			return fmt.Errorf("bignum %v out of range at offset %d", n, start)
+		}
+		return nil
+	}
+	return fmt.Errorf("tag %d has no ext type at offset %d", number, start)
+}
+
+// FromMsgpack transcodes a sequence of MessagePack values to CBOR data items.
+// Timestamp ext is written as tag 1 or 0 (see Marshal), ext of a type in
+// opts.Tags as its tag and other ext types are errors.
+func FromMsgpack(data []byte, opts Options) ([]byte, error) {
+	tags := make(map[int8]uint64, len(opts.Tags))
+	for number, typ := range opts.Tags {
+		if other, ok := tags[typ]; ok {
+			return nil, fmt.Errorf("tags %d and %d map to ext type %d", other, number, typ)
+		}
+		tags[typ] = number
+	}
+
+	var out []byte
+	for b := data; len(b) > 0; {
+		next, rest, err := fromMsgpack(out, b, tags, 0)
+		if err != nil {
+			return nil, fmt.Errorf("value at offset %d: %v", len(data)-len(b), err)
+		}
+		out, b = next, rest
+	}
+	return out, nil
+}
+
+// fromMsgpack appends the next value of b to out and returns the bytes following it
+func fromMsgpack(out, b []byte, tags map[int8]uint64, depth int) ([]byte, []byte, error) {
+	if depth > maxDepth {
+		return nil, nil, fmt.Errorf("nesting exceeds max depth %d", maxDepth)
+	}
+
+	switch wire.NextType(b) {
+	case wire.NilType:
+		rest, err := wire.ReadNil(b)
+		return append(out, majorSimple<<5|simpleNull), rest, err
+	case wire.BoolType:
+		v, rest, err := wire.ReadBool(b)
+		if v {
+			return append(out, majorSimple<<5|simpleTrue), rest, err
+		}
+		return append(out, majorSimple<<5|simpleFalse), rest, err
+	case wire.IntType:
+		v, rest, err := wire.ReadInt64(b)
+		return appendInt(out, v), rest, err
+	case wire.UintType:
+		v, rest, err := wire.ReadUint64(b)
+		return appendHead(out, majorUint, v), rest, err
+	case wire.FloatType:
+		if b[0] == def.Float32 {
+			v, rest, err := wire.ReadFloat32(b)
+			return appendFloat32(out, v), rest, err
+		}
+		v, rest, err := wire.ReadFloat64(b)
+		return appendFloat64(out, v), rest, err
+	case wire.StrType:
+		s, rest, err := wire.ReadStringBytes(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		return append(appendHead(out, majorText, uint64(len(s))), s...), rest, nil
+	case wire.BinType:
+		s, rest, err := wire.ReadBytes(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		return append(appendHead(out, majorBytes, uint64(len(s))), s...), rest, nil
+
+	case wire.ArrayType, wire.MapType:
+		var n int
+		var rest []byte
+		var err error
+		if wire.NextType(b) == wire.MapType {
+			n, rest, err = wire.ReadMapHeader(b)
+			out = appendHead(out, majorMap, uint64(n))
+			n *= 2
+		} else {
+			n, rest, err = wire.ReadArrayHeader(b)
+			out = appendHead(out, majorArray, uint64(n))
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		for i := 0; i < n; i++ {
+			if out, rest, err = fromMsgpack(out, rest, tags, depth+1); err != nil {
+				return nil, nil, err
+			}
+		}
+		return out, rest, nil
+
+	case wire.ExtType:
+		typ, payload, rest, err := wire.ReadExt(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		if typ == def.TimestampExt {
+			var tm time.Time
+			if tm, _, err = wire.ReadTime(b); err != nil {
+				return nil, nil, err
+			}
+			return appendTime(out, tm), rest, nil
+		}
+		number, ok := tags[typ]
+		if !ok {
+			return nil, nil, fmt.Errorf("ext type %d has no tag", typ)
+		}
+		// the payload must be one data item to keep the output well-formed
+		r := reader{b: payload}
+		if err = r.skip(depth + 1); err != nil {
+			return nil, nil, fmt.Errorf("ext type %d payload: %v", typ, err)
+		}
+		if r.off != len(payload) {
+			return nil, nil, fmt.Errorf("ext type %d payload has trailing bytes", typ)
+		}
+		return append(appendHead(out, majorTag, number), payload...), rest, nil
+	}
+	if len(b) == 0 {
+		return nil, nil, fmt.Errorf("unexpected end of MessagePack data")
+	}
+	return nil, nil, fmt.Errorf("invalid code %x", b[0])
+}
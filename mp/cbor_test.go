@@ -0,0 +1,277 @@
+package mp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/romanzac/json-mp/mp/cbor"
+	"github.com/romanzac/json-mp/mp/wire"
+)
+
+func TestFromCBOR(t *testing.T) {
+	// examples of RFC 8949 appendix A
+	for _, c := range []struct {
+		cbor     string
+		expected []byte
+	}{
+		{"00", wire.AppendUint(nil, 0)},
+		{"1818", wire.AppendUint(nil, 24)},
+		{"1b000000e8d4a51000", wire.AppendUint(nil, 1000000000000)},
+		{"1bffffffffffffffff", wire.AppendUint(nil, math.MaxUint64)},
+		{"3903e7", wire.AppendInt(nil, -1000)},
+		{"3b7fffffffffffffff", wire.AppendInt(nil, math.MinInt64)},
+		{"c249010000000000000000", nil},
+		{"c24900ffffffffffffffff", wire.AppendUint(nil, math.MaxUint64)},
+		{"c34900ffffffffffffffff", nil},
+		{"f93c00", wire.AppendFloat32(nil, 1)},
+		{"f97bff", wire.AppendFloat32(nil, 65504)},
+		{"f90001", wire.AppendFloat32(nil, 5.960464477539063e-8)},
+		{"f9fc00", wire.AppendFloat32(nil, float32(math.Inf(-1)))},
+		{"fa47c35000", wire.AppendFloat32(nil, 100000)},
+		{"fb3ff199999999999a", wire.AppendFloat64(nil, 1.1)},
+		{"f4", wire.AppendBool(nil, false)},
+		{"f6", wire.AppendNil(nil)},
+		{"f7", wire.AppendNil(nil)},
+		{"f0", nil},
+		{"4401020304", wire.AppendBytes(nil, []byte{1, 2, 3, 4})},
+		{"62c3bc", wire.AppendString(nil, "ü")},
+		{"5f42010243030405ff", wire.AppendBytes(nil, []byte{1, 2, 3, 4, 5})},
+		{"7f657374726561646d696e67ff", wire.AppendString(nil, "streaming")},
+		{"83010203", wire.AppendUint(wire.AppendUint(wire.AppendUint(wire.AppendArrayHeader(nil, 3), 1), 2), 3)},
+		{"9f018202039f0405ffff", mustMarshal(t, []interface{}{1, []interface{}{2, 3}, []interface{}{4, 5}})},
+		{"bf61610161629f0203ffff", mapOf(wire.AppendString(nil, "a"), wire.AppendUint(nil, 1),
+			wire.AppendString(nil, "b"), mustMarshal(t, []interface{}{2, 3}))},
+		{"a201020304", mapOf(wire.AppendUint(nil, 1), wire.AppendUint(nil, 2), wire.AppendUint(nil, 3), wire.AppendUint(nil, 4))},
+		{"c074323031332d30332d32315432303a30343a30305a", wire.AppendTime(nil, time.Unix(1363896240, 0))},
+		{"c11a514b67b0", wire.AppendTime(nil, time.Unix(1363896240, 0))},
+		{"c1fb41d452d9ec200000", wire.AppendTime(nil, time.Unix(1363896240, 5e8))},
+		{"d9d9f7f5", wire.AppendBool(nil, true)},
+		{"d82076687474703a2f2f7777772e6578616d706c652e636f6d", nil},
+		{"0001", append(wire.AppendUint(nil, 0), wire.AppendUint(nil, 1)...)},
+	} {
+		data, _ := hex.DecodeString(c.cbor)
+		d, err := FromCBOR(data, CBOROptions{})
+		if c.expected == nil {
+			if err == nil {
+				t.Errorf("%s: error must occur", c.cbor)
+			}
+			continue
+		}
+		if err != nil || !bytes.Equal(d, c.expected) {
+			t.Errorf("%s: % x, %v, expected % x", c.cbor, d, err, c.expected)
+		}
+	}
+}
+
+// mapOf returns a map of the encoded keys and values in the given order
+func mapOf(kv ...[]byte) []byte {
+	d := wire.AppendMapHeader(nil, len(kv)/2)
+	for _, b := range kv {
+		d = append(d, b...)
+	}
+	return d
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	d, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestCBORTags(t *testing.T) {
+	// tag 37 UUID and tag 32 URI, whose content is a text string
+	data, _ := hex.DecodeString("82d82550000102030405060708090a0b0c0d0e0fd82072687474703a2f2f6578616d706c652e636f6d")
+	opts := CBOROptions{Tags: map[uint64]int8{37: 1, 32: 2}}
+
+	d, err := FromCBOR(data, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, b, _ := wire.ReadArrayHeader(d)
+	typ, payload, b, err := wire.ReadExt(b)
+	if n != 2 || err != nil || typ != 1 || len(payload) != 17 || payload[0] != 0x50 {
+		t.Errorf("unexpected ext %d % x, %v", typ, payload, err)
+	}
+	if typ, _, _, err = wire.ReadExt(b); err != nil || typ != 2 {
+		t.Errorf("unexpected ext %d, %v", typ, err)
+	}
+
+	back, err := ToCBOR(d, opts)
+	if err != nil || !bytes.Equal(back, data) {
+		t.Errorf("round trip % x, %v", back, err)
+	}
+
+	if _, err = ToCBOR(d, CBOROptions{}); err == nil || !strings.Contains(err.Error(), "ext type 1 has no tag") {
+		t.Error("error must occur for unmapped ext", err)
+	}
+	if _, err = ToCBOR(d, CBOROptions{Tags: map[uint64]int8{37: 1, 38: 1}}); err == nil {
+		t.Error("error must occur for tags sharing an ext type")
+	}
+	if _, err = ToCBOR(wire.AppendExt(nil, 1, []byte{0x82, 0x01}), opts); err == nil {
+		t.Error("error must occur for a payload which is no data item")
+	}
+}
+
+func TestToCBOR(t *testing.T) {
+	var d []byte
+	d = wire.AppendMapHeader(d, 2)
+	d = wire.AppendString(d, "at")
+	d = wire.AppendTime(d, time.Unix(1363896240, 0))
+	d = wire.AppendString(d, "at_ns")
+	d = wire.AppendTime(d, time.Unix(1363896240, 5))
+	d = wire.AppendArrayHeader(d, 6)
+	d = wire.AppendInt(d, -1)
+	d = wire.AppendUint(d, 1000)
+	d = wire.AppendFloat32(d, 1.5)
+	d = wire.AppendFloat64(d, 1.1)
+	d = wire.AppendBytes(d, []byte{1, 2})
+	d = wire.AppendNil(d)
+
+	c, err := ToCBOR(d, CBOROptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "a2" + "626174" + "c11a514b67b0" +
+		"6561745f6e73" + "c0781e323031332d30332d32315432303a30343a30302e3030303030303030355a" +
+		"86" + "20" + "1903e8" + "fa3fc00000" + "fb3ff199999999999a" + "420102" + "f6"
+	if hex.EncodeToString(c) != expected {
+		t.Errorf("unexpected CBOR\n%x\n%s", c, expected)
+	}
+
+	back, err := FromCBOR(c, CBOROptions{})
+	if err != nil || !bytes.Equal(back, d) {
+		t.Errorf("round trip % x, %v\nexpected % x", back, err, d)
+	}
+
+	for _, bad := range [][]byte{{0xc1}, wire.AppendExt(nil, 3, []byte{1}), {0x92, 0x01}} {
+		if _, err := ToCBOR(bad, CBOROptions{}); err == nil {
+			t.Errorf("% x: error must occur", bad)
+		}
+	}
+}
+
+func TestCBORUnmarshal(t *testing.T) {
+	// {"raw": h'0102', "at": 1(1363896240), "n": 1}
+	data, _ := hex.DecodeString("a3" + "63726177420102" + "626174c11a514b67b0" + "616e01")
+	d, err := FromCBOR(data, CBOROptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Unix(1363896240, 0).UTC()
+
+	var full struct {
+		Raw []byte    `json:"raw"`
+		At  time.Time `json:"at"`
+		N   int       `json:"n"`
+	}
+	if err = Unmarshal(d, &full); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(full.Raw, []byte{1, 2}) || !full.At.Equal(at) || full.N != 1 {
+		t.Errorf("unexpected value %+v", full)
+	}
+
+	// bin and timestamp are skipped
+	var partial struct {
+		N int `json:"n"`
+	}
+	if err = Unmarshal(d, &partial); err != nil || partial.N != 1 {
+		t.Errorf("unexpected value %+v, %v", partial, err)
+	}
+
+	var generic map[string]interface{}
+	if err = Unmarshal(d, &generic); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(generic["raw"], []byte{1, 2}) || generic["at"] != at {
+		t.Errorf("unexpected value %#v", generic)
+	}
+
+	// ext types other than timestamp can not be decoded, invalid codes not skipped
+	var v interface{}
+	if err = Unmarshal(wire.AppendExt(nil, 1, []byte{1}), &v); err == nil {
+		t.Error("error must occur for ext type 1")
+	}
+	if err = Unmarshal([]byte{0x81, 0xa1, 'x', 0xc1}, &partial); err == nil {
+		t.Error("error must occur skipping an invalid code")
+	}
+}
+
+func TestCBORMarshal(t *testing.T) {
+	type inner struct {
+		Tags []string          `json:"tags"`
+		Raw  []byte            `json:"raw"`
+		Attr map[string]uint16 `json:"attr"`
+	}
+	type record struct {
+		Name    string `json:"name"`
+		Count   int8   `json:"count"`
+		Rate    float32
+		At      time.Time `json:"at"`
+		Inner   *inner    `json:"inner"`
+		Skipped string    `json:"-"`
+		private int
+		Any     interface{} `json:"any"`
+	}
+	v := record{
+		Name: "a", Count: -3, Rate: 0.5, At: time.Unix(1363896240, 0).UTC(),
+		Inner:   &inner{Tags: []string{"x"}, Raw: []byte{1}, Attr: map[string]uint16{"b": 2, "a": 1}},
+		Skipped: "s", private: 1,
+		Any: []interface{}{uint64(1), "s", cbor.Tag{Number: 37, Content: []byte{1}}},
+	}
+
+	d, err := cbor.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out record
+	if err = cbor.Unmarshal(d, &out); err != nil {
+		t.Fatal(err)
+	}
+	v.Skipped, v.private = "", 0
+	if !reflect.DeepEqual(out, v) {
+		t.Errorf("round trip\n%+v\nexpected\n%+v", out, v)
+	}
+
+	// the same field names as MessagePack, map keys are sorted
+	type plain struct {
+		Name string          `json:"name"`
+		On   bool            `json:"on"`
+		Skip bool            `json:"-"`
+		Set  map[string]bool `json:"set"`
+	}
+	p := plain{Name: "n", On: true, Set: map[string]bool{"a": true}}
+	d, err = cbor.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := FromCBOR(d, CBOROptions{})
+	if err != nil || !bytes.Equal(m, mustMarshal(t, p)) {
+		t.Errorf("% x, %v\nexpected % x", m, err, mustMarshal(t, p))
+	}
+
+	var generic interface{}
+	if err = cbor.Unmarshal([]byte{0xa1, 0x01, 0x9f, 0x20, 0xf9, 0x3c, 0x00, 0xff}, &generic); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(generic, map[interface{}]interface{}{uint64(1): []interface{}{int64(-1), float32(1)}}) {
+		t.Errorf("unexpected value %#v", generic)
+	}
+
+	var small struct {
+		N int8 `json:"n"`
+	}
+	for _, bad := range []string{"a1616e190100", "a1616e", "a1616e01ff", "1c", "ff"} {
+		data, _ := hex.DecodeString(bad)
+		if err := cbor.Unmarshal(data, &small); err == nil {
+			t.Errorf("%s: error must occur", bad)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+// Package csv converts CSV with a header row to a MessagePack array of records
+// and flattens such arrays back to CSV. Column names of nested maps are the
+// dotted paths of their keys, e.g. "address.city".
+package csv
+
+import (
+	"bytes"
+	stdcsv "encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/romanzac/json-mp/mp/def"
+	"github.com/romanzac/json-mp/mp/stream"
+	"github.com/romanzac/json-mp/mp/wire"
+)
+
+// Options controls ToMsgpack
+type Options struct {
+	// Arrays writes every record as an array of its cells in column order
+	// instead of a map keyed by column name
+	Arrays bool
+	// Shape is a struct type giving the column types. Columns are matched to
+	// the dotted field names of def.CheckStructField, columns of fields which
+	// are not bool, numbers or strings keep the inferred types.
+	// Without a shape the type of every cell is inferred.
+	Shape reflect.Type
+}
+
+// column is a node of the tree of dotted column names, leaves have a column
+// index and branches children
+type column struct {
+	name     string
+	index    int
+	typ      reflect.Type
+	children []*column
+}
+
+// ToMsgpack reads CSV with a header row from r and returns a MessagePack array
+// with one record per row. Inferred cells are nil when empty, bool for true
+// and false, int or uint for integers without leading zeros, float64 for other
+// numbers and str otherwise.
+func ToMsgpack(r io.Reader, opts Options) ([]byte, error) {
+	cr := stdcsv.NewReader(r)
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("missing header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var types map[string]reflect.Type
+	if opts.Shape != nil {
+		if opts.Shape.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("shape %v is not a struct", opts.Shape)
+		}
+		types = map[string]reflect.Type{}
+		fieldTypes(opts.Shape, "", types)
+	}
+
+	leaves := make([]*column, len(header))
+	root := &column{index: -1}
+	for i, name := range header {
+		if types != nil {
+			t, ok := types[name]
+			if !ok {
+				return nil, fmt.Errorf("column %q is not in the shape", name)
+			}
+			leaves[i] = &column{name: name, index: i, typ: t}
+		} else {
+			leaves[i] = &column{name: name, index: i}
+		}
+		if !opts.Arrays {
+			if err = root.add(strings.Split(name, "."), leaves[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var body []byte
+	rows := 0
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows++
+		if opts.Arrays {
+			body = wire.AppendArrayHeader(body, len(leaves))
+			for _, c := range leaves {
+				if body, err = appendCell(body, cr, record, c); err != nil {
+					return nil, err
+				}
+			}
+		} else if body, err = root.appendMap(body, cr, record); err != nil {
+			return nil, err
+		}
+	}
+
+	if int64(rows) > math.MaxUint32 {
+		return nil, fmt.Errorf("%d rows exceed MessagePack limits", rows)
+	}
+	return append(wire.AppendArrayHeader(nil, rows), body...), nil
+}
+
+// fieldTypes adds the types of the fields of struct type t by dotted name
+func fieldTypes(t reflect.Type, prefix string, types map[string]reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		ok, name := def.CheckStructField(t.Field(i))
+		if !ok {
+			continue
+		}
+		ft := t.Field(i).Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && ft != reflect.TypeOf(time.Time{}) {
+			fieldTypes(ft, prefix+name+".", types)
+			continue
+		}
+		types[prefix+name] = ft
+	}
+}
+
+// add places leaf at path below c
+func (c *column) add(path []string, leaf *column) error {
+	for _, child := range c.children {
+		if child.name != path[0] {
+			continue
+		}
+		if len(path) == 1 || child.index >= 0 {
+			return fmt.Errorf("column %q conflicts with column %q", leaf.name, columnName(child))
+		}
+		return child.add(path[1:], leaf)
+	}
+	if len(path) == 1 {
+		c.children = append(c.children, &column{name: path[0], index: leaf.index, typ: leaf.typ})
+		return nil
+	}
+	branch := &column{name: path[0], index: -1}
+	c.children = append(c.children, branch)
+	return branch.add(path[1:], leaf)
+}
+
+// columnName returns the name of the first column below or at c
+func columnName(c *column) string {
+	if c.index >= 0 {
+		return c.name
+	}
+	return c.name + "." + columnName(c.children[0])
+}
+
+// appendMap appends the cells of record below branch c as a map
+func (c *column) appendMap(b []byte, cr *stdcsv.Reader, record []string) ([]byte, error) {
+	b = wire.AppendMapHeader(b, len(c.children))
+	for _, child := range c.children {
+		b = wire.AppendString(b, child.name)
+		var err error
+		if child.index >= 0 {
+			b, err = appendCell(b, cr, record, child)
+		} else {
+			b, err = child.appendMap(b, cr, record)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// appendCell appends the cell of record at column c, converted to the type of
+// c or inferred
+func appendCell(b []byte, cr *stdcsv.Reader, record []string, c *column) ([]byte, error) {
+	s := record[c.index]
+	if c.typ == nil {
+		return appendInferred(b, s), nil
+	}
+
+	kind := c.typ.Kind()
+	if kind == reflect.String {
+		return wire.AppendString(b, s), nil
+	}
+	if s == "" {
+		return wire.AppendNil(b), nil
+	}
+	var err error
+	switch kind {
+	case reflect.Bool:
+		var v bool
+		if v, err = strconv.ParseBool(s); err == nil {
+			return wire.AppendBool(b, v), nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var v int64
+		if v, err = strconv.ParseInt(s, 10, c.typ.Bits()); err == nil {
+			return wire.AppendInt(b, v), nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var v uint64
+		if v, err = strconv.ParseUint(s, 10, c.typ.Bits()); err == nil {
+			return wire.AppendUint(b, v), nil
+		}
+	case reflect.Float32:
+		var v float64
+		if v, err = strconv.ParseFloat(s, 32); err == nil {
+			return wire.AppendFloat32(b, float32(v)), nil
+		}
+	case reflect.Float64:
+		var v float64
+		if v, err = strconv.ParseFloat(s, 64); err == nil {
+			return wire.AppendFloat64(b, v), nil
+		}
+	default:
+		return appendInferred(b, s), nil
+	}
+	line, _ := cr.FieldPos(c.index)
+	return nil, fmt.Errorf("line %d, column %d: %v", line, c.index+1, err)
+}
+
+// appendInferred appends s as the type it looks like, see ToMsgpack
+func appendInferred(b []byte, s string) []byte {
+	switch s {
+	case "":
+		return wire.AppendNil(b)
+	case "true":
+		return wire.AppendBool(b, true)
+	case "false":
+		return wire.AppendBool(b, false)
+	}
+	if !isNumber(s) {
+		return wire.AppendString(b, s)
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return wire.AppendInt(b, i)
+	}
+	if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return wire.AppendUint(b, u)
+	}
+	// integers beyond 64 bits are kept rather than rounded
+	if f, err := strconv.ParseFloat(s, 64); err == nil && strings.ContainsAny(s, ".eE") {
+		return wire.AppendFloat64(b, f)
+	}
+	return wire.AppendString(b, s)
+}
+
+// isNumber reports whether s starts like a decimal number without leading
+// zeros, so codes like "007" stay strings
+func isNumber(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	if s == "" || s[0] < '0' || s[0] > '9' {
+		return false
+	}
+	return s[0] != '0' || len(s) == 1 || s[1] == '.' || s[1] == 'e' || s[1] == 'E'
+}
+
+// FromMsgpack writes an array of records in data to w as CSV. Records which
+// are maps are flattened with dotted column names, the columns are the keys of
+// all records in order of appearance and the header row is written first.
+// Records which are arrays are written as rows without a header. Cells of
+// absent keys and nil are empty, arrays are JSON and timestamps RFC 3339.
+func FromMsgpack(w io.Writer, data []byte) error {
+	n, b, err := wire.ReadArrayHeader(data)
+	if err != nil {
+		return fmt.Errorf("records must be an array: %v", err)
+	}
+
+	cw := stdcsv.NewWriter(w)
+	if n > 0 && wire.NextType(b) == wire.ArrayType {
+		for i := 0; i < n; i++ {
+			var l int
+			if l, b, err = wire.ReadArrayHeader(b); err != nil {
+				return fmt.Errorf("record %d: %v", i, err)
+			}
+			row := make([]string, l)
+			for j := range row {
+				if row[j], b, err = cell(b); err != nil {
+					return fmt.Errorf("record %d: %v", i, err)
+				}
+			}
+			cw.Write(row)
+		}
+	} else {
+		var columns []string
+		index := map[string]int{}
+		records := make([]map[string]string, n)
+		for i := range records {
+			records[i] = map[string]string{}
+			if b, err = flatten(b, "", records[i], &columns, index); err != nil {
+				return fmt.Errorf("record %d: %v", i, err)
+			}
+		}
+		cw.Write(columns)
+		row := make([]string, len(columns))
+		for _, r := range records {
+			for j, name := range columns {
+				row[j] = r[name]
+			}
+			cw.Write(row)
+		}
+	}
+	if len(b) > 0 {
+		return wire.ErrTrailingBytes
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// flatten adds the cells of the map at the start of b to record, keyed by
+// their dotted names, and appends names seen for the first time to columns
+func flatten(b []byte, prefix string, record map[string]string, columns *[]string, index map[string]int) ([]byte, error) {
+	n, b, err := wire.ReadMapHeader(b)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < n; i++ {
+		var key string
+		if key, b, err = cell(b); err != nil {
+			return nil, err
+		}
+		name := prefix + key
+		if wire.NextType(b) == wire.MapType {
+			if b, err = flatten(b, name+".", record, columns, index); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if _, ok := record[name]; ok {
+			return nil, fmt.Errorf("column %q occurs twice", name)
+		}
+		if record[name], b, err = cell(b); err != nil {
+			return nil, err
+		}
+		if _, ok := index[name]; !ok {
+			index[name] = len(*columns)
+			*columns = append(*columns, name)
+		}
+	}
+	return b, nil
+}
+
+// cell formats the value at the start of b as CSV cell
+func cell(b []byte) (string, []byte, error) {
+	switch wire.NextType(b) {
+	case wire.NilType:
+		rest, err := wire.ReadNil(b)
+		return "", rest, err
+	case wire.StrType:
+		return wire.ReadString(b)
+	case wire.ExtType:
+		if typ, _, _, err := wire.ReadExt(b); err == nil && typ == def.TimestampExt {
+			t, rest, err := wire.ReadTime(b)
+			if err != nil {
+				return "", nil, err
+			}
+			return t.Format(time.RFC3339Nano), rest, nil
+		}
+	}
+
+	raw, rest, err := wire.ReadRaw(b)
+	if err != nil {
+		return "", nil, err
+	}
+	var buf bytes.Buffer
+	if err = stream.ToJSON(&buf, bytes.NewReader(raw), stream.JSONOptions{NonFinite: stream.NonFiniteString}); err != nil {
+		return "", nil, err
+	}
+	s := strings.TrimSuffix(buf.String(), "\n")
+	// bin and non-finite floats are JSON strings
+	if u, err := strconv.Unquote(s); err == nil {
+		return u, rest, nil
+	}
+	return s, rest, nil
+}
@@ -0,0 +1,155 @@
+package mp
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/romanzac/json-mp/mp/wire"
+)
+
+func TestFromCSV(t *testing.T) {
+	in := "id,name,address.city,address.zip,score,big,active\n" +
+		"1,Ann,Brno,00601,9.5,18446744073709551615,true\n" +
+		"-2,\"Bob, Jr.\",Praha,,,123456789012345678901234,false\n"
+
+	d, err := FromCSV(strings.NewReader(in), CSVOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []*OrderedMap
+	if err = UnmarshalWithOptions(d, &records, UnmarshalOptions{OrderedMaps: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("%d records", len(records))
+	}
+	keys := records[0].Keys()
+	if !reflect.DeepEqual(keys, []interface{}{"id", "name", "address", "score", "big", "active"}) {
+		t.Errorf("unexpected keys %v", keys)
+	}
+	addr, _ := records[0].Get("address")
+	zip, _ := addr.(*OrderedMap).Get("zip")
+	score, _ := records[1].Get("score")
+	big, _ := records[1].Get("big")
+	id, _ := records[1].Get("id")
+	if zip != "00601" || score != nil || big != "123456789012345678901234" || fmt.Sprint(id) != "-2" {
+		t.Errorf("unexpected cells %#v %#v %#v %#v", zip, score, big, id)
+	}
+
+	// CSV of the records is the input
+	var out bytes.Buffer
+	if err = ToCSV(&out, d); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != in {
+		t.Errorf("round trip\n%s\nexpected\n%s", out.String(), in)
+	}
+
+	d, err = FromCSV(strings.NewReader(in), CSVOptions{Arrays: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows [][]interface{}
+	if err = Unmarshal(d, &rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || len(rows[0]) != 7 || rows[0][2] != "Brno" {
+		t.Errorf("unexpected rows %v", rows)
+	}
+	out.Reset()
+	if err = ToCSV(&out, d); err != nil || out.String() != in[strings.IndexByte(in, '\n')+1:] {
+		t.Errorf("rows without header\n%s, %v", out.String(), err)
+	}
+}
+
+func TestFromCSVShape(t *testing.T) {
+	type record struct {
+		ID   uint8 `json:"id"`
+		Code string
+		Geo  struct {
+			Lat float32 `json:"lat"`
+		} `json:"geo"`
+		On bool `json:"on"`
+	}
+	opts := CSVOptions{Shape: reflect.TypeOf(record{})}
+
+	d, err := FromCSV(strings.NewReader("id,Code,geo.lat,on\n7,007,1.5,1\n"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []record
+	if err = Unmarshal(d, &records); err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].ID != 7 || records[0].Code != "007" || records[0].Geo.Lat != 1.5 || !records[0].On {
+		t.Errorf("unexpected records %+v", records)
+	}
+	// sizes follow the shape
+	if !bytes.Contains(d, wire.AppendFloat32(nil, 1.5)) {
+		t.Errorf("float32 expected in % x", d)
+	}
+
+	for _, c := range []struct {
+		in, err string
+	}{
+		{"id,other\n1,2\n", `column "other" is not in the shape`},
+		{"id\n300\n", "line 2, column 1"},
+		{"id,on\n1\n", "wrong number of fields"},
+	} {
+		if _, err := FromCSV(strings.NewReader(c.in), opts); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%q: error %v, expected %s", c.in, err, c.err)
+		}
+	}
+	if _, err := FromCSV(strings.NewReader("a,a.b\n1,2\n"), CSVOptions{}); err == nil {
+		t.Error("error must occur for conflicting columns")
+	}
+	if _, err := FromCSV(strings.NewReader(""), CSVOptions{}); err == nil {
+		t.Error("error must occur without header")
+	}
+}
+
+func TestToCSV(t *testing.T) {
+	var d []byte
+	d = wire.AppendArrayHeader(d, 2)
+	d = wire.AppendMapHeader(d, 3)
+	d = wire.AppendString(d, "a")
+	d = wire.AppendTime(d, time.Unix(0, 5).UTC())
+	d = wire.AppendString(d, "tags")
+	d = wire.AppendArrayHeader(d, 2)
+	d = wire.AppendString(d, "x")
+	d = wire.AppendInt(d, 1)
+	d = wire.AppendString(d, "raw")
+	d = wire.AppendBytes(d, []byte{1, 2})
+	d = wire.AppendMapHeader(d, 2)
+	d = wire.AppendString(d, "b")
+	d = wire.AppendMapHeader(d, 1)
+	d = wire.AppendUint(d, 1)
+	d = wire.AppendFloat32(d, 0.1)
+	d = wire.AppendString(d, "a")
+	d = wire.AppendNil(d)
+
+	var out bytes.Buffer
+	if err := ToCSV(&out, d); err != nil {
+		t.Fatal(err)
+	}
+	expected := "a,tags,raw,b.1\n" +
+		"1970-01-01T00:00:00.000000005Z,\"[\"\"x\"\",1]\",AQI=,\n" +
+		",,,0.1\n"
+	if out.String() != expected {
+		t.Errorf("unexpected CSV\n%s\nexpected\n%s", out.String(), expected)
+	}
+
+	for _, bad := range [][]byte{
+		wire.AppendMapHeader(nil, 0),
+		append(wire.AppendArrayHeader(nil, 1), wire.AppendUint(nil, 1)...),
+		append(wire.AppendArrayHeader(nil, 1), 0x82, 0xa1, 'a', 0x01, 0xa1, 'a', 0x02),
+	} {
+		if err := ToCSV(&out, bad); err == nil {
+			t.Errorf("% x: error must occur", bad)
+		}
+	}
+}
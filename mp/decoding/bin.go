@@ -0,0 +1,31 @@
+package decoding
+
+import (
+	"reflect"
+
+	"github.com/romanzac/json-mp/mp/def"
+	"github.com/romanzac/json-mp/mp/wire"
+)
+
+func (d *decoder) isCodeBin(v byte) bool {
+	return def.Bin8 <= v && v <= def.Bin32
+}
+
+// asBin reads a bin payload, which is copied unless ZeroCopy is set
+func (d *decoder) asBin(offset int, k reflect.Kind) ([]byte, int, error) {
+	code, _, err := d.readSize1(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !d.isCodeBin(code) {
+		return nil, 0, d.errorTemplate(code, k)
+	}
+	bs, rest, err := wire.ReadBytes(d.data[offset:])
+	if err != nil {
+		return nil, 0, err
+	}
+	if !d.opts.ZeroCopy {
+		bs = append(make([]byte, 0, len(bs)), bs...)
+	}
+	return bs, len(d.data) - len(rest), nil
+}
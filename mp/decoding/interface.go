@@ -25,68 +25,32 @@ func (d *decoder) asInterface(offset int, k reflect.Kind) (interface{}, int, err
 		}
 		return v, offset, nil
 
-	case d.isPositiveFixNum(code), code == def.Uint8:
+	case d.isPositiveFixNum(code), code == def.Uint8, code == def.Uint16, code == def.Uint32, code == def.Uint64:
 		v, offset, err := d.asUint(offset, k)
 		if err != nil {
 			return nil, 0, err
 		}
-		return uint8(v), offset, err
-	case code == def.Uint16:
-		v, offset, err := d.asUint(offset, k)
-		if err != nil {
-			return nil, 0, err
-		}
-		return uint16(v), offset, err
-	case code == def.Uint32:
-		v, offset, err := d.asUint(offset, k)
-		if err != nil {
-			return nil, 0, err
-		}
-		return uint32(v), offset, err
-	case code == def.Uint64:
-		v, offset, err := d.asUint(offset, k)
-		if err != nil {
-			return nil, 0, err
-		}
-		return v, offset, err
+		return d.uintValue(code, v), offset, nil
 
-	case d.isNegativeFixNum(code), code == def.Int8:
-		v, offset, err := d.asInt(offset, k)
-		if err != nil {
-			return nil, 0, err
-		}
-		return int8(v), offset, err
-	case code == def.Int16:
-		v, offset, err := d.asInt(offset, k)
-		if err != nil {
-			return nil, 0, err
-		}
-		return int16(v), offset, err
-	case code == def.Int32:
+	case d.isNegativeFixNum(code), code == def.Int8, code == def.Int16, code == def.Int32, code == def.Int64:
 		v, offset, err := d.asInt(offset, k)
 		if err != nil {
 			return nil, 0, err
 		}
-		return int32(v), offset, err
-	case code == def.Int64:
-		v, offset, err := d.asInt(offset, k)
-		if err != nil {
-			return nil, 0, err
-		}
-		return v, offset, err
+		return d.intValue(code, v), offset, nil
 
 	case code == def.Float32:
 		v, offset, err := d.asFloat32(offset, k)
 		if err != nil {
 			return nil, 0, err
 		}
-		return v, offset, err
+		return d.floatValue(code, float64(v)), offset, nil
 	case code == def.Float64:
 		v, offset, err := d.asFloat64(offset, k)
 		if err != nil {
 			return nil, 0, err
 		}
-		return v, offset, err
+		return d.floatValue(code, v), offset, nil
 
 	case d.isFixString(code), code == def.Str8, code == def.Str16, code == def.Str32:
 		v, offset, err := d.asString(offset, k)
@@ -95,6 +59,20 @@ func (d *decoder) asInterface(offset int, k reflect.Kind) (interface{}, int, err
 		}
 		return v, offset, err
 
+	case d.isCodeBin(code):
+		v, offset, err := d.asBin(offset, k)
+		if err != nil {
+			return nil, 0, err
+		}
+		return v, offset, nil
+
+	case d.isCodeExt(code):
+		v, offset, err := d.asTime(offset, k)
+		if err != nil {
+			return nil, 0, err
+		}
+		return v, offset, nil
+
 	case d.isFixSlice(code), code == def.Array16, code == def.Array32:
 		l, o, err := d.sliceLength(offset, k)
 		if err != nil {
@@ -125,9 +103,17 @@ func (d *decoder) asInterface(offset int, k reflect.Kind) (interface{}, int, err
 		if err = d.hasRequiredLeastMapSize(o, l); err != nil {
 			return nil, 0, err
 		}
-		v := make(map[interface{}]interface{}, l)
+		var (
+			v  map[interface{}]interface{}
+			om *OrderedMap
+		)
+		if d.opts.OrderedMaps {
+			om = NewOrderedMap(l)
+		} else {
+			v = make(map[interface{}]interface{}, l)
+		}
 		for i := 0; i < l; i++ {
-			if d.canSetAsMapKey(o) != nil {
+			if err = d.canSetAsMapKey(o); err != nil {
 				return nil, 0, err
 			}
 			key, o2, err := d.asInterface(o, k)
@@ -138,10 +124,17 @@ func (d *decoder) asInterface(offset int, k reflect.Kind) (interface{}, int, err
 			if err != nil {
 				return nil, 0, err
 			}
-			v[key] = value
+			if om != nil {
+				om.Set(key, value)
+			} else {
+				v[key] = value
+			}
 			o = o2
 		}
 		offset = o
+		if om != nil {
+			return om, offset, nil
+		}
 		return v, offset, nil
 	}
 
@@ -1,11 +1,11 @@
 package decoding
 
 import (
-	"encoding/binary"
 	"errors"
 	"reflect"
 
 	"github.com/romanzac/json-mp/mp/def"
+	"github.com/romanzac/json-mp/mp/wire"
 )
 
 var (
@@ -33,29 +33,18 @@ func (d *decoder) isFixMap(v byte) bool {
 }
 
 func (d *decoder) mapLength(offset int, k reflect.Kind) (int, int, error) {
-	code, offset, err := d.readSize1(offset)
+	code, _, err := d.readSize1(offset)
 	if err != nil {
 		return 0, 0, err
 	}
-
-	switch {
-	case d.isFixMap(code):
-		return int(code - def.FixMap), offset, nil
-	case code == def.Map16:
-		bs, offset, err := d.readSize2(offset)
-		if err != nil {
-			return 0, 0, err
-		}
-		return int(binary.BigEndian.Uint16(bs)), offset, nil
-	case code == def.Map32:
-		bs, offset, err := d.readSize4(offset)
-		if err != nil {
-			return 0, 0, err
-		}
-		return int(binary.BigEndian.Uint32(bs)), offset, nil
+	if !d.isFixMap(code) && code != def.Map16 && code != def.Map32 {
+		return 0, 0, d.errorTemplate(code, k)
 	}
-
-	return 0, 0, d.errorTemplate(code, k)
+	l, rest, err := wire.ReadMapHeader(d.data[offset:])
+	if err != nil {
+		return 0, 0, err
+	}
+	return l, len(d.data) - len(rest), nil
 }
 
 func (d *decoder) hasRequiredLeastMapSize(offset, length int) error {
@@ -0,0 +1,218 @@
+package decoding
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/romanzac/json-mp/mp/def"
+	"github.com/romanzac/json-mp/mp/wire"
+)
+
+// NumberMode selects the Go types numbers decode to in interface values
+type NumberMode int
+
+const (
+	// NumbersAsWire decodes to the type of the wire width, e.g. uint8, int16
+	// or float32. It is the default.
+	NumbersAsWire NumberMode = iota
+	// NumbersNormalized decodes integers to int64, or uint64 above
+	// MaxInt64, and floats to float64
+	NumbersNormalized
+	// NumbersAsNumber decodes to Number, which keeps the wire format
+	NumbersAsNumber
+)
+
+// Number is a MessagePack int, uint or float together with its format code,
+// so encoding it writes the same bytes it was decoded from. Like
+// encoding/json's Number it can also be used as a struct field.
+type Number struct {
+	code byte
+	bits uint64
+}
+
+// Code returns the format code, e.g. def.Uint16. For fixints the code is the value.
+func (n Number) Code() byte {
+	return n.code
+}
+
+// Type returns wire.IntType, wire.UintType or wire.FloatType
+func (n Number) Type() wire.Type {
+	return wire.NextType([]byte{n.code})
+}
+
+// Int64 returns the number as int64, floats and uints above MaxInt64 return an error
+func (n Number) Int64() (int64, error) {
+	switch n.Type() {
+	case wire.IntType:
+		return int64(n.bits), nil
+	case wire.UintType:
+		if n.bits <= math.MaxInt64 {
+			return int64(n.bits), nil
+		}
+	}
+	return 0, fmt.Errorf("number %s is not an int64", n)
+}
+
+// Uint64 returns the number as uint64, floats and negative ints return an error
+func (n Number) Uint64() (uint64, error) {
+	switch n.Type() {
+	case wire.UintType:
+		return n.bits, nil
+	case wire.IntType:
+		if int64(n.bits) >= 0 {
+			return n.bits, nil
+		}
+	}
+	return 0, fmt.Errorf("number %s is not an uint64", n)
+}
+
+// Float64 returns the number as float64, large integers are rounded
+func (n Number) Float64() float64 {
+	switch n.Type() {
+	case wire.IntType:
+		return float64(int64(n.bits))
+	case wire.UintType:
+		return float64(n.bits)
+	}
+	return math.Float64frombits(n.bits)
+}
+
+// String formats the number, float 32 with the shortest float32 representation
+func (n Number) String() string {
+	switch n.Type() {
+	case wire.IntType:
+		return strconv.FormatInt(int64(n.bits), 10)
+	case wire.UintType:
+		return strconv.FormatUint(n.bits, 10)
+	}
+	bitSize := 64
+	if n.code == def.Float32 {
+		bitSize = 32
+	}
+	return strconv.FormatFloat(math.Float64frombits(n.bits), 'g', -1, bitSize)
+}
+
+// MarshalJSON writes the number as a JSON number, NaN and infinities return an error
+func (n Number) MarshalJSON() ([]byte, error) {
+	if f := n.Float64(); math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("unsupported number %s", n)
+	}
+	return []byte(n.String()), nil
+}
+
+// AppendMsgpack appends the number in its original format
+func (n Number) AppendMsgpack(b []byte) ([]byte, error) {
+	switch n.code {
+	case def.Uint8:
+		return wire.AppendUint8(b, uint8(n.bits)), nil
+	case def.Uint16:
+		return wire.AppendUint16(b, uint16(n.bits)), nil
+	case def.Uint32:
+		return wire.AppendUint32(b, uint32(n.bits)), nil
+	case def.Uint64:
+		return wire.AppendUint64(b, n.bits), nil
+	case def.Int8:
+		return wire.AppendInt8(b, int8(n.bits)), nil
+	case def.Int16:
+		return wire.AppendInt16(b, int16(n.bits)), nil
+	case def.Int32:
+		return wire.AppendInt32(b, int32(n.bits)), nil
+	case def.Int64:
+		return wire.AppendInt64(b, int64(n.bits)), nil
+	case def.Float32:
+		return wire.AppendFloat32(b, float32(math.Float64frombits(n.bits))), nil
+	case def.Float64:
+		return wire.AppendFloat64(b, math.Float64frombits(n.bits)), nil
+	}
+	// fixint
+	return append(b, n.code), nil
+}
+
+// UnmarshalMsgpack reads any int, uint or float format
+func (n *Number) UnmarshalMsgpack(b []byte) error {
+	if len(b) < def.Byte1 {
+		return fmt.Errorf("empty data decoding number")
+	}
+	var rest []byte
+	var err error
+	code := b[0]
+	switch wire.NextType(b) {
+	case wire.UintType:
+		var v uint64
+		v, rest, err = wire.ReadUint(b)
+		*n = Number{code: code, bits: v}
+	case wire.IntType:
+		var v int64
+		v, rest, err = wire.ReadInt(b)
+		*n = Number{code: code, bits: uint64(v)}
+	case wire.FloatType:
+		var v float64
+		v, rest, err = wire.ReadFloat64(b)
+		*n = Number{code: code, bits: math.Float64bits(v)}
+	default:
+		return fmt.Errorf("invalid code %x decoding number", code)
+	}
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return wire.ErrTrailingBytes
+	}
+	return nil
+}
+
+// intValue returns v read from format code as set by the number mode
+func (d *decoder) intValue(code byte, v int64) interface{} {
+	switch d.opts.Numbers {
+	case NumbersNormalized:
+		return v
+	case NumbersAsNumber:
+		return Number{code: code, bits: uint64(v)}
+	}
+	switch code {
+	case def.Int16:
+		return int16(v)
+	case def.Int32:
+		return int32(v)
+	case def.Int64:
+		return v
+	}
+	return int8(v)
+}
+
+// uintValue returns v read from format code as set by the number mode
+func (d *decoder) uintValue(code byte, v uint64) interface{} {
+	switch d.opts.Numbers {
+	case NumbersNormalized:
+		if v <= math.MaxInt64 {
+			return int64(v)
+		}
+		return v
+	case NumbersAsNumber:
+		return Number{code: code, bits: v}
+	}
+	switch code {
+	case def.Uint16:
+		return uint16(v)
+	case def.Uint32:
+		return uint32(v)
+	case def.Uint64:
+		return v
+	}
+	return uint8(v)
+}
+
+// floatValue returns v read from format code as set by the number mode
+func (d *decoder) floatValue(code byte, v float64) interface{} {
+	switch d.opts.Numbers {
+	case NumbersNormalized:
+		return v
+	case NumbersAsNumber:
+		return Number{code: code, bits: math.Float64bits(v)}
+	}
+	if code == def.Float32 {
+		return float32(v)
+	}
+	return v
+}
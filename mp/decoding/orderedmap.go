@@ -0,0 +1,183 @@
+package decoding
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/romanzac/json-mp/mp/encoding"
+	"github.com/romanzac/json-mp/mp/wire"
+)
+
+// OrderedMap is a map which keeps its keys in insertion order. It is written
+// to MessagePack and JSON in that order, decoding with the OrderedMaps option
+// or from JSON keeps the order of the source. The zero value is an empty map.
+type OrderedMap struct {
+	keys   []interface{}
+	values map[interface{}]interface{}
+}
+
+// NewOrderedMap returns an empty map with room for n keys
+func NewOrderedMap(n int) *OrderedMap {
+	return &OrderedMap{keys: make([]interface{}, 0, n), values: make(map[interface{}]interface{}, n)}
+}
+
+// Len returns the number of keys
+func (m OrderedMap) Len() int {
+	return len(m.keys)
+}
+
+// Keys returns the keys in order, the slice must not be modified
+func (m OrderedMap) Keys() []interface{} {
+	return m.keys
+}
+
+// Get returns the value of key k
+func (m OrderedMap) Get(k interface{}) (interface{}, bool) {
+	v, ok := m.values[k]
+	return v, ok
+}
+
+// Set adds key k at the end, or replaces the value of k in place
+func (m *OrderedMap) Set(k, v interface{}) {
+	if m.values == nil {
+		m.values = make(map[interface{}]interface{})
+	}
+	if _, ok := m.values[k]; !ok {
+		m.keys = append(m.keys, k)
+	}
+	m.values[k] = v
+}
+
+// Delete removes key k
+func (m *OrderedMap) Delete(k interface{}) {
+	if _, ok := m.values[k]; !ok {
+		return
+	}
+	delete(m.values, k)
+	for i, key := range m.keys {
+		if key == k {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// AppendMsgpack appends the map with keys in order
+func (m OrderedMap) AppendMsgpack(b []byte) ([]byte, error) {
+	b = wire.AppendMapHeader(b, len(m.keys))
+	var err error
+	for _, k := range m.keys {
+		if b, err = encoding.Append(b, k); err != nil {
+			return b, err
+		}
+		if b, err = encoding.Append(b, m.values[k]); err != nil {
+			return b, err
+		}
+	}
+	return b, nil
+}
+
+// UnmarshalMsgpack decodes a map, nested maps become *OrderedMap as well
+func (m *OrderedMap) UnmarshalMsgpack(b []byte) error {
+	var v interface{}
+	if err := DecodeWithOptions(b, &v, Options{OrderedMaps: true}); err != nil {
+		return err
+	}
+	om, ok := v.(*OrderedMap)
+	if !ok {
+		return fmt.Errorf("messagepack value is %T, not a map", v)
+	}
+	*m = *om
+	return nil
+}
+
+// MarshalJSON writes the map as JSON object with keys in order. Keys which
+// are not strings are written in their fmt.Sprint form.
+func (m OrderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		s, ok := k.(string)
+		if !ok {
+			s = fmt.Sprint(k)
+		}
+		key, err := json.Marshal(s)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(m.values[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON reads a JSON object keeping the order of its keys. Nested
+// objects become *OrderedMap and numbers json.Number, so no precision is lost.
+func (m *OrderedMap) UnmarshalJSON(b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	v, err := jsonValue(dec)
+	if err != nil {
+		return err
+	}
+	om, ok := v.(*OrderedMap)
+	if !ok {
+		return fmt.Errorf("json value is %T, not an object", v)
+	}
+	*m = *om
+	return nil
+}
+
+// jsonValue reads the next JSON value from dec
+func jsonValue(dec *json.Decoder) (interface{}, error) {
+	t, err := dec.Token()
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	switch t {
+	case json.Delim('{'):
+		m := &OrderedMap{}
+		for dec.More() {
+			k, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := jsonValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			m.Set(k.(string), v)
+		}
+		_, err = dec.Token()
+		return m, err
+	case json.Delim('['):
+		a := []interface{}{}
+		for dec.More() {
+			v, err := jsonValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		_, err = dec.Token()
+		return a, err
+	case json.Delim('}'), json.Delim(']'):
+		return nil, errors.New("invalid JSON input")
+	}
+	return t, nil
+}
@@ -0,0 +1,370 @@
+package decoding
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Unmarshaler is implemented by types which decode themselves, such as types
+// with methods from json-mp gen. It receives exactly one encoded value.
+type Unmarshaler interface {
+	UnmarshalMsgpack(data []byte) error
+}
+
+var unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+
+// decodeFunc reads a value of the type it was compiled for at offset
+type decodeFunc func(d *decoder, rv reflect.Value, offset int) (int, error)
+
+// Plans are compiled once per type and stored as Map
+var plans = sync.Map{}
+
+func decoderOf(t reflect.Type) decodeFunc {
+	if f, find := plans.Load(t); find {
+		return f.(decodeFunc)
+	}
+
+	// Recursive types get an indirect func which waits for the plan compiled below
+	var (
+		wg sync.WaitGroup
+		f  decodeFunc
+	)
+	wg.Add(1)
+	fi, loaded := plans.LoadOrStore(t, decodeFunc(func(d *decoder, rv reflect.Value, offset int) (int, error) {
+		wg.Wait()
+		return f(d, rv, offset)
+	}))
+	if loaded {
+		return fi.(decodeFunc)
+	}
+	f = compileDecoder(t)
+	wg.Done()
+	plans.Store(t, f)
+	return f
+}
+
+func compileDecoder(t reflect.Type) decodeFunc {
+	k := t.Kind()
+	if k != reflect.Pointer && k != reflect.Interface && reflect.PointerTo(t).Implements(unmarshalerType) {
+		return decodeUnmarshaler
+	}
+	if t == timeType {
+		return decodeTime
+	}
+
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(d *decoder, rv reflect.Value, offset int) (int, error) {
+			v, o, err := d.asInt(offset, k)
+			if err != nil {
+				return 0, err
+			}
+			rv.SetInt(v)
+			return o, nil
+		}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(d *decoder, rv reflect.Value, offset int) (int, error) {
+			v, o, err := d.asUint(offset, k)
+			if err != nil {
+				return 0, err
+			}
+			rv.SetUint(v)
+			return o, nil
+		}
+
+	case reflect.Float32:
+		return func(d *decoder, rv reflect.Value, offset int) (int, error) {
+			v, o, err := d.asFloat32(offset, k)
+			if err != nil {
+				return 0, err
+			}
+			rv.SetFloat(float64(v))
+			return o, nil
+		}
+
+	case reflect.Float64:
+		return func(d *decoder, rv reflect.Value, offset int) (int, error) {
+			v, o, err := d.asFloat64(offset, k)
+			if err != nil {
+				return 0, err
+			}
+			rv.SetFloat(v)
+			return o, nil
+		}
+
+	case reflect.String:
+		return func(d *decoder, rv reflect.Value, offset int) (int, error) {
+			v, o, err := d.asString(offset, k)
+			if err != nil {
+				return 0, err
+			}
+			rv.SetString(v)
+			return o, nil
+		}
+
+	case reflect.Bool:
+		return func(d *decoder, rv reflect.Value, offset int) (int, error) {
+			v, o, err := d.asBool(offset, k)
+			if err != nil {
+				return 0, err
+			}
+			rv.SetBool(v)
+			return o, nil
+		}
+
+	case reflect.Slice:
+		return compileSliceDecoder(t)
+
+	case reflect.Array:
+		return compileArrayDecoder(t)
+
+	case reflect.Map:
+		return compileMapDecoder(t)
+
+	case reflect.Struct:
+		return compileStructDecoder(t)
+
+	case reflect.Pointer:
+		return compilePointerDecoder(t)
+
+	case reflect.Interface:
+		return decodeInterface
+	}
+
+	// Fail only when a value of the type is actually read
+	err := fmt.Errorf("unsupported type(%v)", k)
+	return func(d *decoder, rv reflect.Value, offset int) (int, error) {
+		return 0, err
+	}
+}
+
+func compileSliceDecoder(t reflect.Type) decodeFunc {
+	k := t.Kind()
+	elem := decoderOf(t.Elem())
+
+	return func(d *decoder, rv reflect.Value, offset int) (int, error) {
+		code, _, err := d.readSize1(offset)
+		if err != nil {
+			return 0, err
+		}
+		if d.isCodeNil(code) {
+			offset++
+			return offset, nil
+		}
+		// Decode string to bytes
+		if d.isCodeString(code) {
+			l, offset, err := d.stringByteLength(offset, k)
+			if err != nil {
+				return 0, err
+			}
+			bs, offset, err := d.asStringByteByLength(offset, l)
+			if err != nil {
+				return 0, err
+			}
+			if !d.opts.ZeroCopy {
+				bs = append(make([]byte, 0, len(bs)), bs...)
+			}
+			rv.SetBytes(bs)
+			return offset, nil
+		}
+		if d.isCodeBin(code) && t.Elem().Kind() == reflect.Uint8 {
+			bs, offset, err := d.asBin(offset, k)
+			if err != nil {
+				return 0, err
+			}
+			rv.SetBytes(bs)
+			return offset, nil
+		}
+
+		l, o, err := d.sliceLength(offset, k)
+		if err != nil {
+			return 0, err
+		}
+
+		if err = d.hasRequiredLeastSliceSize(o, l); err != nil {
+			return 0, err
+		}
+
+		// Check fix type for slice
+		fixOffset, found, err := d.asFixSlice(rv, o, l)
+		if err != nil {
+			return 0, err
+		}
+		if found {
+			return fixOffset, nil
+		}
+
+		// Add slice
+		tmpSlice := reflect.MakeSlice(t, l, l)
+		for i := 0; i < l; i++ {
+			if o, err = elem(d, tmpSlice.Index(i), o); err != nil {
+				return 0, err
+			}
+		}
+		rv.Set(tmpSlice)
+		return o, nil
+	}
+}
+
+func compileArrayDecoder(t reflect.Type) decodeFunc {
+	k := t.Kind()
+	elem := decoderOf(t.Elem())
+
+	return func(d *decoder, rv reflect.Value, offset int) (int, error) {
+		code, _, err := d.readSize1(offset)
+		if err != nil {
+			return 0, err
+		}
+		if d.isCodeNil(code) {
+			offset++
+			return offset, nil
+		}
+
+		// Decode string to bytes
+		if d.isCodeString(code) {
+			l, offset, err := d.stringByteLength(offset, k)
+			if err != nil {
+				return 0, err
+			}
+			if l > rv.Len() {
+				return 0, fmt.Errorf("%v len is %d, but messagepack has %d elements", t, rv.Len(), l)
+			}
+			bs, offset, err := d.asStringByteByLength(offset, l)
+			if err != nil {
+				return 0, err
+			}
+			for i, b := range bs {
+				rv.Index(i).SetUint(uint64(b))
+			}
+			return offset, nil
+		}
+
+		l, o, err := d.sliceLength(offset, k)
+		if err != nil {
+			return 0, err
+		}
+
+		if l > rv.Len() {
+			return 0, fmt.Errorf("%v len is %d, but messagepack has %d elements", t, rv.Len(), l)
+		}
+
+		if err = d.hasRequiredLeastSliceSize(o, l); err != nil {
+			return 0, err
+		}
+
+		// Add array
+		for i := 0; i < l; i++ {
+			if o, err = elem(d, rv.Index(i), o); err != nil {
+				return 0, err
+			}
+		}
+		return o, nil
+	}
+}
+
+func compileMapDecoder(t reflect.Type) decodeFunc {
+	k := t.Kind()
+	keyType, valueType := t.Key(), t.Elem()
+	key, value := decoderOf(keyType), decoderOf(valueType)
+
+	return func(d *decoder, rv reflect.Value, offset int) (int, error) {
+		code, _, err := d.readSize1(offset)
+		if err != nil {
+			return 0, err
+		}
+		if d.isCodeNil(code) {
+			offset++
+			return offset, nil
+		}
+
+		l, o, err := d.mapLength(offset, k)
+		if err != nil {
+			return 0, err
+		}
+
+		if err = d.hasRequiredLeastMapSize(o, l); err != nil {
+			return 0, err
+		}
+
+		// Check fix map type
+		fixOffset, found, err := d.asFixMap(rv, o, l)
+		if err != nil {
+			return 0, err
+		}
+		if found {
+			return fixOffset, nil
+		}
+
+		// Add elements dynamically
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMapWithSize(t, l))
+		}
+		// Key and value are reused, SetMapIndex stores copies
+		mk, mv := reflect.New(keyType).Elem(), reflect.New(valueType).Elem()
+		for i := 0; i < l; i++ {
+			mk.SetZero()
+			mv.SetZero()
+			if o, err = key(d, mk, o); err != nil {
+				return 0, err
+			}
+			if o, err = value(d, mv, o); err != nil {
+				return 0, err
+			}
+			rv.SetMapIndex(mk, mv)
+		}
+		return o, nil
+	}
+}
+
+func compilePointerDecoder(t reflect.Type) decodeFunc {
+	elem := decoderOf(t.Elem())
+
+	return func(d *decoder, rv reflect.Value, offset int) (int, error) {
+		code, _, err := d.readSize1(offset)
+		if err != nil {
+			return 0, err
+		}
+		if d.isCodeNil(code) {
+			offset++
+			return offset, nil
+		}
+
+		if rv.Elem().Kind() == reflect.Invalid {
+			n := reflect.New(t.Elem())
+			rv.Set(n)
+		}
+		return elem(d, rv.Elem(), offset)
+	}
+}
+
+func decodeInterface(d *decoder, rv reflect.Value, offset int) (int, error) {
+	if rv.Elem().Kind() == reflect.Pointer {
+		return d.decode(rv.Elem(), offset)
+	}
+
+	v, o, err := d.asInterface(offset, reflect.Interface)
+	if err != nil {
+		return 0, err
+	}
+	if v != nil {
+		rv.Set(reflect.ValueOf(v))
+	}
+	return o, nil
+}
+
+func decodeUnmarshaler(d *decoder, rv reflect.Value, offset int) (int, error) {
+	end, err := d.jumpOffset(offset)
+	if err != nil {
+		return 0, err
+	}
+	if len(d.data) < end {
+		return 0, errors.New("too short bytes")
+	}
+	if !rv.CanAddr() {
+		return 0, fmt.Errorf("can not unmarshal into unaddressable %v", rv.Type())
+	}
+	return end, rv.Addr().Interface().(Unmarshaler).UnmarshalMsgpack(d.data[offset:end])
+}
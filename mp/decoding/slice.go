@@ -1,11 +1,11 @@
 package decoding
 
 import (
-	"encoding/binary"
 	"errors"
 	"reflect"
 
 	"github.com/romanzac/json-mp/mp/def"
+	"github.com/romanzac/json-mp/mp/wire"
 )
 
 var (
@@ -34,28 +34,18 @@ func (d *decoder) isFixSlice(v byte) bool {
 }
 
 func (d *decoder) sliceLength(offset int, k reflect.Kind) (int, int, error) {
-	code, offset, err := d.readSize1(offset)
+	code, _, err := d.readSize1(offset)
 	if err != nil {
 		return 0, 0, err
 	}
-
-	switch {
-	case d.isFixSlice(code):
-		return int(code - def.FixArray), offset, nil
-	case code == def.Array16:
-		bs, offset, err := d.readSize2(offset)
-		if err != nil {
-			return 0, 0, err
-		}
-		return int(binary.BigEndian.Uint16(bs)), offset, nil
-	case code == def.Array32:
-		bs, offset, err := d.readSize4(offset)
-		if err != nil {
-			return 0, 0, err
-		}
-		return int(binary.BigEndian.Uint32(bs)), offset, nil
+	if !d.isFixSlice(code) && code != def.Array16 && code != def.Array32 {
+		return 0, 0, d.errorTemplate(code, k)
+	}
+	l, rest, err := wire.ReadArrayHeader(d.data[offset:])
+	if err != nil {
+		return 0, 0, err
 	}
-	return 0, 0, d.errorTemplate(code, k)
+	return l, len(d.data) - len(rest), nil
 }
 
 func (d *decoder) hasRequiredLeastSliceSize(offset, length int) error {
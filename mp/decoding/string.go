@@ -3,6 +3,7 @@ package decoding
 import (
 	"encoding/binary"
 	"reflect"
+	"unsafe"
 
 	"github.com/romanzac/json-mp/mp/def"
 )
@@ -56,6 +57,9 @@ func (d *decoder) asString(offset int, k reflect.Kind) (string, int, error) {
 	if err != nil {
 		return emptyString, 0, err
 	}
+	if d.opts.ZeroCopy && len(bs) > 0 {
+		return unsafe.String(&bs[0], len(bs)), offset, nil
+	}
 	return string(bs), offset, nil
 }
 
@@ -0,0 +1,56 @@
+package decoding
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/romanzac/json-mp/mp/def"
+	"github.com/romanzac/json-mp/mp/wire"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (d *decoder) isCodeExt(v byte) bool {
+	return def.Ext8 <= v && v <= def.Ext32 || def.FixExt1 <= v && v <= def.FixExt16
+}
+
+// asTime reads a timestamp ext, other ext types are errors
+func (d *decoder) asTime(offset int, k reflect.Kind) (time.Time, int, error) {
+	code, _, err := d.readSize1(offset)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	if !d.isCodeExt(code) {
+		return time.Time{}, 0, d.errorTemplate(code, k)
+	}
+	typ, _, _, err := wire.ReadExt(d.data[offset:])
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	if typ != def.TimestampExt {
+		return time.Time{}, 0, fmt.Errorf("ext type %d can not be decoded into %v", typ, k)
+	}
+	t, rest, err := wire.ReadTime(d.data[offset:])
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	return t, len(d.data) - len(rest), nil
+}
+
+func decodeTime(d *decoder, rv reflect.Value, offset int) (int, error) {
+	code, _, err := d.readSize1(offset)
+	if err != nil {
+		return 0, err
+	}
+	if d.isCodeNil(code) {
+		offset++
+		return offset, nil
+	}
+	t, o, err := d.asTime(offset, reflect.Struct)
+	if err != nil {
+		return 0, err
+	}
+	rv.Set(reflect.ValueOf(t))
+	return o, nil
+}
@@ -2,7 +2,7 @@ package def
 
 import "reflect"
 
-// Message pack format without extensions
+// Message pack format codes
 const (
 	Nil = 0xc0
 
@@ -38,10 +38,28 @@ const (
 	Array16  = 0xdc
 	Array32  = 0xdd
 
+	Bin8  = 0xc4
+	Bin16 = 0xc5
+	Bin32 = 0xc6
+
+	Ext8     = 0xc7
+	Ext16    = 0xc8
+	Ext32    = 0xc9
+	FixExt1  = 0xd4
+	FixExt2  = 0xd5
+	FixExt4  = 0xd6
+	FixExt8  = 0xd7
+	FixExt16 = 0xd8
+
+	NeverUsed = 0xc1
+
 	NegativeFixIntMin = 0xe0 - 0xff // -31
 	NegativeFixIntMax = -0x01       //  -1
 )
 
+// TimestampExt is the ext type of the timestamp extension of the specification
+const TimestampExt = -1
+
 // Bytes
 const (
 	Byte1 = 1
@@ -63,3 +81,33 @@ func CheckStructField(field reflect.StructField) (bool, string) {
 	}
 	return false, ""
 }
+
+// FormatName returns the MessagePack specification name of the format code
+func FormatName(code byte) string {
+	switch {
+	case code <= FixIntMax:
+		return "fixint"
+	case FixMap <= code && code <= FixMap+0x0f:
+		return "fixmap"
+	case FixArray <= code && code <= FixArray+0x0f:
+		return "fixarray"
+	case FixStr <= code && code <= FixStr+0x1f:
+		return "fixstr"
+	case code >= 0xe0:
+		return "negfixint"
+	}
+	return formatNames[code]
+}
+
+var formatNames = map[byte]string{
+	Nil: "nil", NeverUsed: "never used", False: "false", True: "true",
+	Bin8: "bin8", Bin16: "bin16", Bin32: "bin32",
+	Ext8: "ext8", Ext16: "ext16", Ext32: "ext32",
+	Float32: "float32", Float64: "float64",
+	Uint8: "uint8", Uint16: "uint16", Uint32: "uint32", Uint64: "uint64",
+	Int8: "int8", Int16: "int16", Int32: "int32", Int64: "int64",
+	FixExt1: "fixext1", FixExt2: "fixext2", FixExt4: "fixext4", FixExt8: "fixext8", FixExt16: "fixext16",
+	Str8: "str8", Str16: "str16", Str32: "str32",
+	Array16: "array16", Array32: "array32",
+	Map16: "map16", Map32: "map32",
+}
@@ -0,0 +1,308 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/romanzac/json-mp/mp/decoding"
+	"github.com/romanzac/json-mp/mp/path"
+)
+
+// Kind of difference between two documents
+type Kind int
+
+const (
+	Added Kind = iota
+	Removed
+	TypeChanged
+	ValueChanged
+)
+
+var kindNames = []string{"added", "removed", "type changed", "value changed"}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return "unknown"
+	}
+	return kindNames[k]
+}
+
+// MarshalText encodes the kind by its name in JSON output
+func (k Kind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// Difference is a single structural difference found at Path (JSON Pointer).
+// From and To are always present in JSON, null for a side which is nil or
+// missing, as told by the empty FromType or ToType.
+type Difference struct {
+	Path     string      `json:"path"`
+	Kind     Kind        `json:"kind"`
+	FromType string      `json:"fromType,omitempty"`
+	ToType   string      `json:"toType,omitempty"`
+	From     interface{} `json:"from"`
+	To       interface{} `json:"to"`
+}
+
+// Options controls which differences are reported
+type Options struct {
+	// IgnoreNumericWidth compares numbers by value only, so uint8 1 equals int64 1
+	IgnoreNumericWidth bool
+}
+
+// Diff returns the structural differences between MessagePack documents a and b.
+// Map keys are compared regardless of their order, array elements by index.
+// Removed array elements are reported from the last index down.
+func Diff(a, b []byte, opts Options) ([]Difference, error) {
+	var va, vb interface{}
+	if err := decoding.Decode(a, &va); err != nil {
+		return nil, err
+	}
+	if err := decoding.Decode(b, &vb); err != nil {
+		return nil, err
+	}
+	return Values(va, vb, opts), nil
+}
+
+// Values is like Diff, but compares already decoded schemaless values
+func Values(a, b interface{}, opts Options) []Difference {
+	var ds []Difference
+	compare(nil, a, b, opts, &ds)
+	return ds
+}
+
+// Equal reports whether decoded values a and b have no differences
+func Equal(a, b interface{}, opts Options) bool {
+	return len(Values(a, b, opts)) == 0
+}
+
+func compare(segs []string, a, b interface{}, opts Options, ds *[]Difference) {
+	ta, tb := TypeName(a), TypeName(b)
+
+	if ma, ok := StringMap(a); ok {
+		if mb, ok := StringMap(b); ok {
+			for _, k := range sortedKeys(ma) {
+				if vb, ok := mb[k]; ok {
+					compare(child(segs, k), ma[k], vb, opts, ds)
+				} else {
+					*ds = append(*ds, removed(child(segs, k), ma[k]))
+				}
+			}
+			for _, k := range sortedKeys(mb) {
+				if _, ok := ma[k]; !ok {
+					*ds = append(*ds, added(child(segs, k), mb[k]))
+				}
+			}
+			return
+		}
+	}
+
+	if la, ok := a.([]interface{}); ok {
+		if lb, ok := b.([]interface{}); ok {
+			n := len(la)
+			if len(lb) < n {
+				n = len(lb)
+			}
+			for i := 0; i < n; i++ {
+				compare(child(segs, strconv.Itoa(i)), la[i], lb[i], opts, ds)
+			}
+			for i := n; i < len(lb); i++ {
+				*ds = append(*ds, added(child(segs, strconv.Itoa(i)), lb[i]))
+			}
+			for i := len(la) - 1; i >= n; i-- {
+				*ds = append(*ds, removed(child(segs, strconv.Itoa(i)), la[i]))
+			}
+			return
+		}
+	}
+
+	d := Difference{Path: path.Format(segs), FromType: ta, ToType: tb, From: JSONValue(a), To: JSONValue(b)}
+	x, isNumA := number(a)
+	y, isNumB := number(b)
+	switch {
+	case isNumA && isNumB:
+		if ta != tb && !opts.IgnoreNumericWidth {
+			d.Kind = TypeChanged
+		} else if x.Cmp(y) != 0 {
+			d.Kind = ValueChanged
+		} else {
+			return
+		}
+	case ta != tb:
+		d.Kind = TypeChanged
+	case !reflect.DeepEqual(a, b):
+		d.Kind = ValueChanged
+	default:
+		return
+	}
+	*ds = append(*ds, d)
+}
+
+func added(segs []string, v interface{}) Difference {
+	return Difference{Path: path.Format(segs), Kind: Added, ToType: TypeName(v), To: JSONValue(v)}
+}
+
+func removed(segs []string, v interface{}) Difference {
+	return Difference{Path: path.Format(segs), Kind: Removed, FromType: TypeName(v), From: JSONValue(v)}
+}
+
+// Text renders differences in human-readable form, one per line
+func Text(ds []Difference) string {
+	var sb strings.Builder
+	for _, d := range ds {
+		switch d.Kind {
+		case Added:
+			fmt.Fprintf(&sb, "+ %s: %s %s\n", d.Path, d.ToType, text(d.To))
+		case Removed:
+			fmt.Fprintf(&sb, "- %s: %s %s\n", d.Path, d.FromType, text(d.From))
+		case TypeChanged:
+			fmt.Fprintf(&sb, "~ %s: %s %s -> %s %s\n", d.Path, d.FromType, text(d.From), d.ToType, text(d.To))
+		case ValueChanged:
+			fmt.Fprintf(&sb, "~ %s: %s -> %s\n", d.Path, text(d.From), text(d.To))
+		}
+	}
+	return sb.String()
+}
+
+func text(v interface{}) string {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(bs)
+}
+
+// TypeName returns the MessagePack type name of a decoded value
+func TypeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "bool"
+	case string:
+		return "string"
+	case int, int64:
+		return "int64"
+	case uint, uint64:
+		return "uint64"
+	case int8:
+		return "int8"
+	case int16:
+		return "int16"
+	case int32:
+		return "int32"
+	case uint8:
+		return "uint8"
+	case uint16:
+		return "uint16"
+	case uint32:
+		return "uint32"
+	case float32:
+		return "float32"
+	case float64:
+		return "float64"
+	case []interface{}:
+		return "array"
+	case map[interface{}]interface{}, map[string]interface{}:
+		return "map"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// JSONValue converts a decoded value into types accepted by json.Marshal
+func JSONValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			m[fmt.Sprint(k)] = JSONValue(e)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			m[k] = JSONValue(e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(t))
+		for i := range t {
+			s[i] = JSONValue(t[i])
+		}
+		return s
+	}
+	return v
+}
+
+// StringMap returns a decoded map keyed by the string form of its keys, false
+// when m is no map
+func StringMap(m interface{}) (map[string]interface{}, bool) {
+	switch t := m.(type) {
+	case map[interface{}]interface{}:
+		sm := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			sm[fmt.Sprint(k)] = v
+		}
+		return sm, true
+	case map[string]interface{}:
+		return t, true
+	}
+	return nil, false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
+
+func child(segs []string, name string) []string {
+	return append(segs[:len(segs):len(segs)], name)
+}
+
+// number returns the exact value of any Go integer or float type
+func number(v interface{}) (*big.Float, bool) {
+	f := new(big.Float)
+	switch n := v.(type) {
+	case int:
+		return f.SetInt64(int64(n)), true
+	case int8:
+		return f.SetInt64(int64(n)), true
+	case int16:
+		return f.SetInt64(int64(n)), true
+	case int32:
+		return f.SetInt64(int64(n)), true
+	case int64:
+		return f.SetInt64(n), true
+	case uint:
+		return f.SetUint64(uint64(n)), true
+	case uint8:
+		return f.SetUint64(uint64(n)), true
+	case uint16:
+		return f.SetUint64(uint64(n)), true
+	case uint32:
+		return f.SetUint64(uint64(n)), true
+	case uint64:
+		return f.SetUint64(n), true
+	case float32:
+		if math.IsNaN(float64(n)) {
+			return nil, false
+		}
+		return f.SetFloat64(float64(n)), true
+	case float64:
+		if math.IsNaN(n) {
+			return nil, false
+		}
+		return f.SetFloat64(n), true
+	}
+	return nil, false
+}
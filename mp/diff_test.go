@@ -0,0 +1,75 @@
+package mp
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/romanzac/json-mp/mp/def"
+	"github.com/romanzac/json-mp/mp/diff"
+)
+
+func TestDiff(t *testing.T) {
+	a, err := Marshal(map[string]interface{}{
+		"id": uint8(1), "name": "a", "tags": []interface{}{"x", "y", "z"}, "gone": true, "kind": 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Marshal(map[string]interface{}{
+		"id": int64(-1 << 40), "name": "b", "tags": []interface{}{"x", "w"}, "new": nil, "kind": "1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ds, err := Diff(a, b, DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Join([]string{
+		`- /gone: bool true`,
+		`~ /id: uint8 1 -> int64 -1099511627776`,
+		`~ /kind: uint8 1 -> string "1"`,
+		`~ /name: "a" -> "b"`,
+		`~ /tags/1: "y" -> "w"`,
+		`- /tags/2: string "z"`,
+		`+ /new: nil null`,
+		``,
+	}, "\n")
+	if r := diff.Text(ds); r != expected {
+		t.Errorf("different value \n[in]:%s\n[out]:%s", expected, r)
+	}
+
+	// an added nil keeps "to" in JSON
+	j, err := json.Marshal(ds[len(ds)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := `{"path":"/new","kind":"added","toType":"nil","from":null,"to":null}`; string(j) != s {
+		t.Errorf("unexpected JSON %s, expected %s", j, s)
+	}
+}
+
+func TestDiffNumericWidth(t *testing.T) {
+	// Same numbers written with different widths by another producer
+	a := []byte{def.FixMap + 2, def.FixStr + 1, 'n', 0x01, def.FixStr + 1, 'f', def.Float32, 0x3f, 0x00, 0x00, 0x00}
+	b := []byte{def.FixMap + 2, def.FixStr + 1, 'f', def.Float64, 0x3f, 0xe0, 0, 0, 0, 0, 0, 0,
+		def.FixStr + 1, 'n', def.Int64, 0, 0, 0, 0, 0, 0, 0, 0x01}
+
+	ds, err := Diff(a, b, DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds) != 2 || ds[0].Kind != diff.TypeChanged || ds[1].Kind != diff.TypeChanged {
+		t.Error("error:", ds)
+	}
+
+	ds, err = Diff(a, b, DiffOptions{IgnoreNumericWidth: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds) != 0 {
+		t.Error("error:", ds)
+	}
+}
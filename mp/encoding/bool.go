@@ -1,12 +1,7 @@
 package encoding
 
-import "github.com/romanzac/json-mp/mp/def"
+import "github.com/romanzac/json-mp/mp/wire"
 
-func (e *encoder) writeBool(v bool, offset int) int {
-	if v {
-		offset = e.setByte1Int(def.True, offset)
-	} else {
-		offset = e.setByte1Int(def.False, offset)
-	}
-	return offset
+func (e *encoder) writeBool(v bool) {
+	e.d = wire.AppendBool(e.d, v)
 }
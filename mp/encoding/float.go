@@ -1,27 +1,11 @@
 package encoding
 
-import (
-	"math"
+import "github.com/romanzac/json-mp/mp/wire"
 
-	"github.com/romanzac/json-mp/mp/def"
-)
-
-func (e *encoder) computeFloat32() int {
-	return def.Byte4
-}
-
-func (e *encoder) computeFloat64() int {
-	return def.Byte8
-}
-
-func (e *encoder) writeFloat32(v float64, offset int) int {
-	offset = e.setByte1Int(def.Float32, offset)
-	offset = e.setByte4Uint64(uint64(math.Float32bits(float32(v))), offset)
-	return offset
+func (e *encoder) writeFloat32(v float64) {
+	e.d = wire.AppendFloat32(e.d, float32(v))
 }
 
-func (e *encoder) writeFloat64(v float64, offset int) int {
-	offset = e.setByte1Int(def.Float64, offset)
-	offset = e.setByte8Uint64(math.Float64bits(v), offset)
-	return offset
+func (e *encoder) writeFloat64(v float64) {
+	e.d = wire.AppendFloat64(e.d, v)
 }
@@ -1,8 +1,7 @@
 package encoding
 
-import "github.com/romanzac/json-mp/mp/def"
+import "github.com/romanzac/json-mp/mp/wire"
 
-func (e *encoder) writeNil(offset int) int {
-	offset = e.setByte1Int(def.Nil, offset)
-	return offset
+func (e *encoder) writeNil() {
+	e.d = wire.AppendNil(e.d)
 }
@@ -0,0 +1,57 @@
+package encoding
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/romanzac/json-mp/mp/wire"
+)
+
+var jsonNumberType = reflect.TypeOf(json.Number(""))
+
+// AppendJSONNumber appends an integer literal in the smallest int or uint
+// format and any other number as float 64. Integers beyond 64 bits would lose
+// precision as float and are rejected. The empty Number is 0 as in encoding/json.
+func AppendJSONNumber(dst []byte, n json.Number) ([]byte, error) {
+	s := string(n)
+	if s == "" {
+		return wire.AppendInt(dst, 0), nil
+	}
+	if !isIntegerLiteral(s) {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return dst, fmt.Errorf("invalid number literal %q", s)
+		}
+		return wire.AppendFloat64(dst, f), nil
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return wire.AppendInt(dst, i), nil
+	}
+	if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return wire.AppendUint(dst, u), nil
+	}
+	return dst, fmt.Errorf("integer %s does not fit in 64 bits", s)
+}
+
+func (e *encoder) writeJSONNumber(n json.Number) (err error) {
+	e.d, err = AppendJSONNumber(e.d, n)
+	return err
+}
+
+// isIntegerLiteral reports whether s is an optional minus followed by digits
+func isIntegerLiteral(s string) bool {
+	if s[0] == '-' {
+		s = s[1:]
+	}
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,297 @@
+package encoding
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/romanzac/json-mp/mp/def"
+)
+
+// Marshaler is implemented by types which encode themselves, such as types
+// with methods from json-mp gen
+type Marshaler interface {
+	MarshalMsgpack() ([]byte, error)
+}
+
+// appender is a Marshaler which can write into the encoder buffer directly
+type appender interface {
+	AppendMsgpack(b []byte) ([]byte, error)
+}
+
+var (
+	marshalerType = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	appenderType  = reflect.TypeOf((*appender)(nil)).Elem()
+)
+
+// encodeFunc writes a value of the type it was compiled for
+type encodeFunc func(e *encoder, rv reflect.Value) error
+
+// Plans are compiled once per type and stored as Map
+var plans = sync.Map{}
+
+// Plans of the predeclared scalar types, so dynamic values such as interface
+// elements skip the Map lookup. Named types may have methods and use plans.
+var (
+	kindPlans [reflect.UnsafePointer + 1]encodeFunc
+	kindTypes [reflect.UnsafePointer + 1]reflect.Type
+)
+
+func init() {
+	for _, t := range []reflect.Type{
+		reflect.TypeOf(uint(0)), reflect.TypeOf(uint8(0)), reflect.TypeOf(uint16(0)), reflect.TypeOf(uint32(0)), reflect.TypeOf(uint64(0)),
+		reflect.TypeOf(0), reflect.TypeOf(int8(0)), reflect.TypeOf(int16(0)), reflect.TypeOf(int32(0)), reflect.TypeOf(int64(0)),
+		reflect.TypeOf(float32(0)), reflect.TypeOf(float64(0)), reflect.TypeOf(false), reflect.TypeOf(""),
+	} {
+		kindPlans[t.Kind()] = compileEncoder(t)
+		kindTypes[t.Kind()] = t
+	}
+}
+
+func encoderOf(t reflect.Type) encodeFunc {
+	if f, find := plans.Load(t); find {
+		return f.(encodeFunc)
+	}
+
+	// Recursive types get an indirect func which waits for the plan compiled below
+	var (
+		wg sync.WaitGroup
+		f  encodeFunc
+	)
+	wg.Add(1)
+	fi, loaded := plans.LoadOrStore(t, encodeFunc(func(e *encoder, rv reflect.Value) error {
+		wg.Wait()
+		return f(e, rv)
+	}))
+	if loaded {
+		return fi.(encodeFunc)
+	}
+	f = compileEncoder(t)
+	wg.Done()
+	plans.Store(t, f)
+	return f
+}
+
+func compileEncoder(t reflect.Type) encodeFunc {
+	// Pointers and interfaces may be nil, they are resolved to their element first
+	if k := t.Kind(); k != reflect.Pointer && k != reflect.Interface {
+		if t.Implements(appenderType) {
+			return func(e *encoder, rv reflect.Value) (err error) {
+				e.d, err = rv.Interface().(appender).AppendMsgpack(e.d)
+				return err
+			}
+		}
+		if t.Implements(marshalerType) {
+			return func(e *encoder, rv reflect.Value) error {
+				bs, err := rv.Interface().(Marshaler).MarshalMsgpack()
+				e.setBytes(bs)
+				return err
+			}
+		}
+	}
+
+	if t == jsonNumberType {
+		return func(e *encoder, rv reflect.Value) error {
+			return e.writeJSONNumber(json.Number(rv.String()))
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		return func(e *encoder, rv reflect.Value) error {
+			e.writeUint(rv.Uint())
+			return nil
+		}
+
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		return func(e *encoder, rv reflect.Value) error {
+			e.writeInt(rv.Int())
+			return nil
+		}
+
+	case reflect.Float32:
+		return func(e *encoder, rv reflect.Value) error {
+			e.writeFloat32(rv.Float())
+			return nil
+		}
+
+	case reflect.Float64:
+		return func(e *encoder, rv reflect.Value) error {
+			e.writeFloat64(rv.Float())
+			return nil
+		}
+
+	case reflect.Bool:
+		return func(e *encoder, rv reflect.Value) error {
+			e.writeBool(rv.Bool())
+			return nil
+		}
+
+	case reflect.String:
+		return func(e *encoder, rv reflect.Value) error {
+			e.writeString(rv.String())
+			return nil
+		}
+
+	case reflect.Slice:
+		return compileSliceEncoder(t)
+
+	case reflect.Array:
+		return compileArrayEncoder(t)
+
+	case reflect.Map:
+		return compileMapEncoder(t)
+
+	case reflect.Struct:
+		return compileStructEncoder(t)
+
+	case reflect.Pointer:
+		elem := encoderOf(t.Elem())
+		return func(e *encoder, rv reflect.Value) error {
+			if rv.IsNil() {
+				e.writeNil()
+				return nil
+			}
+			return elem(e, rv.Elem())
+		}
+
+	case reflect.Interface:
+		// Dynamic type is only known from the value
+		return func(e *encoder, rv reflect.Value) error {
+			return e.add(rv.Elem())
+		}
+	}
+
+	// Fail only when a value of the type is actually written
+	err := fmt.Errorf("unsupported type(%v)", t.Kind())
+	return func(e *encoder, rv reflect.Value) error {
+		return err
+	}
+}
+
+func compileSliceEncoder(t reflect.Type) encodeFunc {
+	var fix encoder
+	if fix.writeFixSlice(reflect.Zero(t)) {
+		return func(e *encoder, rv reflect.Value) error {
+			if rv.IsNil() {
+				e.writeNil()
+				return nil
+			}
+			if err := e.writeSliceLength(rv.Len()); err != nil {
+				return err
+			}
+			e.writeFixSlice(rv)
+			return nil
+		}
+	}
+
+	elem := encoderOf(t.Elem())
+	return func(e *encoder, rv reflect.Value) error {
+		if rv.IsNil() {
+			e.writeNil()
+			return nil
+		}
+		return e.writeElems(rv, elem)
+	}
+}
+
+func compileArrayEncoder(t reflect.Type) encodeFunc {
+	elem := encoderOf(t.Elem())
+
+	// Format array same as slice
+	return func(e *encoder, rv reflect.Value) error {
+		return e.writeElems(rv, elem)
+	}
+}
+
+func (e *encoder) writeElems(rv reflect.Value, elem encodeFunc) error {
+	l := rv.Len()
+	if err := e.writeSliceLength(l); err != nil {
+		return err
+	}
+	for i := 0; i < l; i++ {
+		if err := elem(e, rv.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func compileMapEncoder(t reflect.Type) encodeFunc {
+	var fix encoder
+	if fix.writeFixMap(reflect.Zero(t)) {
+		return func(e *encoder, rv reflect.Value) error {
+			if rv.IsNil() {
+				e.writeNil()
+				return nil
+			}
+			if err := e.writeMapLength(rv.Len()); err != nil {
+				return err
+			}
+			e.writeFixMap(rv)
+			return nil
+		}
+	}
+
+	key, value := encoderOf(t.Key()), encoderOf(t.Elem())
+	return func(e *encoder, rv reflect.Value) error {
+		if rv.IsNil() {
+			e.writeNil()
+			return nil
+		}
+		if err := e.writeMapLength(rv.Len()); err != nil {
+			return err
+		}
+
+		// Fill in keys and values, reusing one key and value for the iteration
+		k, v := reflect.New(t.Key()).Elem(), reflect.New(t.Elem()).Elem()
+		iter := rv.MapRange()
+		for iter.Next() {
+			k.SetIterKey(iter)
+			v.SetIterValue(iter)
+			if err := key(e, k); err != nil {
+				return err
+			}
+			if err := value(e, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+type structField struct {
+	index int
+	key   []byte // field name already encoded as str
+	value encodeFunc
+}
+
+func compileStructEncoder(t reflect.Type) encodeFunc {
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		if ok, name := def.CheckStructField(t.Field(i)); ok {
+			var key encoder
+			key.writeString(name)
+			fields = append(fields, structField{index: i, key: key.d, value: encoderOf(t.Field(i).Type)})
+		}
+	}
+
+	var header encoder
+	if err := header.writeMapLength(len(fields)); err != nil {
+		return func(e *encoder, rv reflect.Value) error {
+			return err
+		}
+	}
+
+	return func(e *encoder, rv reflect.Value) error {
+		e.setBytes(header.d)
+		for i := range fields {
+			e.setBytes(fields[i].key)
+			if err := fields[i].value(e, rv.Field(fields[i].index)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
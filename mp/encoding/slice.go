@@ -1,202 +1,108 @@
 package encoding
 
 import (
+	"fmt"
 	"math"
 	"reflect"
 
-	"github.com/romanzac/json-mp/mp/def"
+	"github.com/romanzac/json-mp/mp/wire"
 )
 
-func (e *encoder) computeFixSlice(rv reflect.Value) (int, bool) {
-	size := 0
-
-	switch sli := rv.Interface().(type) {
-	case []int:
-		for _, v := range sli {
-			size += def.Byte1 + e.computeInt(int64(v))
-		}
-		return size, true
-
-	case []uint:
-		for _, v := range sli {
-			size += def.Byte1 + e.computeUint(uint64(v))
-		}
-		return size, true
-
-	case []string:
-		for _, v := range sli {
-			size += def.Byte1 + e.computeString(v)
-		}
-		return size, true
-
-	case []float32:
-		for range sli {
-			size += def.Byte1 + e.computeFloat32()
-		}
-		return size, true
-
-	case []float64:
-		for range sli {
-			size += def.Byte1 + e.computeFloat64()
-		}
-		return size, true
-
-	case []bool:
-		size += def.Byte1 * len(sli)
-		return size, true
-
-	case []int8:
-		for _, v := range sli {
-			size += def.Byte1 + e.computeInt(int64(v))
-		}
-		return size, true
-
-	case []int16:
-		for _, v := range sli {
-			size += def.Byte1 + e.computeInt(int64(v))
-		}
-		return size, true
-
-	case []int32:
-		for _, v := range sli {
-			size += def.Byte1 + e.computeInt(int64(v))
-		}
-		return size, true
-
-	case []int64:
-		for _, v := range sli {
-			size += def.Byte1 + e.computeInt(v)
-		}
-		return size, true
-
-	case []uint8:
-		for _, v := range sli {
-			size += def.Byte1 + e.computeUint(uint64(v))
-		}
-		return size, true
-
-	case []uint16:
-		for _, v := range sli {
-			size += def.Byte1 + e.computeUint(uint64(v))
-		}
-		return size, true
-
-	case []uint32:
-		for _, v := range sli {
-			size += def.Byte1 + e.computeUint(uint64(v))
-		}
-		return size, true
-
-	case []uint64:
-		for _, v := range sli {
-			size += def.Byte1 + e.computeUint(v)
-		}
-		return size, true
-	}
-
-	return size, false
-}
-
-func (e *encoder) writeSliceLength(l int, offset int) int {
-	if l <= 0x0f {
-		offset = e.setByte1Int(def.FixArray+l, offset)
-	} else if l <= math.MaxUint16 {
-		offset = e.setByte1Int(def.Array16, offset)
-		offset = e.setByte2Int(l, offset)
-	} else if uint(l) <= math.MaxUint32 {
-		offset = e.setByte1Int(def.Array32, offset)
-		offset = e.setByte4Int(l, offset)
+func (e *encoder) writeSliceLength(l int) error {
+	if uint(l) > math.MaxUint32 {
+		return fmt.Errorf("not support this array length : %d", l)
 	}
-	return offset
+	e.d = wire.AppendArrayHeader(e.d, l)
+	return nil
 }
 
-func (e *encoder) writeFixSlice(rv reflect.Value, offset int) (int, bool) {
+func (e *encoder) writeFixSlice(rv reflect.Value) bool {
 
 	switch sli := rv.Interface().(type) {
 	case []int:
 		for _, v := range sli {
-			offset = e.writeInt(int64(v), offset)
+			e.writeInt(int64(v))
 		}
-		return offset, true
+		return true
 
 	case []uint:
 		for _, v := range sli {
-			offset = e.writeUint(uint64(v), offset)
+			e.writeUint(uint64(v))
 		}
-		return offset, true
+		return true
 
 	case []string:
 		for _, v := range sli {
-			offset = e.writeString(v, offset)
+			e.writeString(v)
 		}
-		return offset, true
+		return true
 
 	case []float32:
 		for _, v := range sli {
-			offset = e.writeFloat32(float64(v), offset)
+			e.writeFloat32(float64(v))
 		}
-		return offset, true
+		return true
 
 	case []float64:
 		for _, v := range sli {
-			offset = e.writeFloat64(float64(v), offset)
+			e.writeFloat64(float64(v))
 		}
-		return offset, true
+		return true
 
 	case []bool:
 		for _, v := range sli {
-			offset = e.writeBool(v, offset)
+			e.writeBool(v)
 		}
-		return offset, true
+		return true
 
 	case []int8:
 		for _, v := range sli {
-			offset = e.writeInt(int64(v), offset)
+			e.writeInt(int64(v))
 		}
-		return offset, true
+		return true
 
 	case []int16:
 		for _, v := range sli {
-			offset = e.writeInt(int64(v), offset)
+			e.writeInt(int64(v))
 		}
-		return offset, true
+		return true
 
 	case []int32:
 		for _, v := range sli {
-			offset = e.writeInt(int64(v), offset)
+			e.writeInt(int64(v))
 		}
-		return offset, true
+		return true
 
 	case []int64:
 		for _, v := range sli {
-			offset = e.writeInt(v, offset)
+			e.writeInt(v)
 		}
-		return offset, true
+		return true
 
 	case []uint8:
 		for _, v := range sli {
-			offset = e.writeUint(uint64(v), offset)
+			e.writeUint(uint64(v))
 		}
-		return offset, true
+		return true
 
 	case []uint16:
 		for _, v := range sli {
-			offset = e.writeUint(uint64(v), offset)
+			e.writeUint(uint64(v))
 		}
-		return offset, true
+		return true
 
 	case []uint32:
 		for _, v := range sli {
-			offset = e.writeUint(uint64(v), offset)
+			e.writeUint(uint64(v))
 		}
-		return offset, true
+		return true
 
 	case []uint64:
 		for _, v := range sli {
-			offset = e.writeUint(v, offset)
+			e.writeUint(v)
 		}
-		return offset, true
+		return true
 	}
 
-	return offset, false
+	return false
 }
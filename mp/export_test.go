@@ -0,0 +1,4 @@
+package mp
+
+// Round-trip helpers for the external tests
+var EncodeDecode = encodeDecode
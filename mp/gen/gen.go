@@ -0,0 +1,583 @@
+// Package gen writes reflection-free MessagePack methods for Go struct types.
+// Generated code encodes exactly like mp.Marshal, following def.CheckStructField,
+// and mp.Marshal and mp.Unmarshal pick the methods up automatically.
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/romanzac/json-mp/mp/def"
+	"github.com/romanzac/json-mp/mp/wire"
+)
+
+const (
+	wirePath = "github.com/romanzac/json-mp/mp/wire"
+	mpPath   = "github.com/romanzac/json-mp/mp"
+)
+
+// Options selects the types and the output of Generate
+type Options struct {
+	Types  []string // struct types to generate for, all struct types of the package when empty
+	Output string   // output file name, it is not parsed so a stale file never breaks generation
+}
+
+type generator struct {
+	pkg     *types.Package
+	types   map[*types.TypeName]bool // types getting methods
+	imports map[string]string        // path -> name
+	buf     *bytes.Buffer
+	n       int  // counter for unique variable names
+	usesErr bool // err is assigned in the current method
+}
+
+// Generate type checks the Go package in dir and returns the formatted source
+// with MarshalMsgpack, AppendMsgpack, UnmarshalMsgpack and Msgsize methods
+func Generate(dir string, opts Options) ([]byte, error) {
+	fset := token.NewFileSet()
+	files, err := parsePackage(fset, dir, filepath.Base(opts.Output))
+	if err != nil {
+		return nil, err
+	}
+
+	// Errors are tolerated, fields of unknown types fall back to reflection
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil), Error: func(error) {}}
+	pkg, _ := conf.Check(files[0].Name.Name, fset, files, nil)
+
+	names, err := structTypes(pkg, opts.Types)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &generator{pkg: pkg, types: map[*types.TypeName]bool{}, imports: map[string]string{wirePath: "wire"}, buf: &bytes.Buffer{}}
+	for _, tn := range names {
+		g.types[tn] = true
+	}
+	for _, tn := range names {
+		g.structMethods(tn)
+	}
+	return g.source()
+}
+
+func parsePackage(fset *token.FileSet, dir, output string) ([]*ast.File, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*ast.File
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") || name == output {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) > 0 && f.Name.Name != files[0].Name.Name {
+			return nil, fmt.Errorf("multiple packages in %s: %s, %s", dir, files[0].Name.Name, f.Name.Name)
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+	return files, nil
+}
+
+// structTypes returns the requested struct types in declaration order
+func structTypes(pkg *types.Package, want []string) ([]*types.TypeName, error) {
+	scope := pkg.Scope()
+	var names []*types.TypeName
+	if len(want) > 0 {
+		for _, name := range want {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || !isStruct(tn) {
+				return nil, fmt.Errorf("struct type %s not found in package %s", name, pkg.Name())
+			}
+			names = append(names, tn)
+		}
+	} else {
+		for _, name := range scope.Names() {
+			if tn, ok := scope.Lookup(name).(*types.TypeName); ok && isStruct(tn) {
+				names = append(names, tn)
+			}
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i].Pos() < names[j].Pos() })
+	return names, nil
+}
+
+func isStruct(tn *types.TypeName) bool {
+	named, ok := tn.Type().(*types.Named)
+	if !ok || tn.IsAlias() || named.TypeParams().Len() > 0 {
+		return false
+	}
+	_, ok = named.Underlying().(*types.Struct)
+	return ok
+}
+
+type field struct {
+	name string // key in the map
+	expr string // selector on the receiver
+	typ  types.Type
+}
+
+// fields follows def.CheckStructField, so keys and their order match reflection
+func fields(tn *types.TypeName) []field {
+	st := tn.Type().Underlying().(*types.Struct)
+	var fs []field
+	for i := 0; i < st.NumFields(); i++ {
+		v := st.Field(i)
+		sf := reflect.StructField{Name: v.Name(), Tag: reflect.StructTag(st.Tag(i))}
+		if ok, name := def.CheckStructField(sf); ok {
+			fs = append(fs, field{name: name, expr: "z." + v.Name(), typ: v.Type()})
+		}
+	}
+	return fs
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(g.buf, format, args...)
+}
+
+func (g *generator) tmp(prefix string) string {
+	g.n++
+	return fmt.Sprint(prefix, g.n)
+}
+
+// typeString prints t as seen from the generated file without recording imports
+func (g *generator) typeString(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string {
+		if p == g.pkg {
+			return ""
+		}
+		return p.Name()
+	})
+}
+
+// typeName prints t as seen from the generated file and records its imports
+func (g *generator) typeName(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string {
+		if p == g.pkg {
+			return ""
+		}
+		g.imports[p.Path()] = p.Name()
+		return p.Name()
+	})
+}
+
+func (g *generator) structMethods(tn *types.TypeName) {
+	name := tn.Name()
+	fs := fields(tn)
+
+	g.printf("\n// MarshalMsgpack implements mp.Marshaler\n")
+	g.printf("func (z %s) MarshalMsgpack() ([]byte, error) {\n", name)
+	g.printf("return z.AppendMsgpack(make([]byte, 0, z.Msgsize()))\n}\n")
+
+	body := g.body(func() {
+		g.printf("b = wire.AppendMapHeader(b, %d)\n", len(fs))
+		for _, f := range fs {
+			g.printf("b = wire.AppendString(b, %q)\n", f.name)
+			g.encode(f.expr, f.typ)
+		}
+	})
+	g.printf("\n// AppendMsgpack appends the MessagePack encoding of z to b\n")
+	g.printf("func (z %s) AppendMsgpack(b []byte) ([]byte, error) {\n", name)
+	if g.usesErr {
+		g.printf("var err error\n")
+	}
+	g.buf.Write(body)
+	g.printf("return b, nil\n}\n")
+
+	// Unmarshal, the first field with a name wins as in reflection
+	g.printf("\n// UnmarshalMsgpack implements mp.Unmarshaler\n")
+	g.printf("func (z *%s) UnmarshalMsgpack(b []byte) error {\n", name)
+	g.printf("n, b, err := wire.ReadMapHeader(b)\nif err != nil {\nreturn err\n}\n")
+	g.printf("for i := 0; i < n; i++ {\nvar key []byte\n")
+	g.printf("if key, b, err = wire.ReadStringBytes(b); err != nil {\nreturn err\n}\n")
+	g.printf("switch string(key) {\n")
+	seen := map[string]bool{}
+	for _, f := range fs {
+		if seen[f.name] {
+			continue
+		}
+		seen[f.name] = true
+		g.printf("case %q:\n", f.name)
+		g.decode(f.expr, f.typ)
+	}
+	g.printf("default:\nif b, err = wire.Skip(b); err != nil {\nreturn err\n}\n}\n}\n")
+	g.printf("if len(b) != 0 {\nreturn wire.ErrTrailingBytes\n}\nreturn nil\n}\n")
+
+	g.printf("\n// Msgsize returns an upper bound of the encoded size of z, fields encoded\n")
+	g.printf("// through reflection are estimated\n")
+	g.printf("func (z %s) Msgsize() int {\ns := wire.HeaderSize\n", name)
+	for _, f := range fs {
+		g.printf("s += %d\n", len(wire.AppendString(nil, f.name)))
+		g.size(f.expr, f.typ)
+	}
+	g.printf("return s\n}\n")
+}
+
+// body returns the statements written by f, usesErr tells whether they assign err
+func (g *generator) body(f func()) []byte {
+	saved := g.buf
+	g.buf = &bytes.Buffer{}
+	g.usesErr = false
+	f()
+	body := g.buf.Bytes()
+	g.buf = saved
+	return body
+}
+
+// Value kinds the generator writes code for
+type kind int
+
+const (
+	kindFallback kind = iota // mp.AppendMarshal and mp.Unmarshal
+	kindMethods              // AppendMsgpack and UnmarshalMsgpack of the type
+	kindBasic
+	kindPointer
+	kindSlice
+	kindArray
+	kindMap
+)
+
+func (g *generator) kind(t types.Type) kind {
+	if strings.Contains(g.typeString(t), "invalid type") {
+		return kindFallback
+	}
+	if named, ok := t.(*types.Named); ok {
+		if g.types[named.Obj()] || hasMethods(named) {
+			return kindMethods
+		}
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		if basicFunc(u) != "" {
+			return kindBasic
+		}
+	case *types.Pointer:
+		return kindPointer
+	case *types.Slice:
+		return kindSlice
+	case *types.Array:
+		return kindArray
+	case *types.Map:
+		if b, ok := u.Key().Underlying().(*types.Basic); ok && basicFunc(b) != "" {
+			return kindMap
+		}
+	}
+	return kindFallback
+}
+
+// hasMethods reports whether a type from another generation run has the methods
+func hasMethods(t *types.Named) bool {
+	_, ok := lookupMethod(t, "AppendMsgpack")
+	_, okPtr := lookupMethod(types.NewPointer(t), "UnmarshalMsgpack")
+	return ok && okPtr
+}
+
+// hasSize reports whether the type with methods has Msgsize as well
+func (g *generator) hasSize(t types.Type) bool {
+	if named, ok := t.(*types.Named); ok && g.types[named.Obj()] {
+		return true
+	}
+	_, ok := lookupMethod(t, "Msgsize")
+	return ok
+}
+
+func lookupMethod(t types.Type, name string) (*types.Func, bool) {
+	obj, _, _ := types.LookupFieldOrMethod(t, false, nil, name)
+	f, ok := obj.(*types.Func)
+	return f, ok
+}
+
+// basicFunc returns the suffix of the wire Append and Read functions for b
+func basicFunc(b *types.Basic) string {
+	switch b.Kind() {
+	case types.Bool:
+		return "Bool"
+	case types.String:
+		return "String"
+	case types.Int, types.Int8, types.Int16, types.Int32, types.Int64:
+		return "Int"
+	case types.Uint, types.Uint8, types.Uint16, types.Uint32, types.Uint64:
+		return "Uint"
+	case types.Float32:
+		return "Float32"
+	case types.Float64:
+		return "Float64"
+	}
+	return ""
+}
+
+// wireType is the Go type used by the wire functions with suffix f
+var wireType = map[string]string{
+	"Bool":    "bool",
+	"String":  "string",
+	"Int":     "int64",
+	"Uint":    "uint64",
+	"Float32": "float32",
+	"Float64": "float64",
+}
+
+// fixedSize returns the constant size bound of t, if any
+func (g *generator) fixedSize(t types.Type) (string, bool) {
+	switch g.kind(t) {
+	case kindFallback:
+		return "wire.UnknownSize", true
+	case kindMethods:
+		if !g.hasSize(t) {
+			return "wire.UnknownSize", true
+		}
+		return "", false
+	case kindBasic:
+	default:
+		return "", false
+	}
+	switch basicFunc(t.Underlying().(*types.Basic)) {
+	case "Bool":
+		return "wire.BoolSize", true
+	case "Int", "Uint":
+		return "wire.IntSize", true
+	case "Float32":
+		return "wire.Float32Size", true
+	case "Float64":
+		return "wire.Float64Size", true
+	}
+	return "", false
+}
+
+// paren guards dereferences before selectors and indexes
+func paren(expr string) string {
+	if strings.HasPrefix(expr, "*") {
+		return "(" + expr + ")"
+	}
+	return expr
+}
+
+func (g *generator) encode(expr string, t types.Type) {
+	switch g.kind(t) {
+	case kindMethods:
+		g.usesErr = true
+		g.printf("if b, err = %s.AppendMsgpack(b); err != nil {\nreturn b, err\n}\n", paren(expr))
+
+	case kindBasic:
+		f := basicFunc(t.Underlying().(*types.Basic))
+		if wt := wireType[f]; g.typeString(t) != wt {
+			expr = wt + "(" + expr + ")"
+		}
+		g.printf("b = wire.Append%s(b, %s)\n", f, expr)
+
+	case kindPointer:
+		g.printf("if %s == nil {\nb = wire.AppendNil(b)\n} else {\n", expr)
+		g.encode("*"+expr, t.Underlying().(*types.Pointer).Elem())
+		g.printf("}\n")
+
+	case kindSlice:
+		g.printf("if %s == nil {\nb = wire.AppendNil(b)\n} else {\n", expr)
+		g.encodeElems(expr, t.Underlying().(*types.Slice).Elem())
+		g.printf("}\n")
+
+	case kindArray:
+		g.encodeElems(expr, t.Underlying().(*types.Array).Elem())
+
+	case kindMap:
+		m := t.Underlying().(*types.Map)
+		k, v := g.tmp("k"), g.tmp("v")
+		g.printf("if %s == nil {\nb = wire.AppendNil(b)\n} else {\n", expr)
+		g.printf("b = wire.AppendMapHeader(b, len(%s))\n", expr)
+		g.printf("for %s, %s := range %s {\n", k, v, expr)
+		g.encode(k, m.Key())
+		g.encode(v, m.Elem())
+		g.printf("}\n}\n")
+
+	default:
+		g.usesErr = true
+		g.imports[mpPath] = "mp"
+		g.printf("if b, err = mp.AppendMarshal(b, %s); err != nil {\nreturn b, err\n}\n", expr)
+	}
+}
+
+func (g *generator) encodeElems(expr string, elem types.Type) {
+	i := g.tmp("i")
+	g.printf("b = wire.AppendArrayHeader(b, len(%s))\n", expr)
+	g.printf("for %s := range %s {\n", i, expr)
+	g.encode(paren(expr)+"["+i+"]", elem)
+	g.printf("}\n")
+}
+
+func (g *generator) decode(dst string, t types.Type) {
+	switch g.kind(t) {
+	case kindMethods:
+		v := g.tmp("v")
+		g.printf("var %s []byte\nif %s, b, err = wire.ReadRaw(b); err != nil {\nreturn err\n}\n", v, v)
+		g.printf("if err = %s.UnmarshalMsgpack(%s); err != nil {\nreturn err\n}\n", paren(dst), v)
+
+	case kindBasic:
+		f := basicFunc(t.Underlying().(*types.Basic))
+		if wt := wireType[f]; g.typeString(t) != wt {
+			v := g.tmp("v")
+			g.printf("var %s %s\nif %s, b, err = wire.Read%s(b); err != nil {\nreturn err\n}\n", v, wt, v, f)
+			g.printf("%s = %s(%s)\n", dst, g.typeName(t), v)
+		} else {
+			g.printf("if %s, b, err = wire.Read%s(b); err != nil {\nreturn err\n}\n", dst, f)
+		}
+
+	case kindPointer:
+		elem := t.Underlying().(*types.Pointer).Elem()
+		g.printf("if wire.IsNil(b) {\nb = b[1:]\n} else {\n")
+		g.printf("if %s == nil {\n%s = new(%s)\n}\n", dst, dst, g.typeName(elem))
+		g.decode("*"+dst, elem)
+		g.printf("}\n")
+
+	case kindSlice:
+		elem := t.Underlying().(*types.Slice).Elem()
+		g.printf("if wire.IsNil(b) {\nb = b[1:]\n")
+		if types.Identical(elem, types.Typ[types.Byte]) {
+			// str and bin as mp.Unmarshal accepts them, copied as b may be reused
+			typ, v := g.tmp("t"), g.tmp("v")
+			g.printf("} else if %s := wire.NextType(b); %s == wire.StrType || %s == wire.BinType {\n", typ, typ, typ)
+			g.printf("var %s []byte\nif %s, b, err = wire.ReadBytes(b); err != nil {\nreturn err\n}\n", v, v)
+			g.printf("%s = append(%s{}, %s...)\n", dst, g.typeName(t), v)
+		}
+		n, i := g.tmp("n"), g.tmp("i")
+		g.printf("} else {\n")
+		g.printf("var %s int\nif %s, b, err = wire.ReadArrayHeader(b); err != nil {\nreturn err\n}\n", n, n)
+		g.printf("%s = make(%s, %s)\n", dst, g.typeName(t), n)
+		g.printf("for %s := range %s {\n", i, dst)
+		g.decode(paren(dst)+"["+i+"]", elem)
+		g.printf("}\n}\n")
+
+	case kindArray:
+		a := t.Underlying().(*types.Array)
+		n, i := g.tmp("n"), g.tmp("i")
+		g.printf("if wire.IsNil(b) {\nb = b[1:]\n} else {\n")
+		g.printf("var %s int\nif %s, b, err = wire.ReadArrayHeader(b); err != nil {\nreturn err\n}\n", n, n)
+		g.printf("if %s > %d {\nreturn wire.ErrArrayLength\n}\n", n, a.Len())
+		g.printf("for %s := 0; %s < %s; %s++ {\n", i, i, n, i)
+		g.decode(paren(dst)+"["+i+"]", a.Elem())
+		g.printf("}\n}\n")
+
+	case kindMap:
+		m := t.Underlying().(*types.Map)
+		n, i, k, v := g.tmp("n"), g.tmp("i"), g.tmp("k"), g.tmp("v")
+		g.printf("if wire.IsNil(b) {\nb = b[1:]\n} else {\n")
+		g.printf("var %s int\nif %s, b, err = wire.ReadMapHeader(b); err != nil {\nreturn err\n}\n", n, n)
+		g.printf("%s = make(%s, %s)\n", dst, g.typeName(t), n)
+		g.printf("for %s := 0; %s < %s; %s++ {\n", i, i, n, i)
+		g.printf("var %s %s\nvar %s %s\n", k, g.typeName(m.Key()), v, g.typeName(m.Elem()))
+		g.decode(k, m.Key())
+		g.decode(v, m.Elem())
+		g.printf("%s[%s] = %s\n}\n}\n", paren(dst), k, v)
+
+	default:
+		g.imports[mpPath] = "mp"
+		v := g.tmp("v")
+		g.printf("var %s []byte\nif %s, b, err = wire.ReadRaw(b); err != nil {\nreturn err\n}\n", v, v)
+		g.printf("if err = mp.Unmarshal(%s, &%s); err != nil {\nreturn err\n}\n", v, dst)
+	}
+}
+
+func (g *generator) size(expr string, t types.Type) {
+	if s, ok := g.fixedSize(t); ok {
+		g.printf("s += %s\n", s)
+		return
+	}
+
+	switch g.kind(t) {
+	case kindMethods:
+		g.printf("s += %s.Msgsize()\n", paren(expr))
+
+	case kindBasic:
+		// Only strings have no fixed size
+		g.printf("s += wire.HeaderSize + len(%s)\n", expr)
+
+	case kindPointer:
+		g.printf("s += wire.NilSize\nif %s != nil {\n", expr)
+		g.size("*"+expr, t.Underlying().(*types.Pointer).Elem())
+		g.printf("}\n")
+
+	case kindSlice:
+		g.sizeElems(expr, t.Underlying().(*types.Slice).Elem())
+
+	case kindArray:
+		g.sizeElems(expr, t.Underlying().(*types.Array).Elem())
+
+	case kindMap:
+		m := t.Underlying().(*types.Map)
+		g.printf("s += wire.HeaderSize\n")
+		ks, kOk := g.fixedSize(m.Key())
+		vs, vOk := g.fixedSize(m.Elem())
+		if kOk && vOk {
+			g.printf("s += len(%s) * (%s + %s)\n", expr, ks, vs)
+			return
+		}
+		k := g.tmp("k")
+		g.printf("for %s := range %s {\n", k, expr)
+		g.size(k, m.Key())
+		g.size(paren(expr)+"["+k+"]", m.Elem())
+		g.printf("}\n")
+
+	}
+}
+
+func (g *generator) sizeElems(expr string, elem types.Type) {
+	g.printf("s += wire.HeaderSize\n")
+	if s, ok := g.fixedSize(elem); ok {
+		g.printf("s += len(%s) * %s\n", expr, s)
+		return
+	}
+	i := g.tmp("i")
+	g.printf("for %s := range %s {\n", i, expr)
+	g.size(paren(expr)+"["+i+"]", elem)
+	g.printf("}\n")
+}
+
+func (g *generator) source() ([]byte, error) {
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by json-mp gen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", g.pkg.Name())
+
+	// Standard library first, then other modules
+	var std, other []string
+	for p := range g.imports {
+		if strings.Contains(strings.Split(p, "/")[0], ".") {
+			other = append(other, p)
+		} else {
+			std = append(std, p)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+	for _, p := range std {
+		fmt.Fprintf(&src, "%q\n", p)
+	}
+	if len(std) > 0 {
+		src.WriteString("\n")
+	}
+	for _, p := range other {
+		fmt.Fprintf(&src, "%q\n", p)
+	}
+	src.WriteString(")\n")
+	src.Write(g.buf.Bytes())
+
+	out, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated invalid code: %v", err)
+	}
+	return out, nil
+}
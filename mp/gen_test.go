@@ -0,0 +1,198 @@
+package mp_test
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/romanzac/json-mp/mp"
+	"github.com/romanzac/json-mp/mp/def"
+	"github.com/romanzac/json-mp/mp/gen"
+	"github.com/romanzac/json-mp/mp/internal/sample"
+	"github.com/romanzac/json-mp/mp/wire"
+)
+
+// Same fields as the sample types, but without the generated methods
+type (
+	plainWidget sample.Widget
+	plainWindow sample.Window
+	plainImage  sample.Image
+)
+
+func newSampleWidget() sample.Widget {
+	alpha := uint8(200)
+	next := &sample.Image{Src: "next.png"}
+	return sample.Widget{
+		Debug:   "on",
+		Window:  sample.Window{Title: "Sample Konfabulator Widget", Width: -500, Height: 1 << 40, Shown: true},
+		Image:   &sample.Image{Src: "sun.png", Alpha: &alpha, Data: []byte{1, 2, 255}, Next: next},
+		Level:   -3,
+		Ratio:   1.5,
+		Scale:   -2.25,
+		Tags:    []string{"a", "", "long tag value which needs a str8 header to be encoded"},
+		Sizes:   [3]uint16{1, 300, 65535},
+		Counts:  map[string]int{"one": 1},
+		Images:  []sample.Image{{Src: "a"}, {Src: "b", Next: next}},
+		Extra:   "extra",
+		Labels:  map[string]*sample.Image{"none": nil},
+		Timeout: 3 * time.Second,
+	}
+}
+
+func TestGeneratedRoundTrip(t *testing.T) {
+	v := newSampleWidget()
+	var r sample.Widget
+	if err := mp.EncodeDecode(v, &r, func(d byte) bool { return def.FixMap+13 == d }); err != nil {
+		t.Error(err)
+	}
+
+	var rs []sample.Image
+	if err := mp.EncodeDecode(v.Images, &rs, func(d byte) bool { return def.FixArray+2 == d }); err != nil {
+		t.Error(err)
+	}
+
+	var rp *sample.Window
+	if err := mp.EncodeDecode(&v.Window, &rp, func(d byte) bool { return def.FixMap+4 == d }); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGeneratedMatchesReflection(t *testing.T) {
+	v := newSampleWidget()
+	for i, tt := range []struct{ gen, plain interface{} }{
+		{v, plainWidget(v)},
+		{v.Window, plainWindow(v.Window)},
+		{*v.Image, plainImage(*v.Image)},
+		{sample.Widget{}, plainWidget{}},
+	} {
+		g, err := mp.Marshal(tt.gen)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		p, err := mp.Marshal(tt.plain)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if !bytes.Equal(g, p) {
+			t.Errorf("%d: generated\n%s\nreflection\n%s", i, mp.Dump(g), mp.Dump(p))
+		}
+	}
+
+	// Reflection decodes generated output
+	d, _ := mp.Marshal(v)
+	var r plainWidget
+	if err := mp.Unmarshal(d, &r); err != nil || r.Debug != v.Debug || r.Window != v.Window || *r.Image.Alpha != *v.Image.Alpha {
+		t.Error(err, r)
+	}
+
+	if n, _ := v.MarshalMsgpack(); len(n) > v.Msgsize() {
+		t.Error("Msgsize must be an upper bound", len(n), v.Msgsize())
+	}
+}
+
+func TestGeneratedBytes(t *testing.T) {
+	// the lengths of the str and bin cases of TestStructJump
+	for _, l := range []int{0, 3, math.MaxUint8, math.MaxUint16, math.MaxUint16 + 1} {
+		p := []byte(strings.Repeat("c", l))
+		array, err := mp.Marshal(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range [][]byte{wire.AppendStringBytes(nil, p), wire.AppendBytes(nil, p), array} {
+			d := append(wire.AppendString(wire.AppendMapHeader(nil, 1), "data"), v...)
+			var g sample.Image
+			var r plainImage
+			if err = mp.Unmarshal(d, &g); err != nil {
+				t.Fatal(l, err)
+			}
+			if err = mp.Unmarshal(d, &r); err != nil {
+				t.Fatal(l, err)
+			}
+			for i := range d {
+				d[i] = 'x'
+			}
+			if !bytes.Equal(g.Data, p) || !bytes.Equal(r.Data, p) {
+				t.Errorf("%d, %x: generated and reflection must read the payload and not alias the input", l, v[0])
+			}
+		}
+	}
+}
+
+func TestGeneratedErrors(t *testing.T) {
+	var w sample.Window
+	d, _ := mp.Marshal(sample.Window{Title: "x"})
+	if err := w.UnmarshalMsgpack(append(d, 0x01)); err == nil {
+		t.Error("error must occur for trailing bytes")
+	}
+	if err := w.UnmarshalMsgpack(d[:len(d)-1]); err == nil {
+		t.Error("error must occur for truncated data")
+	}
+	if err := mp.Unmarshal([]byte{def.FixMap + 1, def.FixStr + 5, 't', 'i', 't', 'l', 'e', def.True}, &w); err == nil {
+		t.Error("error must occur for bool title")
+	}
+
+	d, _ = mp.Marshal(map[string][]int{"sizes": {1, 2, 3, 4}})
+	if err := (&sample.Widget{}).UnmarshalMsgpack(d); err != wire.ErrArrayLength {
+		t.Error("error must occur", err)
+	}
+}
+
+func TestGeneratedUpToDate(t *testing.T) {
+	const file = "internal/sample/sample_msgpack.go"
+	want, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := gen.Generate("internal/sample", gen.Options{Output: "sample_msgpack.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s is stale, run go generate\n%s", file, got)
+	}
+}
+
+func BenchmarkMarshalGenerated(b *testing.B) {
+	v := newSampleWidget()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := mp.Marshal(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalReflection(b *testing.B) {
+	v := plainWidget(newSampleWidget())
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := mp.Marshal(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalGenerated(b *testing.B) {
+	d, _ := mp.Marshal(newSampleWidget())
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var r sample.Widget
+		if err := mp.Unmarshal(d, &r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalReflection(b *testing.B) {
+	d, _ := mp.Marshal(newSampleWidget())
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var r plainWidget
+		if err := mp.Unmarshal(d, &r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
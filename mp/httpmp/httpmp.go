@@ -0,0 +1,197 @@
+// Package httpmp reads and writes MessagePack request and response bodies
+// with net/http, using mp.Marshal and mp.Unmarshal
+package httpmp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/romanzac/json-mp/mp"
+)
+
+// Media types written by this package
+const (
+	ContentType = "application/msgpack"
+	JSONType    = "application/json"
+)
+
+// DefaultMaxBodySize is the body limit of ReadMsgpack
+const DefaultMaxBodySize = 10 << 20
+
+// ContentTypes are the MessagePack media types accepted in requests, all of
+// them stand for ContentType in Accept headers
+var ContentTypes = []string{ContentType, "application/x-msgpack", "application/vnd.msgpack"}
+
+// Error is a request which cannot be read, Status is the HTTP status code
+// answering it: 415, 413 or 400
+type Error struct {
+	Status int
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// encoders buffer the responses of WriteMsgpack
+var encoders = sync.Pool{New: func() interface{} { return new(mp.Encoder) }}
+
+// WriteMsgpack encodes v into a pooled mp.Encoder and writes it as response
+// with status and Content-Length. On error nothing is written, the caller can
+// still answer with an error status.
+func WriteMsgpack(w http.ResponseWriter, status int, v interface{}) error {
+	enc := encoders.Get().(*mp.Encoder)
+	defer func() {
+		// large buffers are not kept alive by the pool
+		if enc.Len() <= DefaultMaxBodySize {
+			enc.Reset()
+			encoders.Put(enc)
+		}
+	}()
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	h := w.Header()
+	h.Set("Content-Type", ContentType)
+	h.Set("Content-Length", strconv.Itoa(enc.Len()))
+	w.WriteHeader(status)
+	_, err := w.Write(enc.Bytes())
+	return err
+}
+
+// ReadMsgpack decodes the MessagePack body of r into v, limited to
+// DefaultMaxBodySize bytes. Errors of the request are *Error.
+func ReadMsgpack(r *http.Request, v interface{}) error {
+	return ReadMsgpackLimit(r, v, DefaultMaxBodySize)
+}
+
+// ReadMsgpackLimit is ReadMsgpack with a body limit of limit bytes
+func ReadMsgpackLimit(r *http.Request, v interface{}, limit int64) error {
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !contains(ContentTypes, mt) {
+		return &Error{http.StatusUnsupportedMediaType, fmt.Errorf("Content-Type must be %s", ContentType)}
+	}
+	if r.ContentLength > limit {
+		return &Error{http.StatusRequestEntityTooLarge, fmt.Errorf("body exceeds %d bytes", limit)}
+	}
+
+	// one byte beyond the limit tells a body of exactly limit bytes from a larger one
+	n := limit
+	if n < math.MaxInt64 {
+		n++
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, n))
+	if err != nil {
+		return &Error{http.StatusBadRequest, err}
+	}
+	if int64(len(data)) > limit {
+		return &Error{http.StatusRequestEntityTooLarge, fmt.Errorf("body exceeds %d bytes", limit)}
+	}
+	if err = mp.Unmarshal(data, v); err != nil {
+		return &Error{http.StatusBadRequest, err}
+	}
+	return nil
+}
+
+// Negotiate returns ContentType or JSONType, whichever the Accept header of r
+// prefers by quality, "" when it accepts neither. Without the header, on equal
+// quality and for */* JSON is chosen, as clients asking for MessagePack name it.
+func Negotiate(r *http.Request) string {
+	if len(r.Header.Values("Accept")) == 0 {
+		return JSONType
+	}
+	mq, jq := Quality(r, ContentTypes...), Quality(r, JSONType)
+	switch {
+	case mq > jq:
+		return ContentType
+	case jq > 0:
+		return JSONType
+	}
+	return ""
+}
+
+// Write writes v as response with status in the media type chosen by
+// Negotiate, JSON with encoding/json. When neither is accepted it answers
+// 406 and returns an error.
+func Write(w http.ResponseWriter, r *http.Request, status int, v interface{}) error {
+	switch Negotiate(r) {
+	case ContentType:
+		return WriteMsgpack(w, status, v)
+	case JSONType:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		h := w.Header()
+		h.Set("Content-Type", JSONType)
+		h.Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(status)
+		_, err = w.Write(data)
+		return err
+	}
+	http.Error(w, "response is "+JSONType+" or "+ContentType, http.StatusNotAcceptable)
+	return errors.New("neither JSON nor MessagePack is acceptable")
+}
+
+// Quality returns the quality the Accept header of r gives to any of types,
+// 1 without the header. The most specific matching range counts, e.g.
+// application/msgpack;q=0 excludes MessagePack even with */* present. Ranges
+// with a quality outside [0, 1] are ignored.
+func Quality(r *http.Request, types ...string) float64 {
+	accept := r.Header.Values("Accept")
+	if len(accept) == 0 {
+		return 1
+	}
+	best, specificity := 0.0, -1
+	for _, value := range accept {
+		for _, part := range strings.Split(value, ",") {
+			mt, params, err := mime.ParseMediaType(part)
+			if err != nil {
+				continue
+			}
+			s := -1
+			switch {
+			case contains(types, mt):
+				s = 2
+			case mt == "application/*":
+				s = 1
+			case mt == "*/*":
+				s = 0
+			}
+			if s < 0 || s < specificity {
+				continue
+			}
+			q := 1.0
+			if v, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(v, 64); err != nil || q < 0 || q > 1 {
+					continue
+				}
+			}
+			if s > specificity || q > best {
+				best, specificity = q, s
+			}
+		}
+	}
+	return best
+}
+
+func contains(types []string, t string) bool {
+	for _, s := range types {
+		if s == t {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package mp_test
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/romanzac/json-mp/mp"
+	"github.com/romanzac/json-mp/mp/httpmp"
+)
+
+type point struct {
+	X int    `json:"x"`
+	Y int    `json:"y"`
+	L string `json:"label"`
+}
+
+func TestHTTPWriteRead(t *testing.T) {
+	v := point{1, -2, "a"}
+	rec := httptest.NewRecorder()
+	if err := httpmp.WriteMsgpack(rec, http.StatusCreated, v); err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := mp.Marshal(v)
+	if rec.Code != http.StatusCreated || rec.Header().Get("Content-Type") != httpmp.ContentType ||
+		rec.Header().Get("Content-Length") != strconv.Itoa(len(expected)) || !bytes.Equal(rec.Body.Bytes(), expected) {
+		t.Errorf("unexpected response %d %v % x", rec.Code, rec.Header(), rec.Body.Bytes())
+	}
+
+	// the pooled buffer holds only the next response
+	rec = httptest.NewRecorder()
+	if err := httpmp.WriteMsgpack(rec, http.StatusOK, 1); err != nil || !bytes.Equal(rec.Body.Bytes(), []byte{0x01}) {
+		t.Errorf("unexpected body % x, %v", rec.Body.Bytes(), err)
+	}
+
+	rec = httptest.NewRecorder()
+	if err := httpmp.WriteMsgpack(rec, http.StatusOK, make(chan int)); err == nil || rec.Body.Len() != 0 || rec.Header().Get("Content-Type") != "" {
+		t.Error("error must occur before the response is written", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(expected))
+	req.Header.Set("Content-Type", "application/x-msgpack")
+	var out point
+	if err := httpmp.ReadMsgpack(req, &out); err != nil || out != v {
+		t.Errorf("read %+v, %v", out, err)
+	}
+
+	for _, c := range []struct {
+		contentType string
+		body        []byte
+		limit       int64
+		status      int
+	}{
+		{"application/json", expected, 100, http.StatusUnsupportedMediaType},
+		{"", expected, 100, http.StatusUnsupportedMediaType},
+		{httpmp.ContentType, expected, 12, http.StatusRequestEntityTooLarge},
+		{httpmp.ContentType, []byte{0xc1}, 100, http.StatusBadRequest},
+		{httpmp.ContentType, []byte{0x81, 0xa1, 'x'}, 100, http.StatusBadRequest},
+		{httpmp.ContentType, expected, math.MaxInt64, 0},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(c.body))
+		req.Header.Set("Content-Type", c.contentType)
+		// a chunked body has no length to check up front
+		req.ContentLength = -1
+		out = point{}
+		err := httpmp.ReadMsgpackLimit(req, &out, c.limit)
+		if c.status == 0 {
+			if err != nil || out != v {
+				t.Errorf("limit %d: read %+v, %v", c.limit, out, err)
+			}
+			continue
+		}
+		var herr *httpmp.Error
+		if !errors.As(err, &herr) || herr.Status != c.status {
+			t.Errorf("%q % x: %v, expected status %d", c.contentType, c.body, err, c.status)
+		}
+	}
+}
+
+func TestHTTPNegotiate(t *testing.T) {
+	for _, c := range []struct {
+		accept   string
+		expected string
+	}{
+		{"", httpmp.JSONType},
+		{"application/msgpack", httpmp.ContentType},
+		{"application/vnd.msgpack, application/json;q=0.5", httpmp.ContentType},
+		{"application/json, application/msgpack", httpmp.JSONType},
+		{"application/json;q=0.2, application/*;q=0.9", httpmp.ContentType},
+		{"*/*", httpmp.JSONType},
+		{"*/*, application/json;q=0", httpmp.ContentType},
+		{"text/html", ""},
+		{"application/msgpack;q=0, text/*", ""},
+		{"application/msgpack;q=5, application/json;q=0.5", httpmp.JSONType},
+		{"application/json;q=-1, application/msgpack;q=0.1", httpmp.ContentType},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if c.accept != "" {
+			req.Header.Set("Accept", c.accept)
+		}
+		if got := httpmp.Negotiate(req); got != c.expected {
+			t.Errorf("%q: %q, expected %q", c.accept, got, c.expected)
+		}
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpmp.Write(w, r, http.StatusOK, point{X: 1})
+	})
+	for accept, expected := range map[string]string{
+		"application/msgpack": httpmp.ContentType,
+		"application/json":    httpmp.JSONType,
+		"text/html":           "text/plain; charset=utf-8",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Header().Get("Content-Type") != expected {
+			t.Errorf("%s: %s", accept, rec.Header().Get("Content-Type"))
+		}
+		if accept == httpmp.JSONType && !strings.Contains(rec.Body.String(), `"x":1`) {
+			t.Errorf("unexpected JSON %s", rec.Body.String())
+		}
+		if accept == "text/html" && rec.Code != http.StatusNotAcceptable {
+			t.Errorf("status %d, expected 406", rec.Code)
+		}
+	}
+}
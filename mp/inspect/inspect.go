@@ -0,0 +1,276 @@
+package inspect
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/romanzac/json-mp/mp/def"
+	"github.com/romanzac/json-mp/mp/validate"
+)
+
+// Options controls the rendering of the tree
+type Options struct {
+	MaxDepth int  // containers deeper than MaxDepth are collapsed, 0 means no limit
+	Color    bool // ANSI colors for terminals
+}
+
+// ANSI color codes
+const (
+	colorReset  = "\x1b[0m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorPurple = "\x1b[35m"
+	colorCyan   = "\x1b[36m"
+	colorGray   = "\x1b[90m"
+)
+
+// Preview sizes of long values
+const (
+	maxStr = 64
+	maxBin = 16
+)
+
+type printer struct {
+	w    io.Writer
+	data []byte
+	opts Options
+	err  error
+}
+
+// Dump renders the annotated tree of MessagePack data as a string, which suits
+// test failure messages. Problems found in the data are rendered as the last line.
+func Dump(data []byte, opts Options) string {
+	var sb strings.Builder
+	_ = Fprint(&sb, data, opts)
+	return sb.String()
+}
+
+// Fprint writes the annotated tree of all MessagePack values in data to w.
+// Each node shows its byte offset, format name, declared length and value.
+func Fprint(w io.Writer, data []byte, opts Options) error {
+	p := printer{w: w, data: data, opts: opts}
+	var err error
+	for o := 0; o < len(data) && err == nil; {
+		o, err = p.value(o, 0, 0, "")
+	}
+	if err != nil && p.err == nil {
+		s := fmt.Sprintf("error: %v", err)
+		if opts.Color {
+			s = colorRed + s + colorReset
+		}
+		_, p.err = io.WriteString(w, s+"\n")
+		return err
+	}
+	return p.err
+}
+
+// node writes a single tree line for the value at offset
+func (p *printer) node(offset, indent int, label, length, value, color string) {
+	off := fmt.Sprintf("%06x", offset)
+	name := fmt.Sprintf("%-9s", def.FormatName(p.data[offset]))
+	if p.opts.Color {
+		off = colorGray + off + colorReset
+		name = colorCyan + name + colorReset
+		if value != "" {
+			value = color + value + colorReset
+		}
+	}
+	s := fmt.Sprintf("%s  %s%s%s %-8s %s", off, strings.Repeat("  ", indent), label, name, length, value)
+	if p.err == nil {
+		_, p.err = io.WriteString(p.w, strings.TrimRight(s, " ")+"\n")
+	}
+}
+
+func (p *printer) value(offset, depth, indent int, label string) (int, error) {
+	if len(p.data) < offset+def.Byte1 {
+		return 0, &validate.Error{Offset: offset, Msg: "too short bytes"}
+	}
+	code := p.data[offset]
+	o := offset + def.Byte1
+
+	switch {
+	case code <= def.FixIntMax:
+		p.node(offset, indent, label, "", fmt.Sprint(code), colorYellow)
+		return o, nil
+	case code >= 0xe0:
+		p.node(offset, indent, label, "", fmt.Sprint(int8(code)), colorYellow)
+		return o, nil
+	case code == def.Nil, code == def.True, code == def.False:
+		p.node(offset, indent, label, "", "", "")
+		return o, nil
+
+	case def.FixMap <= code && code <= def.FixMap+0x0f:
+		return p.container(offset, o, int(code-def.FixMap), true, depth, indent, label)
+	case def.FixArray <= code && code <= def.FixArray+0x0f:
+		return p.container(offset, o, int(code-def.FixArray), false, depth, indent, label)
+	case code == def.Map16, code == def.Map32, code == def.Array16, code == def.Array32:
+		l, o, err := p.length(offset, o, code == def.Map16 || code == def.Array16)
+		if err != nil {
+			return 0, err
+		}
+		return p.container(offset, o, l, code == def.Map16 || code == def.Map32, depth, indent, label)
+
+	case def.FixStr <= code && code <= def.FixStr+0x1f:
+		return p.str(offset, o, int(code-def.FixStr), indent, label)
+	case code == def.Str8, code == def.Bin8, code == def.Ext8:
+		if len(p.data) < o+def.Byte1 {
+			return 0, &validate.Error{Offset: offset, Msg: "too short bytes"}
+		}
+		return p.bytes(offset, o+def.Byte1, int(p.data[o]), indent, label)
+	case code == def.Str16, code == def.Bin16, code == def.Ext16:
+		l, o, err := p.length(offset, o, true)
+		if err != nil {
+			return 0, err
+		}
+		return p.bytes(offset, o, l, indent, label)
+	case code == def.Str32, code == def.Bin32, code == def.Ext32:
+		l, o, err := p.length(offset, o, false)
+		if err != nil {
+			return 0, err
+		}
+		return p.bytes(offset, o, l, indent, label)
+	case def.FixExt1 <= code && code <= def.FixExt16:
+		return p.bytes(offset, o, 1<<(code-def.FixExt1), indent, label)
+
+	case code == def.Uint8, code == def.Uint16, code == def.Uint32, code == def.Uint64,
+		code == def.Int8, code == def.Int16, code == def.Int32, code == def.Int64,
+		code == def.Float32, code == def.Float64:
+		return p.number(offset, o, indent, label)
+	}
+	return 0, &validate.Error{Offset: offset, Msg: fmt.Sprintf("invalid code %x", code)}
+}
+
+func (p *printer) length(offset, o int, short bool) (int, int, error) {
+	n := def.Byte4
+	if short {
+		n = def.Byte2
+	}
+	if len(p.data) < o+n {
+		return 0, 0, &validate.Error{Offset: offset, Msg: "too short bytes"}
+	}
+	if short {
+		return int(binary.BigEndian.Uint16(p.data[o:])), o + n, nil
+	}
+	return int(binary.BigEndian.Uint32(p.data[o:])), o + n, nil
+}
+
+func (p *printer) container(offset, o, l int, isMap bool, depth, indent int, label string) (int, error) {
+	length := fmt.Sprintf("len=%d", l)
+
+	// Collapse containers below the depth limit
+	if p.opts.MaxDepth > 0 && depth >= p.opts.MaxDepth && l > 0 {
+		end, err := validate.Next(p.data, offset, validate.DefaultLimits)
+		if err != nil {
+			return 0, err
+		}
+		p.node(offset, indent, label, length, fmt.Sprintf("... %d bytes", end-offset), colorGray)
+		return end, nil
+	}
+	p.node(offset, indent, label, length, "", "")
+
+	var err error
+	for i := 0; i < l; i++ {
+		if isMap {
+			if o, err = p.value(o, depth+1, indent+1, ""); err != nil {
+				return 0, err
+			}
+			// Values are indented under their keys
+			if o, err = p.value(o, depth+1, indent+2, ""); err != nil {
+				return 0, err
+			}
+		} else if o, err = p.value(o, depth+1, indent+1, fmt.Sprintf("[%d] ", i)); err != nil {
+			return 0, err
+		}
+	}
+	return o, nil
+}
+
+func (p *printer) str(offset, o, l, indent int, label string) (int, error) {
+	if len(p.data)-o < l {
+		return 0, &validate.Error{Offset: offset, Msg: "too short bytes"}
+	}
+	s := string(p.data[o : o+l])
+	if len(s) > maxStr {
+		s = s[:maxStr] + "..."
+	}
+	p.node(offset, indent, label, fmt.Sprintf("len=%d", l), fmt.Sprintf("%q", s), colorGreen)
+	return o + l, nil
+}
+
+// bytes renders str, bin and ext values
+func (p *printer) bytes(offset, o, l, indent int, label string) (int, error) {
+	code := p.data[offset]
+	if code == def.Str8 || code == def.Str16 || code == def.Str32 {
+		return p.str(offset, o, l, indent, label)
+	}
+
+	value := ""
+	if isExt := code < def.Bin8 || def.Bin32 < code; isExt {
+		if len(p.data) < o+def.Byte1 {
+			return 0, &validate.Error{Offset: offset, Msg: "too short bytes"}
+		}
+		value = fmt.Sprintf("type=%d ", int8(p.data[o]))
+		o++
+	}
+	if len(p.data)-o < l {
+		return 0, &validate.Error{Offset: offset, Msg: "too short bytes"}
+	}
+	bs := p.data[o : o+l]
+	if len(bs) > maxBin {
+		value += hex.EncodeToString(bs[:maxBin]) + "..."
+	} else {
+		value += hex.EncodeToString(bs)
+	}
+	p.node(offset, indent, label, fmt.Sprintf("len=%d", l), value, colorPurple)
+	return o + l, nil
+}
+
+func (p *printer) number(offset, o, indent int, label string) (int, error) {
+	code := p.data[offset]
+	var size int
+	switch code {
+	case def.Uint8, def.Int8:
+		size = def.Byte1
+	case def.Uint16, def.Int16:
+		size = def.Byte2
+	case def.Uint32, def.Int32, def.Float32:
+		size = def.Byte4
+	default:
+		size = def.Byte8
+	}
+	if len(p.data) < o+size {
+		return 0, &validate.Error{Offset: offset, Msg: "too short bytes"}
+	}
+	bs := p.data[o : o+size]
+
+	var value interface{}
+	switch code {
+	case def.Uint8:
+		value = bs[0]
+	case def.Uint16:
+		value = binary.BigEndian.Uint16(bs)
+	case def.Uint32:
+		value = binary.BigEndian.Uint32(bs)
+	case def.Uint64:
+		value = binary.BigEndian.Uint64(bs)
+	case def.Int8:
+		value = int8(bs[0])
+	case def.Int16:
+		value = int16(binary.BigEndian.Uint16(bs))
+	case def.Int32:
+		value = int32(binary.BigEndian.Uint32(bs))
+	case def.Int64:
+		value = int64(binary.BigEndian.Uint64(bs))
+	case def.Float32:
+		value = math.Float32frombits(binary.BigEndian.Uint32(bs))
+	case def.Float64:
+		value = math.Float64frombits(binary.BigEndian.Uint64(bs))
+	}
+	p.node(offset, indent, label, "", fmt.Sprint(value), colorYellow)
+	return o + size, nil
+}
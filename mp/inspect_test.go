@@ -0,0 +1,46 @@
+package mp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/romanzac/json-mp/mp/inspect"
+)
+
+func TestDump(t *testing.T) {
+	v := struct {
+		Name string  `json:"name"`
+		IDs  []int   `json:"ids"`
+		Rate float32 `json:"rate"`
+	}{"a", []int{-1, 300}, 0.5}
+	d, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := strings.Join([]string{
+		`000000  fixmap    len=3`,
+		`000001    fixstr    len=4    "name"`,
+		`000006      fixstr    len=1    "a"`,
+		`000008    fixstr    len=3    "ids"`,
+		`00000c      fixarray  len=2`,
+		`00000d        [0] negfixint          -1`,
+		`00000e        [1] uint16             300`,
+		`000011    fixstr    len=4    "rate"`,
+		`000016      float32            0.5`,
+		``,
+	}, "\n")
+	if r := Dump(d); r != expected {
+		t.Errorf("different value \n[in]:\n%s\n[out]:\n%s", expected, r)
+	}
+
+	r := inspect.Dump(d, inspect.Options{MaxDepth: 1})
+	if !strings.Contains(r, "fixarray  len=2    ... 5 bytes") {
+		t.Error("error:", r)
+	}
+
+	r = Dump(d[:len(d)-2])
+	if !strings.HasSuffix(r, "error: too short bytes at offset 22\n") {
+		t.Error("error:", r)
+	}
+}
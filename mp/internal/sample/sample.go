@@ -0,0 +1,40 @@
+// Package sample holds types with generated MessagePack methods for the mp tests
+package sample
+
+import "time"
+
+//go:generate go run ../../.. gen -o sample_msgpack.go .
+
+type Level int8
+
+type Widget struct {
+	Debug   string  `json:"debug"`
+	Window  Window  `json:"window"`
+	Image   *Image  `json:"image"`
+	Level   Level   `json:"level"`
+	Ratio   float32 `json:"ratio"`
+	Scale   float64
+	Tags    []string          `json:"tags"`
+	Sizes   [3]uint16         `json:"sizes"`
+	Counts  map[string]int    `json:"counts"`
+	Images  []Image           `json:"images"`
+	Extra   interface{}       `json:"extra"`
+	Labels  map[string]*Image `json:"labels"`
+	Timeout time.Duration     `json:"timeout"`
+	Hidden  string            `json:"-"`
+	secret  string
+}
+
+type Window struct {
+	Title  string `json:"title"`
+	Width  int    `json:"width"`
+	Height uint64 `json:"height"`
+	Shown  bool   `json:"shown"`
+}
+
+type Image struct {
+	Src   string `json:"src"`
+	Alpha *uint8 `json:"alpha"`
+	Data  []byte `json:"data"`
+	Next  *Image `json:"next"`
+}
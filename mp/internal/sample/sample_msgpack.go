@@ -0,0 +1,554 @@
+// Code generated by json-mp gen. DO NOT EDIT.
+
+package sample
+
+import (
+	"time"
+
+	"github.com/romanzac/json-mp/mp"
+	"github.com/romanzac/json-mp/mp/wire"
+)
+
+// MarshalMsgpack implements mp.Marshaler
+func (z Widget) MarshalMsgpack() ([]byte, error) {
+	return z.AppendMsgpack(make([]byte, 0, z.Msgsize()))
+}
+
+// AppendMsgpack appends the MessagePack encoding of z to b
+func (z Widget) AppendMsgpack(b []byte) ([]byte, error) {
+	var err error
+	b = wire.AppendMapHeader(b, 13)
+	b = wire.AppendString(b, "debug")
+	b = wire.AppendString(b, z.Debug)
+	b = wire.AppendString(b, "window")
+	if b, err = z.Window.AppendMsgpack(b); err != nil {
+		return b, err
+	}
+	b = wire.AppendString(b, "image")
+	if z.Image == nil {
+		b = wire.AppendNil(b)
+	} else {
+		if b, err = (*z.Image).AppendMsgpack(b); err != nil {
+			return b, err
+		}
+	}
+	b = wire.AppendString(b, "level")
+	b = wire.AppendInt(b, int64(z.Level))
+	b = wire.AppendString(b, "ratio")
+	b = wire.AppendFloat32(b, z.Ratio)
+	b = wire.AppendString(b, "Scale")
+	b = wire.AppendFloat64(b, z.Scale)
+	b = wire.AppendString(b, "tags")
+	if z.Tags == nil {
+		b = wire.AppendNil(b)
+	} else {
+		b = wire.AppendArrayHeader(b, len(z.Tags))
+		for i1 := range z.Tags {
+			b = wire.AppendString(b, z.Tags[i1])
+		}
+	}
+	b = wire.AppendString(b, "sizes")
+	b = wire.AppendArrayHeader(b, len(z.Sizes))
+	for i2 := range z.Sizes {
+		b = wire.AppendUint(b, uint64(z.Sizes[i2]))
+	}
+	b = wire.AppendString(b, "counts")
+	if z.Counts == nil {
+		b = wire.AppendNil(b)
+	} else {
+		b = wire.AppendMapHeader(b, len(z.Counts))
+		for k3, v4 := range z.Counts {
+			b = wire.AppendString(b, k3)
+			b = wire.AppendInt(b, int64(v4))
+		}
+	}
+	b = wire.AppendString(b, "images")
+	if z.Images == nil {
+		b = wire.AppendNil(b)
+	} else {
+		b = wire.AppendArrayHeader(b, len(z.Images))
+		for i5 := range z.Images {
+			if b, err = z.Images[i5].AppendMsgpack(b); err != nil {
+				return b, err
+			}
+		}
+	}
+	b = wire.AppendString(b, "extra")
+	if b, err = mp.AppendMarshal(b, z.Extra); err != nil {
+		return b, err
+	}
+	b = wire.AppendString(b, "labels")
+	if z.Labels == nil {
+		b = wire.AppendNil(b)
+	} else {
+		b = wire.AppendMapHeader(b, len(z.Labels))
+		for k6, v7 := range z.Labels {
+			b = wire.AppendString(b, k6)
+			if v7 == nil {
+				b = wire.AppendNil(b)
+			} else {
+				if b, err = (*v7).AppendMsgpack(b); err != nil {
+					return b, err
+				}
+			}
+		}
+	}
+	b = wire.AppendString(b, "timeout")
+	b = wire.AppendInt(b, int64(z.Timeout))
+	return b, nil
+}
+
+// UnmarshalMsgpack implements mp.Unmarshaler
+func (z *Widget) UnmarshalMsgpack(b []byte) error {
+	n, b, err := wire.ReadMapHeader(b)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		var key []byte
+		if key, b, err = wire.ReadStringBytes(b); err != nil {
+			return err
+		}
+		switch string(key) {
+		case "debug":
+			if z.Debug, b, err = wire.ReadString(b); err != nil {
+				return err
+			}
+		case "window":
+			var v8 []byte
+			if v8, b, err = wire.ReadRaw(b); err != nil {
+				return err
+			}
+			if err = z.Window.UnmarshalMsgpack(v8); err != nil {
+				return err
+			}
+		case "image":
+			if wire.IsNil(b) {
+				b = b[1:]
+			} else {
+				if z.Image == nil {
+					z.Image = new(Image)
+				}
+				var v9 []byte
+				if v9, b, err = wire.ReadRaw(b); err != nil {
+					return err
+				}
+				if err = (*z.Image).UnmarshalMsgpack(v9); err != nil {
+					return err
+				}
+			}
+		case "level":
+			var v10 int64
+			if v10, b, err = wire.ReadInt(b); err != nil {
+				return err
+			}
+			z.Level = Level(v10)
+		case "ratio":
+			if z.Ratio, b, err = wire.ReadFloat32(b); err != nil {
+				return err
+			}
+		case "Scale":
+			if z.Scale, b, err = wire.ReadFloat64(b); err != nil {
+				return err
+			}
+		case "tags":
+			if wire.IsNil(b) {
+				b = b[1:]
+			} else {
+				var n11 int
+				if n11, b, err = wire.ReadArrayHeader(b); err != nil {
+					return err
+				}
+				z.Tags = make([]string, n11)
+				for i12 := range z.Tags {
+					if z.Tags[i12], b, err = wire.ReadString(b); err != nil {
+						return err
+					}
+				}
+			}
+		case "sizes":
+			if wire.IsNil(b) {
+				b = b[1:]
+			} else {
+				var n13 int
+				if n13, b, err = wire.ReadArrayHeader(b); err != nil {
+					return err
+				}
+				if n13 > 3 {
+					return wire.ErrArrayLength
+				}
+				for i14 := 0; i14 < n13; i14++ {
+					var v15 uint64
+					if v15, b, err = wire.ReadUint(b); err != nil {
+						return err
+					}
+					z.Sizes[i14] = uint16(v15)
+				}
+			}
+		case "counts":
+			if wire.IsNil(b) {
+				b = b[1:]
+			} else {
+				var n16 int
+				if n16, b, err = wire.ReadMapHeader(b); err != nil {
+					return err
+				}
+				z.Counts = make(map[string]int, n16)
+				for i17 := 0; i17 < n16; i17++ {
+					var k18 string
+					var v19 int
+					if k18, b, err = wire.ReadString(b); err != nil {
+						return err
+					}
+					var v20 int64
+					if v20, b, err = wire.ReadInt(b); err != nil {
+						return err
+					}
+					v19 = int(v20)
+					z.Counts[k18] = v19
+				}
+			}
+		case "images":
+			if wire.IsNil(b) {
+				b = b[1:]
+			} else {
+				var n21 int
+				if n21, b, err = wire.ReadArrayHeader(b); err != nil {
+					return err
+				}
+				z.Images = make([]Image, n21)
+				for i22 := range z.Images {
+					var v23 []byte
+					if v23, b, err = wire.ReadRaw(b); err != nil {
+						return err
+					}
+					if err = z.Images[i22].UnmarshalMsgpack(v23); err != nil {
+						return err
+					}
+				}
+			}
+		case "extra":
+			var v24 []byte
+			if v24, b, err = wire.ReadRaw(b); err != nil {
+				return err
+			}
+			if err = mp.Unmarshal(v24, &z.Extra); err != nil {
+				return err
+			}
+		case "labels":
+			if wire.IsNil(b) {
+				b = b[1:]
+			} else {
+				var n25 int
+				if n25, b, err = wire.ReadMapHeader(b); err != nil {
+					return err
+				}
+				z.Labels = make(map[string]*Image, n25)
+				for i26 := 0; i26 < n25; i26++ {
+					var k27 string
+					var v28 *Image
+					if k27, b, err = wire.ReadString(b); err != nil {
+						return err
+					}
+					if wire.IsNil(b) {
+						b = b[1:]
+					} else {
+						if v28 == nil {
+							v28 = new(Image)
+						}
+						var v29 []byte
+						if v29, b, err = wire.ReadRaw(b); err != nil {
+							return err
+						}
+						if err = (*v28).UnmarshalMsgpack(v29); err != nil {
+							return err
+						}
+					}
+					z.Labels[k27] = v28
+				}
+			}
+		case "timeout":
+			var v30 int64
+			if v30, b, err = wire.ReadInt(b); err != nil {
+				return err
+			}
+			z.Timeout = time.Duration(v30)
+		default:
+			if b, err = wire.Skip(b); err != nil {
+				return err
+			}
+		}
+	}
+	if len(b) != 0 {
+		return wire.ErrTrailingBytes
+	}
+	return nil
+}
+
+// Msgsize returns an upper bound of the encoded size of z, fields encoded
+// through reflection are estimated
+func (z Widget) Msgsize() int {
+	s := wire.HeaderSize
+	s += 6
+	s += wire.HeaderSize + len(z.Debug)
+	s += 7
+	s += z.Window.Msgsize()
+	s += 6
+	s += wire.NilSize
+	if z.Image != nil {
+		s += (*z.Image).Msgsize()
+	}
+	s += 6
+	s += wire.IntSize
+	s += 6
+	s += wire.Float32Size
+	s += 6
+	s += wire.Float64Size
+	s += 5
+	s += wire.HeaderSize
+	for i31 := range z.Tags {
+		s += wire.HeaderSize + len(z.Tags[i31])
+	}
+	s += 6
+	s += wire.HeaderSize
+	s += len(z.Sizes) * wire.IntSize
+	s += 7
+	s += wire.HeaderSize
+	for k32 := range z.Counts {
+		s += wire.HeaderSize + len(k32)
+		s += wire.IntSize
+	}
+	s += 7
+	s += wire.HeaderSize
+	for i33 := range z.Images {
+		s += z.Images[i33].Msgsize()
+	}
+	s += 6
+	s += wire.UnknownSize
+	s += 7
+	s += wire.HeaderSize
+	for k34 := range z.Labels {
+		s += wire.HeaderSize + len(k34)
+		s += wire.NilSize
+		if z.Labels[k34] != nil {
+			s += (*z.Labels[k34]).Msgsize()
+		}
+	}
+	s += 8
+	s += wire.IntSize
+	return s
+}
+
+// MarshalMsgpack implements mp.Marshaler
+func (z Window) MarshalMsgpack() ([]byte, error) {
+	return z.AppendMsgpack(make([]byte, 0, z.Msgsize()))
+}
+
+// AppendMsgpack appends the MessagePack encoding of z to b
+func (z Window) AppendMsgpack(b []byte) ([]byte, error) {
+	b = wire.AppendMapHeader(b, 4)
+	b = wire.AppendString(b, "title")
+	b = wire.AppendString(b, z.Title)
+	b = wire.AppendString(b, "width")
+	b = wire.AppendInt(b, int64(z.Width))
+	b = wire.AppendString(b, "height")
+	b = wire.AppendUint(b, z.Height)
+	b = wire.AppendString(b, "shown")
+	b = wire.AppendBool(b, z.Shown)
+	return b, nil
+}
+
+// UnmarshalMsgpack implements mp.Unmarshaler
+func (z *Window) UnmarshalMsgpack(b []byte) error {
+	n, b, err := wire.ReadMapHeader(b)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		var key []byte
+		if key, b, err = wire.ReadStringBytes(b); err != nil {
+			return err
+		}
+		switch string(key) {
+		case "title":
+			if z.Title, b, err = wire.ReadString(b); err != nil {
+				return err
+			}
+		case "width":
+			var v35 int64
+			if v35, b, err = wire.ReadInt(b); err != nil {
+				return err
+			}
+			z.Width = int(v35)
+		case "height":
+			if z.Height, b, err = wire.ReadUint(b); err != nil {
+				return err
+			}
+		case "shown":
+			if z.Shown, b, err = wire.ReadBool(b); err != nil {
+				return err
+			}
+		default:
+			if b, err = wire.Skip(b); err != nil {
+				return err
+			}
+		}
+	}
+	if len(b) != 0 {
+		return wire.ErrTrailingBytes
+	}
+	return nil
+}
+
+// Msgsize returns an upper bound of the encoded size of z, fields encoded
+// through reflection are estimated
+func (z Window) Msgsize() int {
+	s := wire.HeaderSize
+	s += 6
+	s += wire.HeaderSize + len(z.Title)
+	s += 6
+	s += wire.IntSize
+	s += 7
+	s += wire.IntSize
+	s += 6
+	s += wire.BoolSize
+	return s
+}
+
+// MarshalMsgpack implements mp.Marshaler
+func (z Image) MarshalMsgpack() ([]byte, error) {
+	return z.AppendMsgpack(make([]byte, 0, z.Msgsize()))
+}
+
+// AppendMsgpack appends the MessagePack encoding of z to b
+func (z Image) AppendMsgpack(b []byte) ([]byte, error) {
+	var err error
+	b = wire.AppendMapHeader(b, 4)
+	b = wire.AppendString(b, "src")
+	b = wire.AppendString(b, z.Src)
+	b = wire.AppendString(b, "alpha")
+	if z.Alpha == nil {
+		b = wire.AppendNil(b)
+	} else {
+		b = wire.AppendUint(b, uint64(*z.Alpha))
+	}
+	b = wire.AppendString(b, "data")
+	if z.Data == nil {
+		b = wire.AppendNil(b)
+	} else {
+		b = wire.AppendArrayHeader(b, len(z.Data))
+		for i36 := range z.Data {
+			b = wire.AppendUint(b, uint64(z.Data[i36]))
+		}
+	}
+	b = wire.AppendString(b, "next")
+	if z.Next == nil {
+		b = wire.AppendNil(b)
+	} else {
+		if b, err = (*z.Next).AppendMsgpack(b); err != nil {
+			return b, err
+		}
+	}
+	return b, nil
+}
+
+// UnmarshalMsgpack implements mp.Unmarshaler
+func (z *Image) UnmarshalMsgpack(b []byte) error {
+	n, b, err := wire.ReadMapHeader(b)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		var key []byte
+		if key, b, err = wire.ReadStringBytes(b); err != nil {
+			return err
+		}
+		switch string(key) {
+		case "src":
+			if z.Src, b, err = wire.ReadString(b); err != nil {
+				return err
+			}
+		case "alpha":
+			if wire.IsNil(b) {
+				b = b[1:]
+			} else {
+				if z.Alpha == nil {
+					z.Alpha = new(uint8)
+				}
+				var v37 uint64
+				if v37, b, err = wire.ReadUint(b); err != nil {
+					return err
+				}
+				*z.Alpha = uint8(v37)
+			}
+		case "data":
+			if wire.IsNil(b) {
+				b = b[1:]
+			} else if t38 := wire.NextType(b); t38 == wire.StrType || t38 == wire.BinType {
+				var v39 []byte
+				if v39, b, err = wire.ReadBytes(b); err != nil {
+					return err
+				}
+				z.Data = append([]byte{}, v39...)
+			} else {
+				var n40 int
+				if n40, b, err = wire.ReadArrayHeader(b); err != nil {
+					return err
+				}
+				z.Data = make([]byte, n40)
+				for i41 := range z.Data {
+					var v42 uint64
+					if v42, b, err = wire.ReadUint(b); err != nil {
+						return err
+					}
+					z.Data[i41] = byte(v42)
+				}
+			}
+		case "next":
+			if wire.IsNil(b) {
+				b = b[1:]
+			} else {
+				if z.Next == nil {
+					z.Next = new(Image)
+				}
+				var v43 []byte
+				if v43, b, err = wire.ReadRaw(b); err != nil {
+					return err
+				}
+				if err = (*z.Next).UnmarshalMsgpack(v43); err != nil {
+					return err
+				}
+			}
+		default:
+			if b, err = wire.Skip(b); err != nil {
+				return err
+			}
+		}
+	}
+	if len(b) != 0 {
+		return wire.ErrTrailingBytes
+	}
+	return nil
+}
+
+// Msgsize returns an upper bound of the encoded size of z, fields encoded
+// through reflection are estimated
+func (z Image) Msgsize() int {
+	s := wire.HeaderSize
+	s += 4
+	s += wire.HeaderSize + len(z.Src)
+	s += 6
+	s += wire.NilSize
+	if z.Alpha != nil {
+		s += wire.IntSize
+	}
+	s += 5
+	s += wire.HeaderSize
+	s += len(z.Data) * wire.IntSize
+	s += 5
+	s += wire.NilSize
+	if z.Next != nil {
+		s += (*z.Next).Msgsize()
+	}
+	return s
+}
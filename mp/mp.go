@@ -1,17 +1,230 @@
 package mp
 
 import (
+	"io"
+
+	"github.com/romanzac/json-mp/mp/cbor"
+	"github.com/romanzac/json-mp/mp/csv"
 	"github.com/romanzac/json-mp/mp/decoding"
+	"github.com/romanzac/json-mp/mp/diff"
 	"github.com/romanzac/json-mp/mp/encoding"
+	"github.com/romanzac/json-mp/mp/inspect"
+	"github.com/romanzac/json-mp/mp/patch"
+	"github.com/romanzac/json-mp/mp/path"
+	"github.com/romanzac/json-mp/mp/stats"
+	"github.com/romanzac/json-mp/mp/stream"
+	"github.com/romanzac/json-mp/mp/validate"
 )
 
+// Difference is a structural difference between two MessagePack documents
+type Difference = diff.Difference
+
+// DiffOptions controls which differences Diff reports
+type DiffOptions = diff.Options
+
+// ValidationError is returned by Validate with the offset of the first problem
+type ValidationError = validate.Error
+
+// Marshaler is implemented by types which encode themselves. Marshal uses it
+// instead of reflection, json-mp gen writes it for struct types.
+type Marshaler = encoding.Marshaler
+
+// Unmarshaler is implemented by types which decode themselves from exactly one value
+type Unmarshaler = decoding.Unmarshaler
+
 // Marshal returns the MessagePack byte array of data in v with shape defined in JSONData
 func Marshal(v interface{}) ([]byte, error) {
 	return encoding.Encode(v)
 }
 
+// AppendMarshal appends the MessagePack encoding of v to dst and returns the
+// extended slice. Reusing dst avoids allocating a new slice for every value.
+func AppendMarshal(dst []byte, v interface{}) ([]byte, error) {
+	return encoding.Append(dst, v)
+}
+
+// MarshalTo writes the MessagePack encoding of v into buf and returns the
+// number of bytes written. io.ErrShortBuffer is returned when buf is too small.
+func MarshalTo(buf []byte, v interface{}) (int, error) {
+	d, err := encoding.Append(buf[:0:len(buf)], v)
+	if err != nil {
+		return 0, err
+	}
+	if len(d) > len(buf) {
+		return 0, io.ErrShortBuffer
+	}
+	return len(d), nil
+}
+
+// Encoder encodes values into a buffer which is reused after Reset, so a
+// sync.Pool of Encoders serves many small messages without allocating
+type Encoder = encoding.Encoder
+
 // Unmarshal reads the MessagePack-encoded data and interprets them according to
-// shape object stored in JSONData (v)
+// shape object stored in JSONData (v). bin decodes to []byte and timestamp ext
+// to time.Time, other ext types are errors.
 func Unmarshal(data []byte, v interface{}) error {
 	return decoding.Decode(data, v)
 }
+
+// UnmarshalOptions controls Unmarshal, the zero value is what Unmarshal uses
+type UnmarshalOptions = decoding.Options
+
+// UnmarshalWithOptions is Unmarshal with options. With ZeroCopy strings and
+// []byte in v alias data, which must then stay unmodified while they are in use.
+func UnmarshalWithOptions(data []byte, v interface{}, opts UnmarshalOptions) error {
+	return decoding.DecodeWithOptions(data, v, opts)
+}
+
+// NumberMode selects the Go types numbers decode to in interface values
+type NumberMode = decoding.NumberMode
+
+// Number modes of UnmarshalOptions, see decoding.NumberMode
+const (
+	NumbersAsWire     = decoding.NumbersAsWire
+	NumbersNormalized = decoding.NumbersNormalized
+	NumbersAsNumber   = decoding.NumbersAsNumber
+)
+
+// Number is an int, uint or float which keeps its MessagePack format, decoded
+// with NumbersAsNumber or into Number fields and marshalled back unchanged
+type Number = decoding.Number
+
+// OrderedMap keeps its keys in insertion order when written to MessagePack or
+// JSON. Unmarshalling JSON into it, or MessagePack with the OrderedMaps option,
+// keeps the key order of the source.
+type OrderedMap = decoding.OrderedMap
+
+// NewOrderedMap returns an empty OrderedMap with room for n keys
+func NewOrderedMap(n int) *OrderedMap {
+	return decoding.NewOrderedMap(n)
+}
+
+// FromJSON transcodes a stream of JSON values from r to MessagePack on w
+// without holding the document in memory, object keys keep their order
+func FromJSON(w io.Writer, r io.Reader) error {
+	return stream.FromJSON(w, r)
+}
+
+// JSONOptions controls ToJSON
+type JSONOptions = stream.JSONOptions
+
+// NonFiniteMode selects how ToJSON writes NaN and infinities
+type NonFiniteMode = stream.NonFiniteMode
+
+// NonFinite modes of JSONOptions, see stream.NonFiniteMode
+const (
+	NonFiniteError  = stream.NonFiniteError
+	NonFiniteNull   = stream.NonFiniteNull
+	NonFiniteString = stream.NonFiniteString
+)
+
+// ToJSON transcodes a stream of MessagePack values from r to JSON on w
+// without holding the document in memory, see stream.ToJSON for the mapping
+// of bin, ext, non-string keys and NaN
+func ToJSON(w io.Writer, r io.Reader, opts JSONOptions) error {
+	return stream.ToJSON(w, r, opts)
+}
+
+// CBOROptions maps CBOR tags to ext types for FromCBOR and ToCBOR
+type CBOROptions = cbor.Options
+
+// FromCBOR transcodes a sequence of CBOR (RFC 8949) data items to MessagePack,
+// see cbor.ToMsgpack for the mapping of tags, timestamps and byte strings
+func FromCBOR(data []byte, opts CBOROptions) ([]byte, error) {
+	return cbor.ToMsgpack(data, opts)
+}
+
+// ToCBOR transcodes a sequence of MessagePack values to CBOR data items
+func ToCBOR(data []byte, opts CBOROptions) ([]byte, error) {
+	return cbor.FromMsgpack(data, opts)
+}
+
+// CSVOptions controls FromCSV
+type CSVOptions = csv.Options
+
+// FromCSV reads CSV with a header row from r and returns a MessagePack array of
+// records, maps whose dotted column names become nested maps or arrays of cells.
+// Cell types are inferred or taken from opts.Shape.
+func FromCSV(r io.Reader, opts CSVOptions) ([]byte, error) {
+	return csv.ToMsgpack(r, opts)
+}
+
+// ToCSV writes the MessagePack array of records in data to w as CSV, nested
+// maps are flattened to dotted column names
+func ToCSV(w io.Writer, data []byte) error {
+	return csv.FromMsgpack(w, data)
+}
+
+// StatsReport is the size and composition of MessagePack data
+type StatsReport = stats.Report
+
+// StatsKey is a map key counted by Stats
+type StatsKey = stats.Key
+
+// Stats reports the size of data against compact JSON, the bytes spent on keys,
+// strings, numbers and headers, the topKeys most frequent keys, the format
+// histogram and the deepest nesting
+func Stats(data []byte, topKeys int) (*StatsReport, error) {
+	return stats.Compute(data, topKeys)
+}
+
+// Get returns the raw MessagePack value at JSON Pointer path p (e.g. "/widget/window/title")
+func Get(data []byte, p string) ([]byte, error) {
+	return path.Get(data, p)
+}
+
+// Set returns a copy of data with the value at path p replaced by v. Only the
+// enclosing map or array header is rewritten, untouched siblings are copied as bytes.
+func Set(data []byte, p string, v interface{}) ([]byte, error) {
+	return path.Set(data, p, v)
+}
+
+// Delete returns a copy of data with the map entry or array element at path p removed
+func Delete(data []byte, p string) ([]byte, error) {
+	return path.Delete(data, p)
+}
+
+// ApplyPatch applies a JSON Patch (RFC 6902) to the MessagePack document data.
+// Either all operations succeed or an error is returned and data is left as is.
+func ApplyPatch(data, jsonPatch []byte) ([]byte, error) {
+	return patch.Apply(data, jsonPatch)
+}
+
+// MergePatch applies a JSON Merge Patch (RFC 7396) to the MessagePack document data
+func MergePatch(data, jsonPatch []byte) ([]byte, error) {
+	return patch.MergePatch(data, jsonPatch)
+}
+
+// CreatePatch returns the JSON Patch (RFC 6902) which turns MessagePack document a into b
+func CreatePatch(a, b []byte) ([]byte, error) {
+	return patch.Create(a, b)
+}
+
+// CreateMergePatch returns the JSON Merge Patch (RFC 7396) which turns MessagePack document a into b
+func CreateMergePatch(a, b []byte) ([]byte, error) {
+	return patch.CreateMergePatch(a, b)
+}
+
+// Diff returns the structural differences between MessagePack documents a and b by path.
+// Map order never counts, numeric widths count unless opts.IgnoreNumericWidth is set.
+func Diff(a, b []byte, opts DiffOptions) ([]Difference, error) {
+	return diff.Diff(a, b, opts)
+}
+
+// Valid reports whether data is a single well-formed MessagePack value
+func Valid(data []byte) bool {
+	return validate.Valid(data)
+}
+
+// Validate checks data for truncation, reserved codes, invalid UTF-8 in str,
+// trailing bytes and size limits. The first problem is returned as *ValidationError.
+func Validate(data []byte) error {
+	return validate.Validate(data)
+}
+
+// Dump renders MessagePack data as an annotated tree with byte offsets and
+// format names, e.g. for test failure messages
+func Dump(data []byte) string {
+	return inspect.Dump(data, inspect.Options{})
+}
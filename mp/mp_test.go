@@ -1,15 +1,19 @@
 package mp
 
 import (
-	"encoding/hex"
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/romanzac/json-mp/mp/def"
+	"io"
 	"math"
 	"math/rand"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestIntFixMinMax(t *testing.T) {
@@ -966,13 +970,496 @@ func TestStructJump(t *testing.T) {
 
 }
 
+type recursiveNode struct {
+	Name     string
+	Children []recursiveNode
+	Next     *recursiveNode
+}
+
+func TestRecursiveType(t *testing.T) {
+	v := recursiveNode{
+		Name:     "root",
+		Children: []recursiveNode{{Name: "a"}, {Name: "b", Children: []recursiveNode{{Name: "c"}}}},
+		Next:     &recursiveNode{Name: "next"},
+	}
+
+	// Plans are compiled concurrently on first use
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var r recursiveNode
+			if err := encodeDecode(v, &r, func(d byte) bool { return def.FixMap+3 == d }); err != nil {
+				t.Error(err)
+				return
+			}
+			if !reflect.DeepEqual(v, r) {
+				t.Error("value different", v, r)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestMarshalBufferReuse(t *testing.T) {
+	a, err := Marshal("first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	big := make([]int, 100000)
+	if _, err = Marshal(big); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Marshal("other"); err != nil {
+		t.Fatal(err)
+	}
+	var s string
+	if err = Unmarshal(a, &s); err != nil || s != "first" {
+		t.Error("result must not share the pooled buffer", s, err)
+	}
+}
+
+type benchWidget struct {
+	Debug  string `json:"debug"`
+	Window struct {
+		Title  string `json:"title"`
+		Name   string `json:"name"`
+		Width  int    `json:"width"`
+		Height int    `json:"height"`
+	} `json:"window"`
+	Tags  []string               `json:"tags"`
+	Attrs map[string]interface{} `json:"attrs"`
+	Items []benchItem            `json:"items"`
+}
+
+type benchItem struct {
+	ID    uint64  `json:"id"`
+	Price float64 `json:"price"`
+	Name  string  `json:"name"`
+}
+
+func newBenchWidget() benchWidget {
+	var v benchWidget
+	v.Debug = "on"
+	v.Window.Title = "Sample Konfabulator Widget"
+	v.Window.Name = "main_window"
+	v.Window.Width, v.Window.Height = 500, 500
+	v.Tags = []string{"alpha", "beta", "gamma"}
+	v.Attrs = map[string]interface{}{"one": 1, "two": "2", "three": []interface{}{3.0, true}}
+	for i := 0; i < 20; i++ {
+		v.Items = append(v.Items, benchItem{ID: uint64(i) << 20, Price: float64(i) / 3, Name: fmt.Sprint("item", i)})
+	}
+	return v
+}
+
+func benchmarkMarshal(b *testing.B, v interface{}) {
+	d, err := Marshal(v)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(d)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Marshal(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalStruct(b *testing.B) {
+	benchmarkMarshal(b, newBenchWidget())
+}
+
+func BenchmarkMarshalMap(b *testing.B) {
+	m := map[string]interface{}{}
+	for i := 0; i < 100; i++ {
+		m[fmt.Sprint("key", i)] = map[string]interface{}{"id": i, "name": fmt.Sprint("name", i), "ok": i%2 == 0}
+	}
+	benchmarkMarshal(b, m)
+}
+
+func BenchmarkMarshalSlice(b *testing.B) {
+	s := make([]interface{}, 1000)
+	for i := range s {
+		s[i] = i * 1000
+	}
+	benchmarkMarshal(b, s)
+}
+
+func BenchmarkUnmarshalStruct(b *testing.B) {
+	d, err := Marshal(newBenchWidget())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(d)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var r benchWidget
+		if err := Unmarshal(d, &r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestAppendMarshal(t *testing.T) {
+	prefix := []byte{0x01, 0x02}
+	d, err := AppendMarshal(prefix, map[string]int{"a": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(d, []byte{0x01, 0x02, def.FixMap + 1, def.FixStr + 1, 'a', 0x01}) {
+		t.Error("value different", d)
+	}
+
+	if d, err = AppendMarshal(prefix, make(chan int)); err == nil || len(d) != len(prefix) {
+		t.Error("error must occur and dst be returned as is", d, err)
+	}
+}
+
+func TestMarshalTo(t *testing.T) {
+	buf := make([]byte, 8)
+	n, err := MarshalTo(buf, "abc")
+	if err != nil || n != 4 || !bytes.Equal(buf[:n], []byte{def.FixStr + 3, 'a', 'b', 'c'}) {
+		t.Error("value different", n, err, buf)
+	}
+	if _, err = MarshalTo(buf, "too long for buffer"); !errors.Is(err, io.ErrShortBuffer) {
+		t.Error("error must occur", err)
+	}
+}
+
+func TestEncoderReset(t *testing.T) {
+	var enc Encoder
+	if err := enc.Encode(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode("a"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc.Bytes(), []byte{0x01, def.FixStr + 1, 'a'}) {
+		t.Error("value different", enc.Bytes())
+	}
+	if err := enc.Encode([]interface{}{1, func() {}}); err == nil || enc.Len() != 3 {
+		t.Error("error must occur and buffer be kept", err, enc.Bytes())
+	}
+
+	enc.Reset()
+	if err := enc.Encode(true); err != nil || !bytes.Equal(enc.Bytes(), []byte{def.True}) {
+		t.Error("value different", enc.Bytes(), err)
+	}
+}
+
+func BenchmarkAppendMarshal(b *testing.B) {
+	v := newBenchWidget()
+	pool := sync.Pool{New: func() interface{} { return new(Encoder) }}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		enc := pool.Get().(*Encoder)
+		enc.Reset()
+		if err := enc.Encode(v); err != nil {
+			b.Fatal(err)
+		}
+		pool.Put(enc)
+	}
+}
+
+type zeroCopyValue struct {
+	S string
+	B []byte
+	I interface{}
+}
+
+func TestUnmarshalCopies(t *testing.T) {
+	d, err := Marshal(map[string]string{"S": "abc", "B": "def", "I": "ghi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v zeroCopyValue
+	if err = Unmarshal(d, &v); err != nil {
+		t.Fatal(err)
+	}
+	for i := range d {
+		d[i] = 'x'
+	}
+	if v.S != "abc" || string(v.B) != "def" || v.I != "ghi" {
+		t.Error("decoded values must not alias the input", v)
+	}
+}
+
+func TestUnmarshalZeroCopy(t *testing.T) {
+	d, err := Marshal(map[string]string{"S": "abc", "B": "def", "I": "ghi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v zeroCopyValue
+	if err = UnmarshalWithOptions(d, &v, UnmarshalOptions{ZeroCopy: true}); err != nil {
+		t.Fatal(err)
+	}
+	if v.S != "abc" || string(v.B) != "def" || v.I != "ghi" {
+		t.Fatal("value different", v)
+	}
+	for i := range d {
+		if d[i] >= 'a' && d[i] <= 'i' {
+			d[i] = 'x'
+		}
+	}
+	if v.S != "xxx" || string(v.B) != "xxx" || v.I != "xxx" {
+		t.Error("decoded values must alias the input", v)
+	}
+
+	var e string
+	if err = UnmarshalWithOptions([]byte{def.FixStr}, &e, UnmarshalOptions{ZeroCopy: true}); err != nil || e != "" {
+		t.Error("empty string", e, err)
+	}
+}
+
+func TestUnmarshalZeroCopyAllocs(t *testing.T) {
+	d, err := Marshal(strings.Repeat("a", 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s string
+	unmarshal := func(opts UnmarshalOptions) float64 {
+		return testing.AllocsPerRun(100, func() {
+			if err := UnmarshalWithOptions(d, &s, opts); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+	if c, z := unmarshal(UnmarshalOptions{}), unmarshal(UnmarshalOptions{ZeroCopy: true}); z >= c {
+		t.Error("zero-copy must allocate less", c, z)
+	}
+}
+
+func TestUnmarshalNumbersNormalized(t *testing.T) {
+	in := []interface{}{uint8(1), uint16(300), uint32(70000), uint64(math.MaxUint64), int8(-5), int16(-300), int64(math.MinInt64), float32(1.5), 2.25}
+	d, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out interface{}
+	if err = UnmarshalWithOptions(d, &out, UnmarshalOptions{Numbers: NumbersNormalized}); err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{int64(1), int64(300), int64(70000), uint64(math.MaxUint64), int64(-5), int64(-300), int64(math.MinInt64), 1.5, 2.25}
+	if !reflect.DeepEqual(out, want) {
+		t.Error("value different", out, want)
+	}
+
+	var m map[string]interface{}
+	if err = UnmarshalWithOptions([]byte{def.FixMap + 1, def.FixStr + 1, 'a', def.Uint16, 0x00, 0x01}, &m, UnmarshalOptions{Numbers: NumbersNormalized}); err != nil {
+		t.Fatal(err)
+	}
+	if m["a"] != int64(1) {
+		t.Errorf("%T %v", m["a"], m["a"])
+	}
+}
+
+func TestUnmarshalNumbersAsNumber(t *testing.T) {
+	d := []byte{def.FixArray + 6,
+		0x05,
+		def.Uint16, 0x00, 0x05,
+		def.Int32, 0xff, 0xff, 0xff, 0xfe,
+		def.Uint64, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		def.Float32, 0x3f, 0x8c, 0xcc, 0xcd,
+		def.Float64, 0x3f, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	var out []interface{}
+	if err := UnmarshalWithOptions(d, &out, UnmarshalOptions{Numbers: NumbersAsNumber}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"5", "5", "-2", "18446744073709551615", "1.1", "1.5"}
+	for i, v := range out {
+		n, ok := v.(Number)
+		if !ok || n.String() != want[i] {
+			t.Errorf("%d: %T %v, want %s", i, v, v, want[i])
+		}
+	}
+	if n := out[1].(Number); n.Code() != def.Uint16 {
+		t.Error("code different", n.Code())
+	}
+	if i, err := out[2].(Number).Int64(); err != nil || i != -2 {
+		t.Error(i, err)
+	}
+	if _, err := out[3].(Number).Int64(); err == nil {
+		t.Error("error must occur")
+	}
+	if _, err := out[2].(Number).Uint64(); err == nil {
+		t.Error("error must occur")
+	}
+
+	r, err := Marshal(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(r, d) {
+		t.Error("numbers must keep their format", r, d)
+	}
+}
+
+func TestNumberField(t *testing.T) {
+	type numbers struct {
+		A Number
+		B Number
+	}
+	d := []byte{def.FixMap + 2, def.FixStr + 1, 'A', def.Int64, 0, 0, 0, 0, 0, 0, 0, 0x07, def.FixStr + 1, 'B', def.Float32, 0x3f, 0xc0, 0x00, 0x00}
+	var v numbers
+	if err := Unmarshal(d, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.A.String() != "7" || v.B.Float64() != 1.5 {
+		t.Error("value different", v)
+	}
+	r, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(r, d) {
+		t.Error("numbers must keep their format", r, d)
+	}
+	if err = Unmarshal([]byte{def.FixMap + 1, def.FixStr + 1, 'A', def.FixStr}, &v); err == nil {
+		t.Error("error must occur")
+	}
+}
+
+func TestMarshalJSONNumber(t *testing.T) {
+	dec := json.NewDecoder(strings.NewReader(`{"id":9007199254740993,"neg":-5,"max":18446744073709551615,"f":1.5,"e":1e2,"big":[]}`))
+	dec.UseNumber()
+	var in map[string]interface{}
+	if err := dec.Decode(&in); err != nil {
+		t.Fatal(err)
+	}
+	d, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]interface{}
+	if err = UnmarshalWithOptions(d, &out, UnmarshalOptions{Numbers: NumbersNormalized}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"id": int64(9007199254740993), "neg": int64(-5), "max": uint64(math.MaxUint64), "f": 1.5, "e": 100.0, "big": []interface{}{}}
+	if !reflect.DeepEqual(out, want) {
+		t.Error("value different", out, want)
+	}
+
+	if d, err = Marshal(json.Number("300")); err != nil || !bytes.Equal(d, []byte{def.Uint16, 0x01, 0x2c}) {
+		t.Error("smallest format expected", d, err)
+	}
+	for _, n := range []json.Number{"123456789012345678901234567890", "-9223372036854775809", "abc"} {
+		if _, err = Marshal(n); err == nil {
+			t.Error("error must occur", n)
+		}
+	}
+}
+
+type celsius int
+
+func (c celsius) MarshalMsgpack() ([]byte, error) {
+	return []byte{def.FixStr + 1, 'C'}, nil
+}
+
+func TestMarshalNamedScalar(t *testing.T) {
+	for _, v := range []interface{}{celsius(1), []interface{}{celsius(1)}} {
+		d, err := Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(d, []byte{def.FixStr + 1, 'C'}) {
+			t.Error("MarshalMsgpack must be used", d)
+		}
+	}
+}
+
+func TestOrderedMapRoundTrip(t *testing.T) {
+	src := `{"z":1,"a":{"y":[true,null,"s"],"b":2.5,"x":{}},"m":18446744073709551615,"b":-1}`
+	var in OrderedMap
+	if err := json.Unmarshal([]byte(src), &in); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in.Keys(), []interface{}{"z", "a", "m", "b"}) {
+		t.Error("keys different", in.Keys())
+	}
+	d, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d[1] != def.FixStr+1 || d[2] != 'z' {
+		t.Error("first key must be z", d)
+	}
+
+	var out interface{}
+	if err = UnmarshalWithOptions(d, &out, UnmarshalOptions{OrderedMaps: true}); err != nil {
+		t.Fatal(err)
+	}
+	j, err := json.Marshal(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(j) != src {
+		t.Error("key order different", string(j))
+	}
+
+	var field struct {
+		M OrderedMap
+	}
+	if err = Unmarshal(append([]byte{def.FixMap + 1, def.FixStr + 1, 'M'}, d...), &field); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(field.M.Keys(), in.Keys()) {
+		t.Error("keys different", field.M.Keys())
+	}
+}
+
+func TestOrderedMapSetDelete(t *testing.T) {
+	m := NewOrderedMap(3)
+	m.Set("a", 1)
+	m.Set("b", 2)
+	m.Set("c", 3)
+	m.Set("a", 4)
+	m.Delete("b")
+	m.Delete("missing")
+	if v, ok := m.Get("a"); !ok || v != 4 || m.Len() != 2 {
+		t.Error("value different", v, m.Len())
+	}
+	if _, ok := m.Get("b"); ok {
+		t.Error("b must be deleted")
+	}
+	d, err := Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(d, []byte{def.FixMap + 2, def.FixStr + 1, 'a', 0x04, def.FixStr + 1, 'c', 0x03}) {
+		t.Error("value different", d)
+	}
+}
+
+func TestUnmarshalTruncated(t *testing.T) {
+	// a map whose value is missing, for every kind of field
+	data := []byte{def.FixMap + 1, def.FixStr + 1, 'A'}
+	for _, v := range []interface{}{
+		&struct{ A []int }{},
+		&struct{ A []byte }{},
+		&struct{ A [2]int }{},
+		&struct{ A map[string]int }{},
+		&struct{ A *int }{},
+		&struct{ A bool }{},
+		&struct{ A time.Time }{},
+	} {
+		if err := Unmarshal(data, v); err == nil || !strings.Contains(err.Error(), "too short bytes") {
+			t.Errorf("%T: %v", v, err)
+		}
+	}
+}
+
 func encodeDecode(v, r interface{}, j func(d byte) bool) error {
 	d, err := Marshal(v)
 	if err != nil {
 		return err
 	}
 	if !j(d[0]) {
-		return fmt.Errorf("different %s", hex.Dump(d))
+		return fmt.Errorf("different\n%s", Dump(d))
 	}
 	if err := Unmarshal(d, r); err != nil {
 		return err
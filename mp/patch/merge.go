@@ -0,0 +1,118 @@
+package patch
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/romanzac/json-mp/mp/def"
+	"github.com/romanzac/json-mp/mp/diff"
+	"github.com/romanzac/json-mp/mp/encoding"
+	"github.com/romanzac/json-mp/mp/path"
+)
+
+// MergePatch applies a JSON Merge Patch (RFC 7396) to the MessagePack document
+// and returns the patched copy. The document is left untouched on error.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	p, err := fromJSON(patch)
+	if err != nil {
+		return nil, err
+	}
+	return merge(doc, nil, p)
+}
+
+func merge(doc []byte, segs []string, patch interface{}) ([]byte, error) {
+	p := path.Format(segs)
+
+	pm, ok := patch.(map[string]interface{})
+	if !ok {
+		value, err := encoding.Encode(patch)
+		if err != nil {
+			return nil, err
+		}
+		return path.SetRaw(doc, p, value)
+	}
+
+	// Target which is not a map is replaced by an empty one
+	if cur, err := path.Get(doc, p); err != nil || !isMap(cur) {
+		if doc, err = path.SetRaw(doc, p, []byte{def.FixMap}); err != nil {
+			return nil, err
+		}
+	}
+
+	names := make([]string, 0, len(pm))
+	for k := range pm {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		var err error
+		kp := child(segs, k)
+		if pm[k] == nil {
+			// Removing a missing member is not an error
+			if _, err = path.Get(doc, path.Format(kp)); err == nil {
+				doc, err = path.Delete(doc, path.Format(kp))
+			} else {
+				err = nil
+			}
+		} else {
+			doc, err = merge(doc, kp, pm[k])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// CreateMergePatch returns the JSON Merge Patch (RFC 7396) which turns
+// MessagePack document a into b
+func CreateMergePatch(a, b []byte) ([]byte, error) {
+	va, err := decode(a)
+	if err != nil {
+		return nil, err
+	}
+	vb, err := decode(b)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergeDiff(va, vb))
+}
+
+func mergeDiff(a, b interface{}) interface{} {
+	ma, okA := diff.StringMap(a)
+	mb, okB := diff.StringMap(b)
+	if !okA || !okB {
+		return diff.JSONValue(b)
+	}
+
+	m := map[string]interface{}{}
+	for k := range ma {
+		if _, ok := mb[k]; !ok {
+			m[k] = nil
+		}
+	}
+	for k, vb := range mb {
+		va, ok := ma[k]
+		if !ok {
+			m[k] = diff.JSONValue(vb)
+			continue
+		}
+		if equal(va, vb) {
+			continue
+		}
+		if _, isA := diff.StringMap(va); isA {
+			if _, isB := diff.StringMap(vb); isB {
+				m[k] = mergeDiff(va, vb)
+				continue
+			}
+		}
+		m[k] = diff.JSONValue(vb)
+	}
+	return m
+}
+
+// child returns the path segments of a member without aliasing segs
+func child(segs []string, name string) []string {
+	return append(segs[:len(segs):len(segs)], name)
+}
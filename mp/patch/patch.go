@@ -0,0 +1,141 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/romanzac/json-mp/mp/diff"
+	"github.com/romanzac/json-mp/mp/path"
+)
+
+// Operation is a single JSON Patch (RFC 6902) operation
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Apply applies a JSON Patch (RFC 6902) document to the MessagePack document
+// and returns the patched copy. Operations are applied atomically, the first
+// failing one (including a failed "test") discards all previous changes.
+func Apply(doc, patch []byte) ([]byte, error) {
+	var ops []Operation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, err
+	}
+	return ApplyOperations(doc, ops)
+}
+
+// ApplyOperations is like Apply, but takes already decoded operations
+func ApplyOperations(doc []byte, ops []Operation) ([]byte, error) {
+	var err error
+	for i, op := range ops {
+		if doc, err = apply(doc, op); err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+func apply(doc []byte, op Operation) ([]byte, error) {
+	switch op.Op {
+	case "add":
+		value, err := encodeJSON(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		return path.AddRaw(doc, op.Path, value)
+
+	case "remove":
+		return path.Delete(doc, op.Path)
+
+	case "replace":
+		value, err := encodeJSON(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = path.Get(doc, op.Path); err != nil {
+			return nil, err
+		}
+		return path.SetRaw(doc, op.Path, value)
+
+	case "move":
+		if op.From == op.Path {
+			return doc, nil
+		}
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, fmt.Errorf("can not move %s into its own child", op.From)
+		}
+		value, err := path.Get(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		if doc, err = path.Delete(doc, op.From); err != nil {
+			return nil, err
+		}
+		return path.AddRaw(doc, op.Path, value)
+
+	case "copy":
+		value, err := path.Get(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return path.AddRaw(doc, op.Path, value)
+
+	case "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("missing value")
+		}
+		expected, err := fromJSON(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := path.Get(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		actual, err := decode(raw)
+		if err != nil {
+			return nil, err
+		}
+		if !equal(expected, actual) {
+			return nil, fmt.Errorf("test failed, value is %v", diff.JSONValue(actual))
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unknown operation %q", op.Op)
+}
+
+// Create returns the JSON Patch (RFC 6902) which turns MessagePack document a into b
+func Create(a, b []byte) ([]byte, error) {
+	va, err := decode(a)
+	if err != nil {
+		return nil, err
+	}
+	vb, err := decode(b)
+	if err != nil {
+		return nil, err
+	}
+
+	ops := []Operation{}
+	for _, d := range diff.Values(va, vb, diff.Options{IgnoreNumericWidth: true}) {
+		op := Operation{Path: d.Path}
+		switch d.Kind {
+		case diff.Added:
+			op.Op = "add"
+		case diff.Removed:
+			op.Op = "remove"
+		default:
+			op.Op = "replace"
+		}
+		if d.Kind != diff.Removed {
+			if op.Value, err = json.Marshal(d.To); err != nil {
+				return nil, err
+			}
+		}
+		ops = append(ops, op)
+	}
+	return json.Marshal(ops)
+}
@@ -0,0 +1,98 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/romanzac/json-mp/mp/decoding"
+	"github.com/romanzac/json-mp/mp/def"
+	"github.com/romanzac/json-mp/mp/diff"
+	"github.com/romanzac/json-mp/mp/encoding"
+)
+
+// decode returns the schemaless Go value of MessagePack data
+func decode(data []byte) (interface{}, error) {
+	var v interface{}
+	if err := decoding.Decode(data, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// encodeJSON converts JSON text into a MessagePack value
+func encodeJSON(data []byte) ([]byte, error) {
+	if data == nil {
+		return nil, errors.New("missing value")
+	}
+	v, err := fromJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return encoding.Encode(v)
+}
+
+// fromJSON decodes JSON text keeping integers as int64 or uint64
+func fromJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("invalid JSON input")
+	}
+	return fromNumbers(v)
+}
+
+// fromNumbers replaces json.Number in v by int64, uint64 or float64. Integers
+// beyond 64 bits are rejected as the encoder does, rather than rounded.
+func fromNumbers(v interface{}) (interface{}, error) {
+	var err error
+	switch t := v.(type) {
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i, nil
+		}
+		if u, err := strconv.ParseUint(string(t), 10, 64); err == nil {
+			return u, nil
+		}
+		if !strings.ContainsAny(string(t), ".eE") {
+			return nil, fmt.Errorf("integer %s does not fit in 64 bits", t)
+		}
+		return t.Float64()
+	case []interface{}:
+		for i := range t {
+			if t[i], err = fromNumbers(t[i]); err != nil {
+				return nil, err
+			}
+		}
+	case map[string]interface{}:
+		for k := range t {
+			if t[k], err = fromNumbers(t[k]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v, nil
+}
+
+// isMap reports whether the raw MessagePack value is a map
+func isMap(data []byte) bool {
+	if len(data) < 1 {
+		return false
+	}
+	code := data[0]
+	return def.FixMap <= code && code <= def.FixMap+0x0f || code == def.Map16 || code == def.Map32
+}
+
+// equal compares decoded values regardless of map order and numeric width
+func equal(a, b interface{}) bool {
+	return diff.Equal(a, b, diff.Options{IgnoreNumericWidth: true})
+}
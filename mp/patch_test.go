@@ -0,0 +1,160 @@
+package mp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	v := map[string]interface{}{
+		"name": "a",
+		"tags": []interface{}{"x", "y"},
+		"size": 3,
+	}
+	d, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err = ApplyPatch(d, []byte(`[
+		{"op": "test", "path": "/size", "value": 3.0},
+		{"op": "add", "path": "/tags/1", "value": "z"},
+		{"op": "replace", "path": "/name", "value": {"first": "b"}},
+		{"op": "copy", "from": "/name/first", "path": "/last"},
+		{"op": "move", "from": "/size", "path": "/tags/-"},
+		{"op": "remove", "path": "/tags/0"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var r struct {
+		Name map[string]string `json:"name"`
+		Tags []interface{}     `json:"tags"`
+		Last string            `json:"last"`
+	}
+	if err = Unmarshal(d, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Name["first"] != "b" || r.Last != "b" || len(r.Tags) != 3 || r.Tags[0] != "z" || r.Tags[1] != "y" {
+		t.Error("error:", r)
+	}
+}
+
+func TestApplyPatchAtomic(t *testing.T) {
+	d, err := Marshal(map[string]int{"a": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := append([]byte(nil), d...)
+
+	r, err := ApplyPatch(d, []byte(`[
+		{"op": "add", "path": "/b", "value": 2},
+		{"op": "test", "path": "/a", "value": 5}
+	]`))
+	if err == nil || !strings.Contains(err.Error(), "test failed") || r != nil {
+		t.Error("error must occur", err)
+	}
+	if !reflect.DeepEqual(orig, d) {
+		t.Error("document changed")
+	}
+
+	for _, p := range []string{
+		`[{"op": "remove", "path": "/missing"}]`,
+		`[{"op": "replace", "path": "/b", "value": 1}]`,
+		`[{"op": "move", "from": "", "path": "/a/b"}]`,
+		`[{"op": "add", "path": "/c"}]`,
+		`[{"op": "unknown", "path": "/a"}]`,
+		`[{"op": "add", "path": "/big", "value": 123456789012345678901234}]`,
+		`[{"op": "test", "path": "/a", "value": [18446744073709551616]}]`,
+	} {
+		if _, err = ApplyPatch(d, []byte(p)); err == nil {
+			t.Error("error must occur:", p)
+		}
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	v := map[string]interface{}{
+		"title":  "Goodbye!",
+		"author": map[string]interface{}{"givenName": "John", "familyName": "Doe"},
+		"tags":   []interface{}{"example", "sample"},
+	}
+	d, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err = MergePatch(d, []byte(`{
+		"title": "Hello!",
+		"phoneNumber": "+01-123-456-7890",
+		"author": {"familyName": null},
+		"tags": ["example"],
+		"missing": null
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type author struct {
+		GivenName  string `json:"givenName"`
+		FamilyName string `json:"familyName"`
+	}
+	var r map[string]interface{}
+	var a struct {
+		Author author `json:"author"`
+	}
+	if err = Unmarshal(d, &r); err != nil {
+		t.Fatal(err)
+	}
+	if err = Unmarshal(d, &a); err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 4 || r["title"] != "Hello!" || r["phoneNumber"] != "+01-123-456-7890" || len(r["tags"].([]interface{})) != 1 {
+		t.Error("error:", r)
+	}
+	if a.Author != (author{GivenName: "John"}) {
+		t.Error("error:", a)
+	}
+
+	// integers beyond 64 bits are not rounded to float
+	if _, err = MergePatch(d, []byte(`{"author": {"id": 123456789012345678901234}}`)); err == nil ||
+		!strings.Contains(err.Error(), "does not fit in 64 bits") {
+		t.Error("error must occur", err)
+	}
+}
+
+func TestCreatePatch(t *testing.T) {
+	a, err := Marshal(map[string]interface{}{
+		"a": 1, "b": []interface{}{1, 2, 3}, "c": map[string]interface{}{"d": "e"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Marshal(map[string]interface{}{
+		"a": int64(1), "b": []interface{}{1, 5}, "c": map[string]interface{}{"f": "g"}, "h": nil,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, create := range []func(a, b []byte) ([]byte, error){CreatePatch, CreateMergePatch} {
+		p, err := create(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		apply := ApplyPatch
+		if strings.HasPrefix(string(p), "{") {
+			apply = MergePatch
+		}
+		r, err := apply(a, p)
+		if err != nil {
+			t.Fatal(string(p), err)
+		}
+		// Only a JSON Patch can add a member with null value
+		if p, err = CreatePatch(r, b); err != nil || (string(p) != "[]" && string(p) != `[{"op":"add","path":"/h","value":null}]`) {
+			t.Error("error:", string(p), err)
+		}
+	}
+}
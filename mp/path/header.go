@@ -0,0 +1,104 @@
+package path
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/romanzac/json-mp/mp/def"
+	"github.com/romanzac/json-mp/mp/wire"
+)
+
+// header is the format code of the value at start and, for maps and arrays,
+// their number of entries
+type header struct {
+	isMap   bool
+	isArray bool
+	code    byte
+	length  int // number of entries of maps and arrays
+	start   int // offset of the format code
+	end     int // offset of the first byte after the header
+}
+
+func readHeader(data []byte, offset int) (header, error) {
+	if len(data) < offset+def.Byte1 {
+		return header{}, errors.New("too short bytes")
+	}
+	b := data[offset:]
+	h := header{code: b[0], start: offset, end: offset + def.Byte1}
+
+	var rest []byte
+	var err error
+	switch wire.NextType(b) {
+	case wire.MapType:
+		h.isMap = true
+		h.length, rest, err = wire.ReadMapHeader(b)
+	case wire.ArrayType:
+		h.isArray = true
+		h.length, rest, err = wire.ReadArrayHeader(b)
+	default:
+		return h, nil
+	}
+	if err != nil {
+		return header{}, err
+	}
+	h.end = len(data) - len(rest)
+	return h, nil
+}
+
+// writeHeader encodes container header h with new length l. The original
+// header width is kept when possible, fixmap/fixarray are promoted when needed.
+func writeHeader(h header, l int) ([]byte, error) {
+	fix, code16, code32 := byte(def.FixArray), byte(def.Array16), byte(def.Array32)
+	if h.isMap {
+		fix, code16, code32 = def.FixMap, def.Map16, def.Map32
+	}
+
+	switch {
+	case l < 0:
+		return nil, fmt.Errorf("invalid container length : %d", l)
+	case l <= 0x0f && h.code != code16 && h.code != code32:
+		return []byte{fix + byte(l)}, nil
+	case l <= math.MaxUint16 && h.code != code32:
+		return []byte{code16, byte(l >> 8), byte(l)}, nil
+	case uint(l) <= math.MaxUint32:
+		return []byte{code32, byte(l >> 24), byte(l >> 16), byte(l >> 8), byte(l)}, nil
+	}
+	return nil, fmt.Errorf("not support this container length : %d", l)
+}
+
+// readKey returns the string map key at offset and the offset of its value
+func readKey(data []byte, offset int) (string, int, error) {
+	if len(data) < offset+def.Byte1 {
+		return "", 0, errors.New("too short bytes")
+	}
+	b := data[offset:]
+	switch wire.NextType(b) {
+	case wire.MapType, wire.ArrayType:
+		return "", 0, fmt.Errorf("can not use container code for map key code: %x", b[0])
+	case wire.StrType:
+		key, rest, err := wire.ReadStringBytes(b)
+		if err != nil {
+			return "", 0, err
+		}
+		return string(key), len(data) - len(rest), nil
+	}
+	// Non string keys never match a path segment
+	end, err := skip(data, offset)
+	if err != nil {
+		return "", 0, err
+	}
+	return "", end, nil
+}
+
+// skip returns the offset of the first byte after the value at offset
+func skip(data []byte, offset int) (int, error) {
+	if len(data) < offset {
+		return 0, errors.New("too short bytes")
+	}
+	rest, err := wire.Skip(data[offset:])
+	if err != nil {
+		return 0, fmt.Errorf("value at offset %d: %v", offset, err)
+	}
+	return len(data) - len(rest), nil
+}
@@ -0,0 +1,246 @@
+package path
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/romanzac/json-mp/mp/encoding"
+)
+
+// location describes where the value addressed by a path lives in the data
+type location struct {
+	parent header // enclosing map or array of the last path segment
+	entry  int    // start of the map key or array element
+	start  int    // start of the value, -1 when the value does not exist yet
+	end    int    // end of the value, or insertion point when it does not exist
+}
+
+// Parse splits a JSON Pointer (RFC 6901) into unescaped segments
+func Parse(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if p[0] != '/' {
+		return nil, fmt.Errorf("path must start with '/': %q", p)
+	}
+	segs := strings.Split(p[1:], "/")
+	for i, s := range segs {
+		if strings.Contains(s, "~") {
+			s = strings.ReplaceAll(s, "~1", "/")
+			segs[i] = strings.ReplaceAll(s, "~0", "~")
+		}
+	}
+	return segs, nil
+}
+
+// Format joins segments into a JSON Pointer, escaping '~' and '/'
+func Format(segs []string) string {
+	var sb strings.Builder
+	for _, s := range segs {
+		sb.WriteByte('/')
+		if strings.ContainsAny(s, "~/") {
+			s = strings.ReplaceAll(s, "~", "~0")
+			s = strings.ReplaceAll(s, "/", "~1")
+		}
+		sb.WriteString(s)
+	}
+	return sb.String()
+}
+
+// Get returns the raw MessagePack bytes of the value at path p
+func Get(data []byte, p string) ([]byte, error) {
+	segs, err := Parse(p)
+	if err != nil {
+		return nil, err
+	}
+	if len(segs) == 0 {
+		end, err := skip(data, 0)
+		if err != nil {
+			return nil, err
+		}
+		return data[:end], nil
+	}
+	loc, err := find(data, segs)
+	if err != nil {
+		return nil, err
+	}
+	if loc.start < 0 {
+		return nil, fmt.Errorf("path not found: %s", p)
+	}
+	return data[loc.start:loc.end], nil
+}
+
+// Set replaces the value at path p with the encoding of v. Missing map keys
+// are added and an array index equal to its length (or "-") appends.
+// Only the parent container header is rewritten, siblings are copied as is.
+func Set(data []byte, p string, v interface{}) ([]byte, error) {
+	value, err := encoding.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return SetRaw(data, p, value)
+}
+
+// SetRaw is like Set, but takes an already encoded MessagePack value
+func SetRaw(data []byte, p string, value []byte) ([]byte, error) {
+	return put(data, p, value, false)
+}
+
+// AddRaw is like SetRaw, but inserts before an existing array element
+// instead of replacing it, as the JSON Patch "add" operation does
+func AddRaw(data []byte, p string, value []byte) ([]byte, error) {
+	return put(data, p, value, true)
+}
+
+func put(data []byte, p string, value []byte, insert bool) ([]byte, error) {
+	segs, err := Parse(p)
+	if err != nil {
+		return nil, err
+	}
+	if len(segs) == 0 {
+		return append([]byte(nil), value...), nil
+	}
+	loc, err := find(data, segs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Replace existing value, container length stays the same
+	if loc.start >= 0 && !(insert && loc.parent.isArray) {
+		return splice(data, loc.start, loc.end, value), nil
+	}
+
+	// Add new entry and increase container length
+	h := loc.parent
+	ins := value
+	if h.isMap {
+		key, err := encoding.Encode(segs[len(segs)-1])
+		if err != nil {
+			return nil, err
+		}
+		ins = append(key, value...)
+	}
+	hdr, err := writeHeader(h, h.length+1)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(data)-(h.end-h.start)+len(hdr)+len(ins))
+	out = append(out, data[:h.start]...)
+	out = append(out, hdr...)
+	out = append(out, data[h.end:loc.entry]...)
+	out = append(out, ins...)
+	out = append(out, data[loc.entry:]...)
+	return out, nil
+}
+
+// Delete removes the map entry or array element at path p
+func Delete(data []byte, p string) ([]byte, error) {
+	segs, err := Parse(p)
+	if err != nil {
+		return nil, err
+	}
+	if len(segs) == 0 {
+		return nil, fmt.Errorf("can not delete document root")
+	}
+	loc, err := find(data, segs)
+	if err != nil {
+		return nil, err
+	}
+	if loc.start < 0 {
+		return nil, fmt.Errorf("path not found: %s", p)
+	}
+
+	h := loc.parent
+	hdr, err := writeHeader(h, h.length-1)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(data)-(h.end-h.start)+len(hdr)-(loc.end-loc.entry))
+	out = append(out, data[:h.start]...)
+	out = append(out, hdr...)
+	out = append(out, data[h.end:loc.entry]...)
+	out = append(out, data[loc.end:]...)
+	return out, nil
+}
+
+func splice(data []byte, from, to int, value []byte) []byte {
+	out := make([]byte, 0, len(data)-(to-from)+len(value))
+	out = append(out, data[:from]...)
+	out = append(out, value...)
+	out = append(out, data[to:]...)
+	return out
+}
+
+// find walks the data along segs and returns the location of the last segment
+func find(data []byte, segs []string) (location, error) {
+	offset := 0
+	for i, seg := range segs {
+		last := i == len(segs)-1
+
+		h, err := readHeader(data, offset)
+		if err != nil {
+			return location{}, err
+		}
+		if !h.isMap && !h.isArray {
+			return location{}, fmt.Errorf("value at offset %d is not a container: %s", offset, Format(segs[:i]))
+		}
+
+		loc := location{parent: h, start: -1}
+		o := h.end
+		if h.isMap {
+			for j := 0; j < h.length; j++ {
+				key, vo, err := readKey(data, o)
+				if err != nil {
+					return location{}, err
+				}
+				end, err := skip(data, vo)
+				if err != nil {
+					return location{}, err
+				}
+				if loc.start < 0 && key == seg {
+					loc.entry, loc.start, loc.end = o, vo, end
+				}
+				o = end
+			}
+			if loc.start < 0 {
+				if !last {
+					return location{}, fmt.Errorf("path not found: %s", Format(segs[:i+1]))
+				}
+				loc.entry, loc.end = o, o
+			}
+		} else {
+			idx := h.length
+			if seg != "-" {
+				idx, err = strconv.Atoi(seg)
+				if err != nil || idx < 0 || (seg != "0" && seg[0] == '0') {
+					return location{}, fmt.Errorf("invalid array index %q", seg)
+				}
+			}
+			if idx > h.length || (idx == h.length && !last) {
+				return location{}, fmt.Errorf("array index %d out of range [0:%d]", idx, h.length)
+			}
+			for j := 0; j < idx; j++ {
+				o, err = skip(data, o)
+				if err != nil {
+					return location{}, err
+				}
+			}
+			loc.entry = o
+			if idx < h.length {
+				loc.start = o
+				if loc.end, err = skip(data, o); err != nil {
+					return location{}, err
+				}
+			} else {
+				loc.end = o
+			}
+		}
+
+		if last {
+			return loc, nil
+		}
+		offset = loc.start
+	}
+	return location{}, fmt.Errorf("empty path")
+}
@@ -0,0 +1,164 @@
+package mp
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/romanzac/json-mp/mp/def"
+)
+
+type pathShape struct {
+	Name  string            `json:"name"`
+	Tags  []string          `json:"tags"`
+	Attrs map[string]string `json:"attrs"`
+	Size  int               `json:"size"`
+}
+
+func TestPathGet(t *testing.T) {
+	v := pathShape{Name: "a", Tags: []string{"x", "y"}, Attrs: map[string]string{"k/1": "v"}, Size: 3}
+	d, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var s string
+	b, err := Get(d, "/tags/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Unmarshal(b, &s); err != nil || s != "y" {
+		t.Error("error:", s, err)
+	}
+
+	b, err = Get(d, "/attrs/k~11")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Unmarshal(b, &s); err != nil || s != "v" {
+		t.Error("error:", s, err)
+	}
+
+	for _, p := range []string{"/missing", "/tags/2", "/tags/01", "/size/a", "name"} {
+		if _, err = Get(d, p); err == nil {
+			t.Error("error must occur:", p)
+		}
+	}
+
+	// malformed data is rejected as wire.Skip does
+	for _, c := range []struct {
+		data []byte
+		path string
+	}{
+		{[]byte{0x82, 0xa1, 'x', 0xc1, 0xa1, 'a', 0x01}, "/a"},
+		{[]byte{0x81, 0xa1, 'a', 0xd9}, "/a"},
+		{[]byte{0x92, 0x01}, "/1"},
+		{[]byte{0xdc, 0x00}, "/0"},
+	} {
+		if _, err = Get(c.data, c.path); err == nil {
+			t.Errorf("% x: error must occur", c.data)
+		}
+	}
+}
+
+func TestPathSet(t *testing.T) {
+	v := pathShape{Name: "a", Tags: []string{"x"}, Attrs: map[string]string{}, Size: 3}
+	d, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		path  string
+		value interface{}
+	}{
+		{"/name", strings.Repeat("n", 40)},
+		{"/size", 70000},
+		{"/tags/-", "y"},
+		{"/tags/0", "z"},
+		{"/attrs/new", "w"},
+	}
+	for _, s := range steps {
+		if d, err = Set(d, s.path, s.value); err != nil {
+			t.Fatal(s.path, err)
+		}
+	}
+
+	var r pathShape
+	if err = Unmarshal(d, &r); err != nil {
+		t.Fatal(err)
+	}
+	e := pathShape{Name: strings.Repeat("n", 40), Tags: []string{"z", "y"}, Attrs: map[string]string{"new": "w"}, Size: 70000}
+	if !reflect.DeepEqual(e, r) {
+		t.Error("error:", e, r)
+	}
+
+	if _, err = Set(d, "/tags/5", "q"); err == nil {
+		t.Error("error must occur")
+	}
+}
+
+func TestPathSetPromoteFixMap(t *testing.T) {
+	m := map[string]int{}
+	for i := 0; i < 0x0f; i++ {
+		m[fmt.Sprint(i)] = i
+	}
+	d, err := Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d[0] != def.FixMap+0x0f {
+		t.Fatal("code different")
+	}
+
+	d, err = Set(d, "/15", 15)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d[0] != def.Map16 || d[1] != 0 || d[2] != 0x10 {
+		t.Errorf("code different %x", d[:3])
+	}
+	m["15"] = 15
+
+	var r map[string]int
+	if err = Unmarshal(d, &r); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, r) {
+		t.Error("error:", m, r)
+	}
+}
+
+func TestPathDelete(t *testing.T) {
+	v := pathShape{Name: "a", Tags: []string{"x", "y", "z"}, Attrs: map[string]string{"k": "v"}, Size: 3}
+	d, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{"/tags/1", "/attrs/k", "/size"} {
+		if d, err = Delete(d, p); err != nil {
+			t.Fatal(p, err)
+		}
+	}
+	if d[0] != def.FixMap+0x03 {
+		t.Errorf("code different %x", d[0])
+	}
+
+	var r pathShape
+	if err = Unmarshal(d, &r); err != nil {
+		t.Fatal(err)
+	}
+	e := pathShape{Name: "a", Tags: []string{"x", "z"}, Attrs: map[string]string{}}
+	if !reflect.DeepEqual(e, r) {
+		t.Error("error:", e, r)
+	}
+
+	if _, err = Delete(d, "/size"); err == nil {
+		t.Error("error must occur")
+	}
+	if _, err = Delete(d, ""); err == nil {
+		t.Error("error must occur")
+	}
+}
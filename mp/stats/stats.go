@@ -0,0 +1,232 @@
+// Package stats reports where the bytes of MessagePack documents go
+package stats
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/romanzac/json-mp/mp/def"
+	"github.com/romanzac/json-mp/mp/stream"
+	"github.com/romanzac/json-mp/mp/validate"
+	"github.com/romanzac/json-mp/mp/wire"
+)
+
+// Report is the size and composition of a stream of MessagePack values
+type Report struct {
+	Size     int            `json:"size"`     // MessagePack bytes
+	JSONSize int            `json:"jsonSize"` // bytes of the same values as compact JSON
+	Values   int            `json:"values"`   // top-level values
+	MaxDepth int            `json:"maxDepth"` // deepest nesting of maps and arrays
+	Bytes    Breakdown      `json:"bytes"`
+	Keys     []Key          `json:"keys"`    // most frequent map keys first
+	Formats  map[string]int `json:"formats"` // values per format name, e.g. fixstr
+}
+
+// Breakdown splits Size by what the bytes encode. Values count with their
+// format code and length, so str values are in Strings but map keys in Keys.
+type Breakdown struct {
+	Keys       int `json:"keys"`       // map keys
+	Strings    int `json:"strings"`    // str values
+	Numbers    int `json:"numbers"`    // int, uint and float values
+	Headers    int `json:"headers"`    // map and array headers
+	Binary     int `json:"binary"`     // bin and ext values
+	NilAndBool int `json:"nilAndBool"` // nil, true and false
+}
+
+// Key is a map key with the number of times it occurs and its total bytes.
+// Keys which are not str are listed in their JSON form.
+type Key struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+	Bytes int    `json:"bytes"`
+}
+
+// Compute reports on data holding one or more concatenated values, listing at
+// most topKeys keys
+func Compute(data []byte, topKeys int) (*Report, error) {
+	if len(data) == 0 {
+		return nil, &validate.Error{Offset: 0, Msg: "empty data"}
+	}
+	for o := 0; o < len(data); {
+		end, err := validate.Next(data, o, validate.DefaultLimits)
+		if err != nil {
+			return nil, err
+		}
+		o = end
+	}
+
+	c := counter{
+		r:    &Report{Size: len(data), Formats: map[string]int{}},
+		keys: map[string]*Key{},
+	}
+	for b := data; len(b) > 0; c.r.Values++ {
+		var err error
+		if b, err = c.value(b, 0, false); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, k := range c.keys {
+		c.r.Keys = append(c.r.Keys, *k)
+	}
+	sort.Slice(c.r.Keys, func(i, j int) bool {
+		a, b := c.r.Keys[i], c.r.Keys[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Key < b.Key
+	})
+	if len(c.r.Keys) > topKeys {
+		c.r.Keys = c.r.Keys[:topKeys]
+	}
+
+	var w countWriter
+	if err := stream.ToJSON(&w, bytes.NewReader(data), stream.JSONOptions{NonFinite: stream.NonFiniteNull}); err != nil {
+		return nil, err
+	}
+	// ToJSON ends every value with a newline
+	c.r.JSONSize = int(w) - c.r.Values
+	return c.r, nil
+}
+
+type counter struct {
+	r    *Report
+	keys map[string]*Key
+}
+
+// value counts the value at the start of b, which is a map key when key is
+// true, and returns the bytes following it
+func (c *counter) value(b []byte, depth int, key bool) ([]byte, error) {
+	c.r.Formats[def.FormatName(b[0])]++
+
+	t := wire.NextType(b)
+	if t == wire.MapType || t == wire.ArrayType {
+		var n int
+		var rest []byte
+		var err error
+		if t == wire.MapType {
+			n, rest, err = wire.ReadMapHeader(b)
+			n *= 2
+		} else {
+			n, rest, err = wire.ReadArrayHeader(b)
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.r.Bytes.Headers += len(b) - len(rest)
+		if depth+1 > c.r.MaxDepth {
+			c.r.MaxDepth = depth + 1
+		}
+		for i := 0; i < n; i++ {
+			if rest, err = c.value(rest, depth+1, t == wire.MapType && i%2 == 0); err != nil {
+				return nil, err
+			}
+		}
+		return rest, nil
+	}
+
+	rest, err := wire.Skip(b)
+	if err != nil {
+		return nil, err
+	}
+	size := len(b) - len(rest)
+
+	if key {
+		c.r.Bytes.Keys += size
+		c.key(b[:size])
+		return rest, nil
+	}
+	switch t {
+	case wire.StrType:
+		c.r.Bytes.Strings += size
+	case wire.IntType, wire.UintType, wire.FloatType:
+		c.r.Bytes.Numbers += size
+	case wire.BinType, wire.ExtType:
+		c.r.Bytes.Binary += size
+	default:
+		c.r.Bytes.NilAndBool += size
+	}
+	return rest, nil
+}
+
+// key counts the scalar map key b
+func (c *counter) key(b []byte) {
+	var name string
+	if s, _, err := wire.ReadStringBytes(b); err == nil && wire.NextType(b) == wire.StrType {
+		name = string(s)
+	} else {
+		var sb strings.Builder
+		stream.ToJSON(&sb, bytes.NewReader(b), stream.JSONOptions{NonFinite: stream.NonFiniteString})
+		name = strings.TrimSuffix(sb.String(), "\n")
+	}
+	k, ok := c.keys[name]
+	if !ok {
+		k = &Key{Key: name}
+		c.keys[name] = k
+	}
+	k.Count++
+	k.Bytes += len(b)
+}
+
+// countWriter counts the bytes written to it
+type countWriter int
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	*w += countWriter(len(p))
+	return len(p), nil
+}
+
+// Text formats the report as tables
+func Text(r *Report) string {
+	w := &strings.Builder{}
+	percent := func(n int) string {
+		if r.Size == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%5.1f%%", 100*float64(n)/float64(r.Size))
+	}
+
+	fmt.Fprintf(w, "%-14s %10d\n", "MessagePack", r.Size)
+	fmt.Fprintf(w, "%-14s %10d", "JSON", r.JSONSize)
+	if r.JSONSize > 0 {
+		fmt.Fprintf(w, "  (MessagePack is %.1f%% of JSON)", 100*float64(r.Size)/float64(r.JSONSize))
+	}
+	fmt.Fprintf(w, "\n%-14s %10d\n%-14s %10d\n\n", "values", r.Values, "max depth", r.MaxDepth)
+
+	fmt.Fprintln(w, "bytes")
+	for _, row := range []struct {
+		name string
+		n    int
+	}{
+		{"keys", r.Bytes.Keys}, {"strings", r.Bytes.Strings}, {"numbers", r.Bytes.Numbers},
+		{"headers", r.Bytes.Headers}, {"binary", r.Bytes.Binary}, {"nil and bool", r.Bytes.NilAndBool},
+	} {
+		fmt.Fprintf(w, "  %-12s %10d %s\n", row.name, row.n, percent(row.n))
+	}
+
+	if len(r.Keys) > 0 {
+		fmt.Fprintf(w, "\n%-32s %10s %10s\n", "keys", "count", "bytes")
+		for _, k := range r.Keys {
+			fmt.Fprintf(w, "  %-30q %10d %10d\n", k.Key, k.Count, k.Bytes)
+		}
+	}
+
+	names := make([]string, 0, len(r.Formats))
+	for name := range r.Formats {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := r.Formats[names[i]], r.Formats[names[j]]
+		if a != b {
+			return a > b
+		}
+		return names[i] < names[j]
+	})
+	fmt.Fprintf(w, "\n%-14s %10s\n", "formats", "count")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-12s %10d\n", name, r.Formats[name])
+	}
+	return w.String()
+}
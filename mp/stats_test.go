@@ -0,0 +1,84 @@
+package mp
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	doc := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"id": 1, "name": "a"},
+			map[string]interface{}{"id": 300, "name": "bc"},
+		},
+		"ok":   true,
+		"rate": 0.5,
+	}
+	d, err := Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Stats(d, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Size != len(d) || r.Values != 1 || r.MaxDepth != 3 {
+		t.Errorf("size %d, values %d, max depth %d", r.Size, r.Values, r.MaxDepth)
+	}
+	b := r.Bytes
+	if sum := b.Keys + b.Strings + b.Numbers + b.Headers + b.Binary + b.NilAndBool; sum != r.Size {
+		t.Errorf("breakdown sums to %d, expected %d", sum, r.Size)
+	}
+	// "a", "bc" and 1, 300, 0.5 as float64
+	if b.Strings != 5 || b.Numbers != 1+3+9 || b.Binary != 0 || b.NilAndBool != 1 || b.Headers != 4 {
+		t.Errorf("unexpected breakdown %+v", b)
+	}
+
+	if len(r.Keys) != 2 || r.Keys[0] != (StatsKey{Key: "id", Count: 2, Bytes: 6}) || r.Keys[1] != (StatsKey{Key: "name", Count: 2, Bytes: 10}) {
+		t.Errorf("unexpected keys %+v", r.Keys)
+	}
+	if r.Formats["fixmap"] != 3 || r.Formats["fixstr"] != 9 || r.Formats["uint16"] != 1 || r.Formats["float64"] != 1 {
+		t.Errorf("unexpected formats %v", r.Formats)
+	}
+
+	j, _ := json.Marshal(doc)
+	if r.JSONSize != len(j) {
+		t.Errorf("JSON size %d, expected %d", r.JSONSize, len(j))
+	}
+}
+
+func TestStatsValues(t *testing.T) {
+	var d []byte
+	for _, v := range []interface{}{map[int]int{1: 2}, math.NaN()} {
+		b, err := Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d = append(d, b...)
+	}
+	// bin 0x01 0x02
+	d = append(d, 0xc4, 0x02, 0x01, 0x02)
+
+	r, err := Stats(d, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// NaN is null and bin base64 in JSON
+	if r.Values != 3 || r.MaxDepth != 1 || r.JSONSize != len(`{"1":2}null"AQI="`) {
+		t.Errorf("values %d, max depth %d, JSON size %d", r.Values, r.MaxDepth, r.JSONSize)
+	}
+	if r.Bytes.Binary != 4 || r.Formats["bin8"] != 1 {
+		t.Errorf("unexpected binary %d, formats %v", r.Bytes.Binary, r.Formats)
+	}
+	if len(r.Keys) != 1 || r.Keys[0].Key != "1" {
+		t.Errorf("unexpected keys %+v", r.Keys)
+	}
+
+	for _, bad := range [][]byte{nil, {0xc1}, {0x92, 0x01}} {
+		if _, err := Stats(bad, 10); err == nil {
+			t.Errorf("% x: expected error", bad)
+		}
+	}
+}
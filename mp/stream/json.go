@@ -0,0 +1,203 @@
+// Package stream transcodes between JSON and MessagePack without building
+// the document in memory, so memory stays flat for inputs of any size.
+package stream
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/romanzac/json-mp/mp/encoding"
+	"github.com/romanzac/json-mp/mp/wire"
+)
+
+var (
+	errTooLong = errors.New("container has more than 4294967295 elements")
+	errLengths = errors.New("input changed between passes")
+)
+
+// FromJSON reads a stream of JSON values from r and writes each one to w as
+// MessagePack, in the formats mp.Marshal picks and with object keys in source
+// order. MessagePack headers carry the length of their container, so the
+// input is read twice: a seekable r, such as a regular file, is read again
+// from its current offset, any other r is spooled to a temporary file.
+// Integers beyond 64 bits are rejected.
+func FromJSON(w io.Writer, r io.Reader) error {
+	in, start, err := rereadable(r)
+	if err != nil {
+		return err
+	}
+	if s, ok := in.(*spool); ok {
+		defer s.close()
+	}
+
+	var l lengths
+	defer l.close()
+
+	if err = countJSON(json.NewDecoder(in), &l); err != nil {
+		return err
+	}
+	if _, err = in.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	if err = l.rewind(); err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	if err = writeJSON(json.NewDecoder(in), &l, bw); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// rereadable returns r when it can seek, otherwise a temporary file r is
+// copied into while it is read, together with the offset to read again from
+func rereadable(r io.Reader) (io.ReadSeeker, int64, error) {
+	if s, ok := r.(io.ReadSeeker); ok {
+		if start, err := s.Seek(0, io.SeekCurrent); err == nil {
+			return s, start, nil
+		}
+	}
+	f, err := os.CreateTemp("", "json-mp-spool-*")
+	if err != nil {
+		return nil, 0, err
+	}
+	return &spool{r: r, f: f}, 0, nil
+}
+
+// spool is a temporary file filled from r by the first pass
+type spool struct {
+	r    io.Reader
+	f    *os.File
+	done bool
+}
+
+func (s *spool) Read(p []byte) (int, error) {
+	if s.done {
+		return s.f.Read(p)
+	}
+	n, err := s.r.Read(p)
+	if n > 0 {
+		if _, werr := s.f.Write(p[:n]); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func (s *spool) close() {
+	s.f.Close()
+	os.Remove(s.f.Name())
+}
+
+func (s *spool) Seek(offset int64, whence int) (int64, error) {
+	s.done = true
+	return s.f.Seek(offset, whence)
+}
+
+// frame is a container open in the first pass
+type frame struct {
+	index int
+	n     int
+}
+
+// countJSON records the element count of every container, object members
+// count twice as the key is a token of its own. Numbers are checked here so
+// nothing is written for input which fails.
+func countJSON(dec *json.Decoder, l *lengths) error {
+	dec.UseNumber()
+	var stack []frame
+	var b []byte
+	for {
+		t, err := dec.Token()
+		if err == io.EOF {
+			if len(stack) > 0 {
+				return io.ErrUnexpectedEOF
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t {
+		case json.Delim('}'), json.Delim(']'):
+			f := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if t == json.Delim('}') {
+				f.n /= 2
+			}
+			if err = l.set(f.index, f.n); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if len(stack) > 0 {
+			stack[len(stack)-1].n++
+		}
+		switch t := t.(type) {
+		case json.Delim:
+			i, err := l.open()
+			if err != nil {
+				return err
+			}
+			stack = append(stack, frame{index: i})
+		case json.Number:
+			if b, err = encoding.AppendJSONNumber(b[:0], t); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// writeJSON writes the tokens of dec as MessagePack with the recorded lengths
+func writeJSON(dec *json.Decoder, l *lengths, w *bufio.Writer) error {
+	dec.UseNumber()
+	b := make([]byte, 0, 64)
+	for {
+		t, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		b = b[:0]
+		switch t := t.(type) {
+		case json.Delim:
+			if t == '}' || t == ']' {
+				continue
+			}
+			n, err := l.read()
+			if err != nil {
+				return err
+			}
+			if t == '{' {
+				b = wire.AppendMapHeader(b, n)
+			} else {
+				b = wire.AppendArrayHeader(b, n)
+			}
+		case string:
+			b = wire.AppendString(b, t)
+		case json.Number:
+			if b, err = encoding.AppendJSONNumber(b, t); err != nil {
+				return err
+			}
+		case bool:
+			b = wire.AppendBool(b, t)
+		case nil:
+			b = wire.AppendNil(b)
+		default:
+			return fmt.Errorf("unexpected JSON token %v", t)
+		}
+		if _, err = w.Write(b); err != nil {
+			return err
+		}
+	}
+}
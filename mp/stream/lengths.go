@@ -0,0 +1,103 @@
+package stream
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+)
+
+// Lengths kept in memory before they are spilled to a temporary file
+var maxPendingLengths = 64 * 1024
+
+// lengths records the number of elements of every container by the order the
+// containers open in. Containers close in a different order, so lengths of
+// containers still open when pending is spilled are written in place later.
+type lengths struct {
+	file    *os.File
+	pending []uint32
+	flushed int // lengths written to file
+	next    int // index of the next length to read
+	r       *bufio.Reader
+}
+
+// open reserves the length of a new container and returns its index
+func (l *lengths) open() (int, error) {
+	if len(l.pending) == maxPendingLengths {
+		if err := l.spill(); err != nil {
+			return 0, err
+		}
+	}
+	l.pending = append(l.pending, 0)
+	return l.flushed + len(l.pending) - 1, nil
+}
+
+// set stores length n of container i
+func (l *lengths) set(i int, n int) error {
+	if int64(n) > math.MaxUint32 {
+		return errTooLong
+	}
+	if i >= l.flushed {
+		l.pending[i-l.flushed] = uint32(n)
+		return nil
+	}
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(n))
+	_, err := l.file.WriteAt(b[:], int64(i)*4)
+	return err
+}
+
+func (l *lengths) spill() (err error) {
+	if l.file == nil {
+		if l.file, err = os.CreateTemp("", "json-mp-lengths-*"); err != nil {
+			return err
+		}
+	}
+	b := make([]byte, 0, len(l.pending)*4)
+	for _, n := range l.pending {
+		b = binary.BigEndian.AppendUint32(b, n)
+	}
+	if _, err = l.file.WriteAt(b, int64(l.flushed)*4); err != nil {
+		return err
+	}
+	l.flushed += len(l.pending)
+	l.pending = l.pending[:0]
+	return nil
+}
+
+// rewind prepares reading the lengths from the first container on
+func (l *lengths) rewind() error {
+	l.next = 0
+	if l.file == nil {
+		return nil
+	}
+	if err := l.spill(); err != nil {
+		return err
+	}
+	l.r = bufio.NewReader(io.NewSectionReader(l.file, 0, int64(l.flushed)*4))
+	return nil
+}
+
+// read returns the length of the next container
+func (l *lengths) read() (int, error) {
+	if l.r == nil {
+		if l.next >= len(l.pending) {
+			return 0, errLengths
+		}
+		l.next++
+		return int(l.pending[l.next-1]), nil
+	}
+	var b [4]byte
+	if _, err := io.ReadFull(l.r, b[:]); err != nil {
+		return 0, errLengths
+	}
+	return int(binary.BigEndian.Uint32(b[:])), nil
+}
+
+func (l *lengths) close() {
+	if l.file != nil {
+		l.file.Close()
+		os.Remove(l.file.Name())
+	}
+}
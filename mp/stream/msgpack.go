@@ -0,0 +1,396 @@
+package stream
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/romanzac/json-mp/mp/def"
+	"github.com/romanzac/json-mp/mp/validate"
+)
+
+// NonFiniteMode selects how ToJSON writes NaN and infinities, which JSON lacks
+type NonFiniteMode int
+
+const (
+	// NonFiniteError fails the transcoding, like encoding/json. It is the default.
+	NonFiniteError NonFiniteMode = iota
+	// NonFiniteNull writes null
+	NonFiniteNull
+	// NonFiniteString writes "NaN", "Infinity" or "-Infinity"
+	NonFiniteString
+)
+
+// JSONOptions controls ToJSON
+type JSONOptions struct {
+	// Indent pretty-prints with Indent per level like json.MarshalIndent, when not empty
+	Indent    string
+	NonFinite NonFiniteMode
+}
+
+// Strings and binaries are copied in chunks of this size
+const chunkSize = 4096
+
+// ToJSON reads a stream of MessagePack values from r and writes each one to w
+// as JSON followed by a newline, holding no more than one chunk of a str or
+// bin in memory. Types JSON lacks are mapped as follows:
+//
+//   - bin is a base64 string
+//   - ext is an object {"$ext":type,"data":"base64 payload"}
+//   - map keys other than str are stringified, e.g. 1 becomes "1"
+//   - NaN and infinities are written as set by opts.NonFinite
+//
+// Values written before an error are not taken back.
+func ToJSON(w io.Writer, r io.Reader, opts JSONOptions) error {
+	t := &toJSON{r: bufio.NewReader(r), w: bufio.NewWriter(w), opts: opts}
+	for {
+		if _, err := t.r.Peek(1); err == io.EOF {
+			break
+		}
+		if err := t.value(0); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		t.w.WriteByte('\n')
+	}
+	return t.w.Flush()
+}
+
+type toJSON struct {
+	r    *bufio.Reader
+	w    *bufio.Writer
+	opts JSONOptions
+	b    [8]byte
+}
+
+// value transcodes the next value at nesting depth
+func (t *toJSON) value(depth int) error {
+	code, err := t.r.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case code <= def.FixIntMax:
+		t.w.Write(strconv.AppendUint(t.b[:0], uint64(code), 10))
+		return nil
+	case code >= 0xe0:
+		t.w.Write(strconv.AppendInt(t.b[:0], int64(int8(code)), 10))
+		return nil
+	case def.FixMap <= code && code <= def.FixMap+0x0f:
+		return t.object(int(code-def.FixMap), depth)
+	case def.FixArray <= code && code <= def.FixArray+0x0f:
+		return t.array(int(code-def.FixArray), depth)
+	case def.FixStr <= code && code <= def.FixStr+0x1f:
+		return t.str(int(code - def.FixStr))
+	case def.FixExt1 <= code && code <= def.FixExt16:
+		return t.ext(1<<(code-def.FixExt1), depth)
+	}
+
+	switch code {
+	case def.Nil:
+		t.w.WriteString("null")
+	case def.False:
+		t.w.WriteString("false")
+	case def.True:
+		t.w.WriteString("true")
+
+	case def.Uint8, def.Uint16, def.Uint32, def.Uint64:
+		u, err := t.uint(1 << (code - def.Uint8))
+		if err != nil {
+			return err
+		}
+		t.w.Write(strconv.AppendUint(t.b[:0], u, 10))
+	case def.Int8, def.Int16, def.Int32, def.Int64:
+		size := 1 << (code - def.Int8)
+		u, err := t.uint(size)
+		if err != nil {
+			return err
+		}
+		// sign extend from size bytes
+		shift := 64 - 8*size
+		t.w.Write(strconv.AppendInt(t.b[:0], int64(u<<shift)>>shift, 10))
+	case def.Float32:
+		u, err := t.uint(4)
+		if err != nil {
+			return err
+		}
+		return t.float(float64(math.Float32frombits(uint32(u))), 32)
+	case def.Float64:
+		u, err := t.uint(8)
+		if err != nil {
+			return err
+		}
+		return t.float(math.Float64frombits(u), 64)
+
+	case def.Str8, def.Str16, def.Str32:
+		l, err := t.uint(1 << (code - def.Str8))
+		if err != nil {
+			return err
+		}
+		return t.str(int(l))
+	case def.Bin8, def.Bin16, def.Bin32:
+		l, err := t.uint(1 << (code - def.Bin8))
+		if err != nil {
+			return err
+		}
+		return t.base64(int(l))
+	case def.Ext8, def.Ext16, def.Ext32:
+		l, err := t.uint(1 << (code - def.Ext8))
+		if err != nil {
+			return err
+		}
+		return t.ext(int(l), depth)
+
+	case def.Array16, def.Array32:
+		l, err := t.uint(2 << (code - def.Array16))
+		if err != nil {
+			return err
+		}
+		return t.array(int(l), depth)
+	case def.Map16, def.Map32:
+		l, err := t.uint(2 << (code - def.Map16))
+		if err != nil {
+			return err
+		}
+		return t.object(int(l), depth)
+
+	default:
+		return fmt.Errorf("invalid code %x", code)
+	}
+	return nil
+}
+
+// uint reads a big-endian unsigned integer of size bytes
+func (t *toJSON) uint(size int) (uint64, error) {
+	b := t.b[:size]
+	if _, err := io.ReadFull(t.r, b); err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// float writes f formatted like encoding/json
+func (t *toJSON) float(f float64, bits int) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		switch t.opts.NonFinite {
+		case NonFiniteNull:
+			t.w.WriteString("null")
+			return nil
+		case NonFiniteString:
+			s := "NaN"
+			if math.IsInf(f, 1) {
+				s = "Infinity"
+			} else if math.IsInf(f, -1) {
+				s = "-Infinity"
+			}
+			t.w.WriteString(`"` + s + `"`)
+			return nil
+		}
+		return fmt.Errorf("unsupported float value %v", f)
+	}
+	var v interface{} = f
+	if bits == 32 {
+		v = float32(f)
+	}
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	t.w.Write(bs)
+	return nil
+}
+
+// str writes a str of l bytes as JSON string, escaped like encoding/json. A
+// rune split between chunks is carried over to the next one.
+func (t *toJSON) str(l int) error {
+	t.w.WriteByte('"')
+	var chunk [chunkSize]byte
+	carry := 0
+	for l > 0 {
+		n := len(chunk) - carry
+		if n > l {
+			n = l
+		}
+		if _, err := io.ReadFull(t.r, chunk[carry:carry+n]); err != nil {
+			return err
+		}
+		l -= n
+		end := carry + n
+		carry = 0
+		if l > 0 {
+			for i := end - 1; i >= 0 && i > end-utf8.UTFMax; i-- {
+				if utf8.RuneStart(chunk[i]) {
+					if !utf8.FullRune(chunk[i:end]) {
+						carry = end - i
+					}
+					break
+				}
+			}
+		}
+		if err := t.escape(chunk[:end-carry]); err != nil {
+			return err
+		}
+		copy(chunk[:], chunk[end-carry:end])
+	}
+	t.w.WriteByte('"')
+	return nil
+}
+
+// escape writes s escaped without the surrounding quotes
+func (t *toJSON) escape(s []byte) error {
+	bs, err := json.Marshal(string(s))
+	if err != nil {
+		return err
+	}
+	t.w.Write(bs[1 : len(bs)-1])
+	return nil
+}
+
+// base64 writes l bytes as base64 string
+func (t *toJSON) base64(l int) error {
+	t.w.WriteByte('"')
+	enc := base64.NewEncoder(base64.StdEncoding, t.w)
+	if _, err := io.CopyN(enc, t.r, int64(l)); err != nil {
+		return err
+	}
+	enc.Close()
+	t.w.WriteByte('"')
+	return nil
+}
+
+// ext writes an ext with an l byte payload as tagged object
+func (t *toJSON) ext(l int, depth int) error {
+	typ, err := t.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	t.w.WriteByte('{')
+	t.newline(depth + 1)
+	t.w.WriteString(`"$ext":`)
+	t.space()
+	t.w.Write(strconv.AppendInt(t.b[:0], int64(int8(typ)), 10))
+	t.w.WriteByte(',')
+	t.newline(depth + 1)
+	t.w.WriteString(`"data":`)
+	t.space()
+	if err = t.base64(l); err != nil {
+		return err
+	}
+	t.newline(depth)
+	t.w.WriteByte('}')
+	return nil
+}
+
+func (t *toJSON) array(l int, depth int) error {
+	if depth >= validate.DefaultLimits.MaxDepth {
+		return fmt.Errorf("nesting exceeds max depth %d", validate.DefaultLimits.MaxDepth)
+	}
+	t.w.WriteByte('[')
+	for i := 0; i < l; i++ {
+		if i > 0 {
+			t.w.WriteByte(',')
+		}
+		t.newline(depth + 1)
+		if err := t.value(depth + 1); err != nil {
+			return err
+		}
+	}
+	if l > 0 {
+		t.newline(depth)
+	}
+	t.w.WriteByte(']')
+	return nil
+}
+
+func (t *toJSON) object(l int, depth int) error {
+	if depth >= validate.DefaultLimits.MaxDepth {
+		return fmt.Errorf("nesting exceeds max depth %d", validate.DefaultLimits.MaxDepth)
+	}
+	t.w.WriteByte('{')
+	for i := 0; i < l; i++ {
+		if i > 0 {
+			t.w.WriteByte(',')
+		}
+		t.newline(depth + 1)
+		if err := t.key(depth + 1); err != nil {
+			return err
+		}
+		t.w.WriteByte(':')
+		t.space()
+		if err := t.value(depth + 1); err != nil {
+			return err
+		}
+	}
+	if l > 0 {
+		t.newline(depth)
+	}
+	t.w.WriteByte('}')
+	return nil
+}
+
+// key writes a map key, keys other than str are written compact to a buffer
+// and quoted
+func (t *toJSON) key(depth int) error {
+	code, err := t.r.Peek(1)
+	if err != nil {
+		return err
+	}
+	if c := code[0]; def.FixStr <= c && c <= def.FixStr+0x1f || def.Str8 <= c && c <= def.Str32 {
+		return t.value(depth)
+	}
+
+	var buf bytes.Buffer
+	kt := &toJSON{r: t.r, w: bufio.NewWriter(&buf), opts: JSONOptions{NonFinite: t.opts.NonFinite}}
+	if err = kt.value(depth); err != nil {
+		return err
+	}
+	kt.w.Flush()
+	s := buf.String()
+	if strings.HasPrefix(s, `"`) {
+		// strings in keys such as a bin keep their text
+		s, _ = strconv.Unquote(s)
+	}
+	bs, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	t.w.Write(bs)
+	return nil
+}
+
+// newline starts a new line indented to depth when pretty-printing
+func (t *toJSON) newline(depth int) {
+	if t.opts.Indent == "" {
+		return
+	}
+	t.w.WriteByte('\n')
+	for i := 0; i < depth; i++ {
+		t.w.WriteString(t.opts.Indent)
+	}
+}
+
+// space separates a key from its value when pretty-printing
+func (t *toJSON) space() {
+	if t.opts.Indent != "" {
+		t.w.WriteByte(' ')
+	}
+}
@@ -0,0 +1,152 @@
+package stream
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/romanzac/json-mp/mp/def"
+	"github.com/romanzac/json-mp/mp/validate"
+)
+
+// Reader splits a stream of concatenated MessagePack values, such as written
+// by FromJSON, into single values. Only one value is held in memory.
+type Reader struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+// NewReader returns a Reader reading from r
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next value, which is valid until the following call. At the
+// end of the stream io.EOF is returned, io.ErrUnexpectedEOF within a value.
+// After any other error the stream cannot be resynchronized.
+func (r *Reader) Next() ([]byte, error) {
+	r.buf = r.buf[:0]
+	if _, err := r.r.Peek(1); err != nil {
+		return nil, err
+	}
+	if err := r.value(0); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return r.buf, nil
+}
+
+// value copies the next value at nesting depth into buf
+func (r *Reader) value(depth int) error {
+	code, err := r.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	r.buf = append(r.buf, code)
+
+	switch {
+	case code <= def.FixIntMax, code >= 0xe0:
+		return nil
+	case def.FixMap <= code && code <= def.FixMap+0x0f:
+		return r.elements(2*int(code-def.FixMap), depth)
+	case def.FixArray <= code && code <= def.FixArray+0x0f:
+		return r.elements(int(code-def.FixArray), depth)
+	case def.FixStr <= code && code <= def.FixStr+0x1f:
+		return r.bytes(int(code - def.FixStr))
+	case def.FixExt1 <= code && code <= def.FixExt16:
+		return r.bytes(1 + 1<<(code-def.FixExt1))
+	}
+
+	switch code {
+	case def.Nil, def.False, def.True:
+		return nil
+	case def.Uint8, def.Uint16, def.Uint32, def.Uint64:
+		return r.bytes(1 << (code - def.Uint8))
+	case def.Int8, def.Int16, def.Int32, def.Int64:
+		return r.bytes(1 << (code - def.Int8))
+	case def.Float32:
+		return r.bytes(4)
+	case def.Float64:
+		return r.bytes(8)
+	case def.Str8, def.Str16, def.Str32:
+		l, err := r.length(1 << (code - def.Str8))
+		if err != nil {
+			return err
+		}
+		return r.bytes(l)
+	case def.Bin8, def.Bin16, def.Bin32:
+		l, err := r.length(1 << (code - def.Bin8))
+		if err != nil {
+			return err
+		}
+		return r.bytes(l)
+	case def.Ext8, def.Ext16, def.Ext32:
+		l, err := r.length(1 << (code - def.Ext8))
+		if err != nil {
+			return err
+		}
+		return r.bytes(1 + l)
+	case def.Array16, def.Array32:
+		l, err := r.length(2 << (code - def.Array16))
+		if err != nil {
+			return err
+		}
+		return r.elements(l, depth)
+	case def.Map16, def.Map32:
+		l, err := r.length(2 << (code - def.Map16))
+		if err != nil {
+			return err
+		}
+		return r.elements(2*l, depth)
+	}
+	return fmt.Errorf("invalid code %x", code)
+}
+
+// elements copies n values nested in a container at depth
+func (r *Reader) elements(n int, depth int) error {
+	if depth >= validate.DefaultLimits.MaxDepth {
+		return fmt.Errorf("nesting exceeds max depth %d", validate.DefaultLimits.MaxDepth)
+	}
+	for i := 0; i < n; i++ {
+		if err := r.value(depth + 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// length copies a big-endian length of size bytes and returns it
+func (r *Reader) length(size int) (int, error) {
+	if err := r.bytes(size); err != nil {
+		return 0, err
+	}
+	b := r.buf[len(r.buf)-size:]
+	switch size {
+	case 1:
+		return int(b[0]), nil
+	case 2:
+		return int(binary.BigEndian.Uint16(b)), nil
+	}
+	return int(binary.BigEndian.Uint32(b)), nil
+}
+
+// bytes copies n bytes, growing buf by chunks so a length claimed by broken
+// input is not allocated up front
+func (r *Reader) bytes(n int) error {
+	for n > 0 {
+		c := n
+		if c > chunkSize*16 {
+			c = chunkSize * 16
+		}
+		l := len(r.buf)
+		r.buf = append(r.buf, make([]byte, c)...)
+		if _, err := io.ReadFull(r.r, r.buf[l:]); err != nil {
+			return err
+		}
+		n -= c
+	}
+	return nil
+}
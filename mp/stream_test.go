@@ -0,0 +1,234 @@
+package mp
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/romanzac/json-mp/mp/def"
+	"github.com/romanzac/json-mp/mp/stream"
+)
+
+func TestFromJSON(t *testing.T) {
+	src := `{"z":1,"a":{"y":[true,null,"s",-2,1.5],"b":18446744073709551615,"x":{},"w":[]},"long":"` + strings.Repeat("x", 40) + `"}`
+	var om OrderedMap
+	if err := json.Unmarshal([]byte(src), &om); err != nil {
+		t.Fatal(err)
+	}
+	want, err := Marshal(om)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// strings.Reader is read twice, the wrapped reader is spooled
+	for _, r := range []io.Reader{strings.NewReader(src), struct{ io.Reader }{strings.NewReader(src)}} {
+		var out bytes.Buffer
+		if err = FromJSON(&out, r); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out.Bytes(), want) {
+			t.Errorf("value different %T\n%x\n%x", r, out.Bytes(), want)
+		}
+	}
+}
+
+func TestFromJSONValues(t *testing.T) {
+	var out bytes.Buffer
+	if err := FromJSON(&out, strings.NewReader("1 [] {\"a\":null}\n\"s\"")); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x01, 0x90, 0x81, 0xa1, 'a', 0xc0, 0xa1, 's'}
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Error("value different", out.Bytes())
+	}
+}
+
+func TestFromJSONSpill(t *testing.T) {
+	const n = 100000
+	var src bytes.Buffer
+	src.WriteByte('[')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			src.WriteByte(',')
+		}
+		src.WriteString(`{"a":[1,2],"b":{}}`)
+	}
+	src.WriteByte(']')
+
+	var out bytes.Buffer
+	if err := FromJSON(&out, struct{ io.Reader }{&src}); err != nil {
+		t.Fatal(err)
+	}
+	var v []map[string]interface{}
+	if err := Unmarshal(out.Bytes(), &v); err != nil {
+		t.Fatal(err)
+	}
+	if len(v) != n || len(v[n-1]) != 2 || len(v[n-1]["a"].([]interface{})) != 2 {
+		t.Error("value different", len(v), v[n-1])
+	}
+}
+
+func TestFromJSONErrors(t *testing.T) {
+	for _, src := range []string{
+		`{"a":123456789012345678901234567890}`,
+		`[1,2`,
+		`{"a" 1}`,
+		`[1]]`,
+	} {
+		var out bytes.Buffer
+		if err := FromJSON(&out, strings.NewReader(src)); err == nil {
+			t.Error("error must occur", src)
+		}
+		if out.Len() != 0 {
+			t.Error("nothing must be written", src, out.Bytes())
+		}
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	src := `{"z":1,"a":{"y":[true,null,"s\\t",-2,1.5,-129],"b":18446744073709551615,"x":{},"w":[]},"f":0.000001,"big":1e+21}`
+	var mpData bytes.Buffer
+	if err := FromJSON(&mpData, strings.NewReader(src)); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := ToJSON(&out, bytes.NewReader(mpData.Bytes()), JSONOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	var want bytes.Buffer
+	json.Compact(&want, []byte(src))
+	if out.String() != want.String()+"\n" {
+		t.Errorf("value different\n%s\n%s", out.String(), want.String())
+	}
+
+	out.Reset()
+	if err := ToJSON(&out, bytes.NewReader(mpData.Bytes()), JSONOptions{Indent: "  "}); err != nil {
+		t.Fatal(err)
+	}
+	want.Reset()
+	json.Indent(&want, []byte(src), "", "  ")
+	if out.String() != want.String()+"\n" {
+		t.Errorf("value different\n%s\n%s", out.String(), want.String())
+	}
+}
+
+func TestToJSONMapping(t *testing.T) {
+	d := []byte{def.FixArray + 5,
+		def.Bin8, 3, 0x01, 0x02, 0x03,
+		def.FixExt1, 0x05, 0xff,
+		def.FixMap + 3, 0x01, def.True, def.Nil, 0x02, def.FixArray + 1, def.FixStr + 1, 'k', def.False,
+		def.Float32, 0x3f, 0x8c, 0xcc, 0xcd,
+		def.Int16, 0xff, 0x7f,
+	}
+	var out bytes.Buffer
+	if err := ToJSON(&out, bytes.NewReader(d), JSONOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	want := `["AQID",{"$ext":5,"data":"/w=="},{"1":true,"null":2,"[\"k\"]":false},1.1,-129]` + "\n"
+	if out.String() != want {
+		t.Errorf("value different\n%s\n%s", out.String(), want)
+	}
+}
+
+func TestToJSONNonFinite(t *testing.T) {
+	d, err := Marshal([]float64{math.NaN(), math.Inf(1), math.Inf(-1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err = ToJSON(&out, bytes.NewReader(d), JSONOptions{}); err == nil {
+		t.Error("error must occur")
+	}
+	for mode, want := range map[NonFiniteMode]string{
+		NonFiniteNull:   "[null,null,null]\n",
+		NonFiniteString: `["NaN","Infinity","-Infinity"]` + "\n",
+	} {
+		out.Reset()
+		if err = ToJSON(&out, bytes.NewReader(d), JSONOptions{NonFinite: mode}); err != nil || out.String() != want {
+			t.Error("value different", out.String(), err)
+		}
+	}
+}
+
+func TestToJSONLongString(t *testing.T) {
+	// multi-byte runes and invalid bytes across the chunk boundaries
+	s := strings.Repeat("aé€😀\x80\"", 3000)
+	d, err := Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err = ToJSON(&out, bytes.NewReader(d), JSONOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := json.Marshal(s)
+	if out.String() != string(want)+"\n" {
+		t.Error("value different")
+	}
+}
+
+func TestToJSONErrors(t *testing.T) {
+	for _, d := range [][]byte{
+		{def.FixArray + 2, 0x01},
+		{def.Str8, 10, 'a'},
+		{def.NeverUsed},
+		{def.Uint32, 0x00},
+	} {
+		var out bytes.Buffer
+		if err := ToJSON(&out, bytes.NewReader(d), JSONOptions{}); err == nil {
+			t.Error("error must occur", d)
+		}
+	}
+}
+
+func TestStreamReader(t *testing.T) {
+	values := []interface{}{
+		1, -5, "s", strings.Repeat("x", 100000), []interface{}{nil, true, 1.5, float32(2)},
+		map[string]interface{}{"a": []int{1, 2, 3}}, uint64(math.MaxUint64), []byte{1, 2},
+	}
+	var all []byte
+	for _, v := range values {
+		d, err := Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, d...)
+	}
+	all = append(all, def.Bin8, 2, 0x01, 0x02, def.FixExt2, 0x01, 0xaa, 0xbb)
+
+	r := stream.NewReader(bytes.NewReader(all))
+	var got []byte
+	for n := 0; ; n++ {
+		v, err := r.Next()
+		if err == io.EOF {
+			if n != len(values)+2 {
+				t.Error("count different", n)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = Validate(v); err != nil {
+			t.Fatal(n, err)
+		}
+		got = append(got, v...)
+	}
+	if !bytes.Equal(got, all) {
+		t.Error("value different")
+	}
+
+	for _, d := range [][]byte{
+		{def.FixArray + 2, 0x01},
+		{def.Str16, 0x00},
+		{def.NeverUsed},
+	} {
+		if _, err := stream.NewReader(bytes.NewReader(d)).Next(); err == nil || err == io.EOF {
+			t.Error("error must occur", d, err)
+		}
+	}
+}
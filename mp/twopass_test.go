@@ -0,0 +1,273 @@
+package mp
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/romanzac/json-mp/mp/def"
+	"github.com/romanzac/json-mp/mp/wire"
+)
+
+// twoPass is a reference of the encoder design replaced by the single pass:
+// the size of the value is computed first, caching the keys of every map by
+// its pointer for the second pass, which writes into a buffer of exactly that
+// size. It serves the benchmarks comparing both designs.
+type twoPass struct {
+	keys map[uintptr][]reflect.Value
+}
+
+func twoPassMarshal(v interface{}) ([]byte, error) {
+	e := twoPass{keys: map[uintptr][]reflect.Value{}}
+	rv := reflect.ValueOf(v)
+	size, err := e.size(rv)
+	if err != nil {
+		return nil, err
+	}
+	d := e.write(make([]byte, 0, size), rv)
+	if len(d) != size {
+		return nil, fmt.Errorf("failed serialization size=%d, written=%d", size, len(d))
+	}
+	return d, nil
+}
+
+func (e *twoPass) size(rv reflect.Value) (int, error) {
+	switch rv.Kind() {
+	case reflect.Invalid, reflect.Bool:
+		return def.Byte1, nil
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return def.Byte1, nil
+		}
+		return e.size(rv.Elem())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return intSize(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return uintSize(rv.Uint()), nil
+	case reflect.Float32:
+		return def.Byte1 + def.Byte4, nil
+	case reflect.Float64:
+		return def.Byte1 + def.Byte8, nil
+	case reflect.String:
+		return strSize(rv.Len()), nil
+
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return def.Byte1, nil
+		}
+		n := headerSize(rv.Len(), 0x0f)
+		for i := 0; i < rv.Len(); i++ {
+			s, err := e.size(rv.Index(i))
+			if err != nil {
+				return 0, err
+			}
+			n += s
+		}
+		return n, nil
+
+	case reflect.Map:
+		if rv.IsNil() {
+			return def.Byte1, nil
+		}
+		keys := rv.MapKeys()
+		e.keys[rv.Pointer()] = keys
+		n := headerSize(len(keys), 0x0f)
+		for _, k := range keys {
+			ks, err := e.size(k)
+			if err != nil {
+				return 0, err
+			}
+			vs, err := e.size(rv.MapIndex(k))
+			if err != nil {
+				return 0, err
+			}
+			n += ks + vs
+		}
+		return n, nil
+
+	case reflect.Struct:
+		fields := 0
+		n := 0
+		for i := 0; i < rv.NumField(); i++ {
+			ok, name := def.CheckStructField(rv.Type().Field(i))
+			if !ok {
+				continue
+			}
+			s, err := e.size(rv.Field(i))
+			if err != nil {
+				return 0, err
+			}
+			fields++
+			n += strSize(len(name)) + s
+		}
+		return headerSize(fields, 0x0f) + n, nil
+	}
+	return 0, fmt.Errorf("type(%v) is unsupported", rv.Kind())
+}
+
+func (e *twoPass) write(d []byte, rv reflect.Value) []byte {
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return wire.AppendNil(d)
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return wire.AppendNil(d)
+		}
+		return e.write(d, rv.Elem())
+	case reflect.Bool:
+		return wire.AppendBool(d, rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return wire.AppendInt(d, rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return wire.AppendUint(d, rv.Uint())
+	case reflect.Float32:
+		return wire.AppendFloat32(d, float32(rv.Float()))
+	case reflect.Float64:
+		return wire.AppendFloat64(d, rv.Float())
+	case reflect.String:
+		return wire.AppendString(d, rv.String())
+
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return wire.AppendNil(d)
+		}
+		d = wire.AppendArrayHeader(d, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			d = e.write(d, rv.Index(i))
+		}
+		return d
+
+	case reflect.Map:
+		if rv.IsNil() {
+			return wire.AppendNil(d)
+		}
+		keys := e.keys[rv.Pointer()]
+		d = wire.AppendMapHeader(d, len(keys))
+		for _, k := range keys {
+			d = e.write(d, k)
+			d = e.write(d, rv.MapIndex(k))
+		}
+		return d
+	}
+
+	// struct, checked by size
+	fields := 0
+	for i := 0; i < rv.NumField(); i++ {
+		if ok, _ := def.CheckStructField(rv.Type().Field(i)); ok {
+			fields++
+		}
+	}
+	d = wire.AppendMapHeader(d, fields)
+	for i := 0; i < rv.NumField(); i++ {
+		if ok, name := def.CheckStructField(rv.Type().Field(i)); ok {
+			d = wire.AppendString(d, name)
+			d = e.write(d, rv.Field(i))
+		}
+	}
+	return d
+}
+
+func intSize(v int64) int {
+	switch {
+	case v >= 0:
+		return uintSize(uint64(v))
+	case v >= def.NegativeFixIntMin:
+		return def.Byte1
+	case v >= math.MinInt8:
+		return def.Byte1 + def.Byte1
+	case v >= math.MinInt16:
+		return def.Byte1 + def.Byte2
+	case v >= math.MinInt32:
+		return def.Byte1 + def.Byte4
+	}
+	return def.Byte1 + def.Byte8
+}
+
+func uintSize(v uint64) int {
+	switch {
+	case v <= math.MaxInt8:
+		return def.Byte1
+	case v <= math.MaxUint8:
+		return def.Byte1 + def.Byte1
+	case v <= math.MaxUint16:
+		return def.Byte1 + def.Byte2
+	case v <= math.MaxUint32:
+		return def.Byte1 + def.Byte4
+	}
+	return def.Byte1 + def.Byte8
+}
+
+func strSize(l int) int {
+	if l <= math.MaxUint8 && l > 0x1f {
+		return def.Byte1 + def.Byte1 + l
+	}
+	return headerSize(l, 0x1f) + l
+}
+
+func headerSize(l, fixMax int) int {
+	switch {
+	case l <= fixMax:
+		return def.Byte1
+	case l <= math.MaxUint16:
+		return def.Byte1 + def.Byte2
+	}
+	return def.Byte1 + def.Byte4
+}
+
+// benchValues are the values of the Marshal benchmarks by name
+func benchValues() map[string]interface{} {
+	m := map[string]interface{}{}
+	for i := 0; i < 100; i++ {
+		m[fmt.Sprint("key", i)] = map[string]interface{}{"id": i, "name": fmt.Sprint("name", i), "ok": i%2 == 0}
+	}
+	s := make([]interface{}, 1000)
+	for i := range s {
+		s[i] = i * 1000
+	}
+	return map[string]interface{}{"Struct": newBenchWidget(), "Map": m, "Slice": s}
+}
+
+func TestTwoPassReference(t *testing.T) {
+	for name, v := range benchValues() {
+		a, err := Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := twoPassMarshal(v)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		// maps are written in iteration order, so only the values are compared
+		var va, vb interface{}
+		if err = Unmarshal(a, &va); err != nil {
+			t.Fatal(err)
+		}
+		if err = Unmarshal(b, &vb); err != nil {
+			t.Fatal(err)
+		}
+		if len(a) != len(b) || !reflect.DeepEqual(va, vb) {
+			t.Errorf("%s: the designs differ\n% x\n% x", name, a, b)
+		}
+	}
+}
+
+// The single pass benchmarks are BenchmarkMarshalStruct, -Map and -Slice
+func BenchmarkTwoPassMarshal(b *testing.B) {
+	for name, v := range benchValues() {
+		b.Run(name, func(b *testing.B) {
+			d, err := twoPassMarshal(v)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(len(d)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := twoPassMarshal(v); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
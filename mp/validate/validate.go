@@ -0,0 +1,242 @@
+package validate
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"unicode/utf8"
+
+	"github.com/romanzac/json-mp/mp/def"
+)
+
+// Error describes the first problem found in the data and where it starts
+type Error struct {
+	Offset int
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s at offset %d", e.Msg, e.Offset)
+}
+
+// Limits bounds the resources a document may claim
+type Limits struct {
+	MaxDepth  int // nesting of maps and arrays
+	MaxLength int // entries of a container or bytes of str, bin and ext payload
+}
+
+// DefaultLimits are used by Validate and Valid. MaxLength is the largest
+// length an int holds on every platform.
+var DefaultLimits = Limits{MaxDepth: 1000, MaxLength: math.MaxInt32}
+
+type validator struct {
+	data   []byte
+	limits Limits
+}
+
+// Valid reports whether data is a single well-formed MessagePack value
+func Valid(data []byte) bool {
+	return Validate(data) == nil
+}
+
+// Validate checks that data holds exactly one well-formed MessagePack value
+// and returns the first problem found as *Error
+func Validate(data []byte) error {
+	return WithLimits(data, DefaultLimits)
+}
+
+// WithLimits is like Validate, but with custom limits
+func WithLimits(data []byte, l Limits) error {
+	v := validator{data: data, limits: l}
+	if len(data) < 1 {
+		return &Error{Offset: 0, Msg: "empty data"}
+	}
+	end, err := v.value(0, 0)
+	if err != nil {
+		return err
+	}
+	if end != len(data) {
+		return &Error{Offset: end, Msg: fmt.Sprintf("%d trailing bytes", len(data)-end)}
+	}
+	return nil
+}
+
+// Next checks the value starting at offset and returns the offset following it.
+// It allows validating a stream of concatenated values.
+func Next(data []byte, offset int, l Limits) (int, error) {
+	v := validator{data: data, limits: l}
+	return v.value(offset, 0)
+}
+
+func (v *validator) value(offset, depth int) (int, error) {
+	if len(v.data) < offset+def.Byte1 {
+		return 0, &Error{Offset: offset, Msg: "too short bytes"}
+	}
+	code := v.data[offset]
+	o := offset + def.Byte1
+
+	switch {
+	case code <= def.FixIntMax, code >= 0xe0:
+		// Positive and negative fixint - single byte
+		return o, nil
+	case code == def.Nil, code == def.True, code == def.False:
+		return o, nil
+
+	case def.FixMap <= code && code <= def.FixMap+0x0f:
+		return v.container(offset, o, int(code-def.FixMap), 2, depth)
+	case code == def.Map16, code == def.Map32:
+		l, o, err := v.length(offset, o, code == def.Map16)
+		if err != nil {
+			return 0, err
+		}
+		return v.container(offset, o, l, 2, depth)
+
+	case def.FixArray <= code && code <= def.FixArray+0x0f:
+		return v.container(offset, o, int(code-def.FixArray), 1, depth)
+	case code == def.Array16, code == def.Array32:
+		l, o, err := v.length(offset, o, code == def.Array16)
+		if err != nil {
+			return 0, err
+		}
+		return v.container(offset, o, l, 1, depth)
+
+	case def.FixStr <= code && code <= def.FixStr+0x1f:
+		return v.str(offset, o, int(code-def.FixStr))
+	case code == def.Str8:
+		if len(v.data) < o+def.Byte1 {
+			return 0, &Error{Offset: offset, Msg: "too short bytes"}
+		}
+		return v.str(offset, o+def.Byte1, int(v.data[o]))
+	case code == def.Str16, code == def.Str32:
+		l, o, err := v.length(offset, o, code == def.Str16)
+		if err != nil {
+			return 0, err
+		}
+		return v.str(offset, o, l)
+
+	case code == def.Bin8:
+		if len(v.data) < o+def.Byte1 {
+			return 0, &Error{Offset: offset, Msg: "too short bytes"}
+		}
+		return v.payload(offset, o+def.Byte1, int(v.data[o]))
+	case code == def.Bin16, code == def.Bin32:
+		l, o, err := v.length(offset, o, code == def.Bin16)
+		if err != nil {
+			return 0, err
+		}
+		return v.payload(offset, o, l)
+
+	case code == def.Ext8:
+		if len(v.data) < o+def.Byte1 {
+			return 0, &Error{Offset: offset, Msg: "too short bytes"}
+		}
+		return v.payload(offset, o+def.Byte1, def.Byte1+int(v.data[o]))
+	case code == def.Ext16, code == def.Ext32:
+		l, o, err := v.length(offset, o, code == def.Ext16)
+		if err != nil {
+			return 0, err
+		}
+		// the type byte is checked apart, adding it to l may overflow
+		if o, err = v.payload(offset, o, def.Byte1); err != nil {
+			return 0, err
+		}
+		return v.payload(offset, o, l)
+	case def.FixExt1 <= code && code <= def.FixExt16:
+		return v.payload(offset, o, def.Byte1+1<<(code-def.FixExt1))
+
+	case code == def.Uint8, code == def.Int8:
+		return v.payload(offset, o, def.Byte1)
+	case code == def.Uint16, code == def.Int16:
+		return v.payload(offset, o, def.Byte2)
+	case code == def.Uint32, code == def.Int32, code == def.Float32:
+		return v.payload(offset, o, def.Byte4)
+	case code == def.Uint64, code == def.Int64, code == def.Float64:
+		return v.payload(offset, o, def.Byte8)
+
+	case code == def.NeverUsed:
+		return 0, &Error{Offset: offset, Msg: "reserved code c1"}
+	}
+	return 0, &Error{Offset: offset, Msg: fmt.Sprintf("invalid code %x", code)}
+}
+
+// length reads a 2 or 4 byte length following the format code
+func (v *validator) length(offset, o int, short bool) (int, int, error) {
+	n := def.Byte4
+	if short {
+		n = def.Byte2
+	}
+	if len(v.data) < o+n {
+		return 0, 0, &Error{Offset: offset, Msg: "too short bytes"}
+	}
+	if short {
+		return int(binary.BigEndian.Uint16(v.data[o:])), o + n, nil
+	}
+	l := binary.BigEndian.Uint32(v.data[o:])
+	// checked before the conversion, a 32 bit int does not hold every length
+	if int64(l) > int64(v.limits.MaxLength) {
+		return 0, 0, &Error{Offset: offset, Msg: fmt.Sprintf("length %d exceeds limit %d", l, v.limits.MaxLength)}
+	}
+	return int(l), o + n, nil
+}
+
+func (v *validator) container(offset, o, l, perEntry, depth int) (int, error) {
+	if depth >= v.limits.MaxDepth {
+		return 0, &Error{Offset: offset, Msg: fmt.Sprintf("nesting exceeds max depth %d", v.limits.MaxDepth)}
+	}
+	if l > v.limits.MaxLength {
+		return 0, &Error{Offset: offset, Msg: fmt.Sprintf("container length %d exceeds limit %d", l, v.limits.MaxLength)}
+	}
+	// Every element takes at least one byte
+	if (len(v.data)-o)/perEntry < l {
+		return 0, &Error{Offset: offset, Msg: fmt.Sprintf("container length %d exceeds remaining %d bytes", l, len(v.data)-o)}
+	}
+
+	var err error
+	for i := 0; i < l*perEntry; i++ {
+		if perEntry == 2 && i%2 == 0 {
+			if err = v.key(o); err != nil {
+				return 0, err
+			}
+		}
+		if o, err = v.value(o, depth+1); err != nil {
+			return 0, err
+		}
+	}
+	return o, nil
+}
+
+// key rejects containers used as map keys, as the decoder does
+func (v *validator) key(offset int) error {
+	if len(v.data) <= offset {
+		return nil
+	}
+	code := v.data[offset]
+	switch {
+	case def.FixMap <= code && code <= def.FixMap+0x0f, code == def.Map16, code == def.Map32:
+		return &Error{Offset: offset, Msg: "map used as map key"}
+	case def.FixArray <= code && code <= def.FixArray+0x0f, code == def.Array16, code == def.Array32:
+		return &Error{Offset: offset, Msg: "array used as map key"}
+	}
+	return nil
+}
+
+func (v *validator) str(offset, o, l int) (int, error) {
+	end, err := v.payload(offset, o, l)
+	if err != nil {
+		return 0, err
+	}
+	if !utf8.Valid(v.data[o:end]) {
+		return 0, &Error{Offset: offset, Msg: "invalid UTF-8 in str"}
+	}
+	return end, nil
+}
+
+func (v *validator) payload(offset, o, l int) (int, error) {
+	if l > v.limits.MaxLength {
+		return 0, &Error{Offset: offset, Msg: fmt.Sprintf("payload length %d exceeds limit %d", l, v.limits.MaxLength)}
+	}
+	if len(v.data)-o < l {
+		return 0, &Error{Offset: offset, Msg: "too short bytes"}
+	}
+	return o + l, nil
+}
@@ -0,0 +1,69 @@
+package mp
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/romanzac/json-mp/mp/def"
+	"github.com/romanzac/json-mp/mp/validate"
+)
+
+func TestValid(t *testing.T) {
+	d, err := Marshal(map[string]interface{}{"a": []interface{}{1, -300, "x", 1.5, nil, true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Validate(d); err != nil {
+		t.Error(err)
+	}
+
+	ext := []byte{def.FixArray + 2, def.Bin8, 0x02, 0xff, 0xfe, def.FixExt4, 0x01, 0, 0, 0, 0}
+	if !Valid(ext) {
+		t.Error("bin and ext must be valid")
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	tests := []struct {
+		data   []byte
+		offset int
+		msg    string
+	}{
+		{[]byte{}, 0, "empty data"},
+		{[]byte{def.FixArray + 2, 0x01}, 0, "exceeds remaining"},
+		{[]byte{def.FixArray + 1, def.Str8, 0x05, 'a'}, 1, "too short bytes"},
+		{[]byte{def.FixMap + 1, def.NeverUsed, 0x01}, 1, "reserved code c1"},
+		{[]byte{def.FixStr + 2, 0xc3, 0x28}, 0, "invalid UTF-8"},
+		{[]byte{0x01, 0x02}, 1, "1 trailing bytes"},
+		{[]byte{def.FixMap + 1, def.FixArray, 0x01}, 1, "array used as map key"},
+		{[]byte{def.Uint32, 0x00}, 0, "too short bytes"},
+		{[]byte{def.Str32, 0x80, 0x00, 0x00, 0x00}, 0, "length 2147483648 exceeds limit 2147483647"},
+		{[]byte{def.Ext32, 0x7f, 0xff, 0xff, 0xff, 0x01}, 0, "too short bytes"},
+	}
+
+	for i, tt := range tests {
+		err := Validate(tt.data)
+		var ve *ValidationError
+		if !errors.As(err, &ve) {
+			t.Error(i, "error must occur", err)
+			continue
+		}
+		if ve.Offset != tt.offset || !strings.Contains(ve.Msg, tt.msg) {
+			t.Error(i, err)
+		}
+	}
+
+	deep := append(bytes.Repeat([]byte{def.FixArray + 1}, 2000), 0x01)
+	if err := Validate(deep); err == nil || !strings.Contains(err.Error(), "max depth") {
+		t.Error("error must occur", err)
+	}
+
+	// the limit reported is the limit applied
+	bin := []byte{def.Bin32, 0x00, 0x00, 0x00, 0x05, 1, 2, 3, 4, 5}
+	err := validate.WithLimits(bin, validate.Limits{MaxDepth: 1, MaxLength: 4})
+	if err == nil || !strings.Contains(err.Error(), "length 5 exceeds limit 4") {
+		t.Error("error must occur", err)
+	}
+}
@@ -0,0 +1,481 @@
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/romanzac/json-mp/mp/def"
+)
+
+var errShort = errors.New("too short bytes")
+
+// Errors returned by generated UnmarshalMsgpack methods
+var (
+	ErrTrailingBytes = errors.New("trailing bytes after value")
+	ErrArrayLength   = errors.New("messagepack array is longer than the Go array")
+)
+
+func invalid(code byte, what string) error {
+	return fmt.Errorf("invalid code %x decoding %s", code, what)
+}
+
+// IsNil reports whether the next value in b is nil
+func IsNil(b []byte) bool {
+	return len(b) > 0 && b[0] == def.Nil
+}
+
+// ReadNil consumes a nil
+func ReadNil(b []byte) ([]byte, error) {
+	if len(b) < def.Byte1 {
+		return nil, errShort
+	}
+	if b[0] != def.Nil {
+		return nil, invalid(b[0], "nil")
+	}
+	return b[1:], nil
+}
+
+// ReadBool reads true or false
+func ReadBool(b []byte) (bool, []byte, error) {
+	if len(b) < def.Byte1 {
+		return false, nil, errShort
+	}
+	switch b[0] {
+	case def.True:
+		return true, b[1:], nil
+	case def.False:
+		return false, b[1:], nil
+	}
+	return false, nil, invalid(b[0], "bool")
+}
+
+// number reads any int, uint or float format. Exactly one of i, u and f is
+// meaningful as reported by kind: 'i', 'u' or 'f'.
+func number(b []byte, what string) (i int64, u uint64, f float64, kind byte, rest []byte, err error) {
+	if len(b) < def.Byte1 {
+		return 0, 0, 0, 0, nil, errShort
+	}
+	code := b[0]
+	switch {
+	case code <= def.FixIntMax:
+		return 0, uint64(code), 0, 'u', b[1:], nil
+	case code >= 0xe0:
+		return int64(int8(code)), 0, 0, 'i', b[1:], nil
+	case code == def.Nil:
+		return 0, 0, 0, 'u', b[1:], nil
+	}
+
+	var size int
+	switch code {
+	case def.Uint8, def.Int8:
+		size = def.Byte1
+	case def.Uint16, def.Int16:
+		size = def.Byte2
+	case def.Uint32, def.Int32, def.Float32:
+		size = def.Byte4
+	case def.Uint64, def.Int64, def.Float64:
+		size = def.Byte8
+	default:
+		return 0, 0, 0, 0, nil, invalid(code, what)
+	}
+	if len(b) < def.Byte1+size {
+		return 0, 0, 0, 0, nil, errShort
+	}
+	bs, rest := b[1:1+size], b[1+size:]
+
+	switch code {
+	case def.Uint8:
+		return 0, uint64(bs[0]), 0, 'u', rest, nil
+	case def.Uint16:
+		return 0, uint64(binary.BigEndian.Uint16(bs)), 0, 'u', rest, nil
+	case def.Uint32:
+		return 0, uint64(binary.BigEndian.Uint32(bs)), 0, 'u', rest, nil
+	case def.Uint64:
+		return 0, binary.BigEndian.Uint64(bs), 0, 'u', rest, nil
+	case def.Int8:
+		return int64(int8(bs[0])), 0, 0, 'i', rest, nil
+	case def.Int16:
+		return int64(int16(binary.BigEndian.Uint16(bs))), 0, 0, 'i', rest, nil
+	case def.Int32:
+		return int64(int32(binary.BigEndian.Uint32(bs))), 0, 0, 'i', rest, nil
+	case def.Int64:
+		return int64(binary.BigEndian.Uint64(bs)), 0, 0, 'i', rest, nil
+	case def.Float32:
+		return 0, 0, float64(math.Float32frombits(binary.BigEndian.Uint32(bs))), 'f', rest, nil
+	}
+	return 0, 0, math.Float64frombits(binary.BigEndian.Uint64(bs)), 'f', rest, nil
+}
+
+// ReadInt reads any int or uint format as int64, nil reads as 0
+func ReadInt(b []byte) (int64, []byte, error) {
+	i, u, _, kind, rest, err := number(b, "int")
+	switch kind {
+	case 'f':
+		return 0, nil, invalid(b[0], "int")
+	case 'u':
+		return int64(u), rest, err
+	}
+	return i, rest, err
+}
+
+// ReadInt8 is ReadInt with a range check
+func ReadInt8(b []byte) (int8, []byte, error) {
+	v, rest, err := readIntRange(b, math.MinInt8, math.MaxInt8, "int8")
+	return int8(v), rest, err
+}
+
+// ReadInt16 is ReadInt with a range check
+func ReadInt16(b []byte) (int16, []byte, error) {
+	v, rest, err := readIntRange(b, math.MinInt16, math.MaxInt16, "int16")
+	return int16(v), rest, err
+}
+
+// ReadInt32 is ReadInt with a range check
+func ReadInt32(b []byte) (int32, []byte, error) {
+	v, rest, err := readIntRange(b, math.MinInt32, math.MaxInt32, "int32")
+	return int32(v), rest, err
+}
+
+// ReadInt64 is ReadInt, uint 64 values above math.MaxInt64 are rejected
+func ReadInt64(b []byte) (int64, []byte, error) {
+	i, u, _, kind, rest, err := number(b, "int64")
+	switch {
+	case err != nil:
+		return 0, nil, err
+	case kind == 'f':
+		return 0, nil, invalid(b[0], "int64")
+	case kind == 'u' && u > math.MaxInt64:
+		return 0, nil, fmt.Errorf("value %d overflows int64", u)
+	case kind == 'u':
+		return int64(u), rest, nil
+	}
+	return i, rest, nil
+}
+
+func readIntRange(b []byte, min, max int64, what string) (int64, []byte, error) {
+	v, rest, err := ReadInt64(b)
+	if err != nil {
+		return 0, nil, err
+	}
+	if v < min || max < v {
+		return 0, nil, fmt.Errorf("value %d overflows %s", v, what)
+	}
+	return v, rest, nil
+}
+
+// ReadUint reads any int or uint format as uint64, nil reads as 0
+func ReadUint(b []byte) (uint64, []byte, error) {
+	i, u, _, kind, rest, err := number(b, "uint")
+	switch kind {
+	case 'f':
+		return 0, nil, invalid(b[0], "uint")
+	case 'i':
+		return uint64(i), rest, err
+	}
+	return u, rest, err
+}
+
+// ReadUint8 is ReadUint with a range check, negative values are rejected
+func ReadUint8(b []byte) (uint8, []byte, error) {
+	v, rest, err := readUintRange(b, math.MaxUint8, "uint8")
+	return uint8(v), rest, err
+}
+
+// ReadUint16 is ReadUint with a range check, negative values are rejected
+func ReadUint16(b []byte) (uint16, []byte, error) {
+	v, rest, err := readUintRange(b, math.MaxUint16, "uint16")
+	return uint16(v), rest, err
+}
+
+// ReadUint32 is ReadUint with a range check, negative values are rejected
+func ReadUint32(b []byte) (uint32, []byte, error) {
+	v, rest, err := readUintRange(b, math.MaxUint32, "uint32")
+	return uint32(v), rest, err
+}
+
+// ReadUint64 is ReadUint, negative values are rejected
+func ReadUint64(b []byte) (uint64, []byte, error) {
+	return readUintRange(b, math.MaxUint64, "uint64")
+}
+
+func readUintRange(b []byte, max uint64, what string) (uint64, []byte, error) {
+	i, u, _, kind, rest, err := number(b, what)
+	switch {
+	case err != nil:
+		return 0, nil, err
+	case kind == 'f':
+		return 0, nil, invalid(b[0], what)
+	case kind == 'i' && i < 0:
+		return 0, nil, fmt.Errorf("value %d overflows %s", i, what)
+	case kind == 'i':
+		u = uint64(i)
+	}
+	if u > max {
+		return 0, nil, fmt.Errorf("value %d overflows %s", u, what)
+	}
+	return u, rest, nil
+}
+
+// ReadFloat32 reads float 32 or any int or uint format, nil reads as 0
+func ReadFloat32(b []byte) (float32, []byte, error) {
+	if len(b) > 0 && b[0] == def.Float64 {
+		return 0, nil, invalid(b[0], "float32")
+	}
+	i, u, f, kind, rest, err := number(b, "float32")
+	switch kind {
+	case 'u':
+		return float32(u), rest, err
+	case 'i':
+		return float32(i), rest, err
+	}
+	return float32(f), rest, err
+}
+
+// ReadFloat64 reads any float, int or uint format, nil reads as 0
+func ReadFloat64(b []byte) (float64, []byte, error) {
+	i, u, f, kind, rest, err := number(b, "float64")
+	switch kind {
+	case 'u':
+		return float64(u), rest, err
+	case 'i':
+		return float64(i), rest, err
+	}
+	return f, rest, err
+}
+
+// ReadStringBytes reads a str payload, which aliases b. Nil reads as empty.
+func ReadStringBytes(b []byte) ([]byte, []byte, error) {
+	if len(b) < def.Byte1 {
+		return nil, nil, errShort
+	}
+	code := b[0]
+	var l int
+	var err error
+	switch {
+	case def.FixStr <= code && code <= def.FixStr+0x1f:
+		l, b = int(code-def.FixStr), b[1:]
+	case code == def.Str8:
+		l, b, err = readLength(b, def.Byte1)
+	case code == def.Str16:
+		l, b, err = readLength(b, def.Byte2)
+	case code == def.Str32:
+		l, b, err = readLength(b, def.Byte4)
+	case code == def.Nil:
+		return []byte{}, b[1:], nil
+	default:
+		return nil, nil, invalid(code, "string")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(b) < l {
+		return nil, nil, errShort
+	}
+	return b[:l], b[l:], nil
+}
+
+// ReadString reads a str, nil reads as empty
+func ReadString(b []byte) (string, []byte, error) {
+	bs, rest, err := ReadStringBytes(b)
+	return string(bs), rest, err
+}
+
+// ReadBytes reads a bin or str payload, which aliases b. Nil reads as nil.
+func ReadBytes(b []byte) ([]byte, []byte, error) {
+	if len(b) < def.Byte1 {
+		return nil, nil, errShort
+	}
+	var l int
+	var err error
+	switch b[0] {
+	case def.Bin8:
+		l, b, err = readLength(b, def.Byte1)
+	case def.Bin16:
+		l, b, err = readLength(b, def.Byte2)
+	case def.Bin32:
+		l, b, err = readLength(b, def.Byte4)
+	case def.Nil:
+		return nil, b[1:], nil
+	default:
+		if NextType(b) != StrType {
+			return nil, nil, invalid(b[0], "bytes")
+		}
+		return ReadStringBytes(b)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(b) < l {
+		return nil, nil, errShort
+	}
+	return b[:l], b[l:], nil
+}
+
+// ReadExt reads the application type and payload of an ext, the payload aliases b
+func ReadExt(b []byte) (int8, []byte, []byte, error) {
+	if len(b) < def.Byte1 {
+		return 0, nil, nil, errShort
+	}
+	code := b[0]
+	var l int
+	var err error
+	switch {
+	case def.FixExt1 <= code && code <= def.FixExt16:
+		l, b = 1<<(code-def.FixExt1), b[1:]
+	case code == def.Ext8:
+		l, b, err = readLength(b, def.Byte1)
+	case code == def.Ext16:
+		l, b, err = readLength(b, def.Byte2)
+	case code == def.Ext32:
+		l, b, err = readLength(b, def.Byte4)
+	default:
+		return 0, nil, nil, invalid(code, "ext")
+	}
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if len(b) < def.Byte1+l {
+		return 0, nil, nil, errShort
+	}
+	return int8(b[0]), b[1 : 1+l], b[1+l:], nil
+}
+
+// ReadMapHeader reads the number of entries of a map
+func ReadMapHeader(b []byte) (int, []byte, error) {
+	return readContainer(b, def.FixMap, def.Map16, def.Map32, "map")
+}
+
+// ReadArrayHeader reads the number of elements of an array
+func ReadArrayHeader(b []byte) (int, []byte, error) {
+	return readContainer(b, def.FixArray, def.Array16, def.Array32, "array")
+}
+
+func readContainer(b []byte, fix, code16, code32 byte, what string) (int, []byte, error) {
+	if len(b) < def.Byte1 {
+		return 0, nil, errShort
+	}
+	code := b[0]
+	var l int
+	var err error
+	switch {
+	case fix <= code && code <= fix+0x0f:
+		return int(code - fix), b[1:], nil
+	case code == code16:
+		l, b, err = readLength(b, def.Byte2)
+	case code == code32:
+		l, b, err = readLength(b, def.Byte4)
+	default:
+		return 0, nil, invalid(code, what)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	// Every element takes at least one byte
+	if len(b) < l {
+		return 0, nil, errShort
+	}
+	return l, b, nil
+}
+
+// readLength reads the size byte length following the format code
+func readLength(b []byte, size int) (int, []byte, error) {
+	if len(b) < def.Byte1+size {
+		return 0, nil, errShort
+	}
+	bs := b[1 : 1+size]
+	switch size {
+	case def.Byte1:
+		return int(bs[0]), b[1+size:], nil
+	case def.Byte2:
+		return int(binary.BigEndian.Uint16(bs)), b[1+size:], nil
+	}
+	return int(binary.BigEndian.Uint32(bs)), b[1+size:], nil
+}
+
+// ReadRaw returns the next complete value in b without decoding it
+func ReadRaw(b []byte) ([]byte, []byte, error) {
+	rest, err := Skip(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b[:len(b)-len(rest)], rest, nil
+}
+
+// Skip returns the bytes following the next value in b
+func Skip(b []byte) ([]byte, error) {
+	if len(b) < def.Byte1 {
+		return nil, errShort
+	}
+	code := b[0]
+
+	var n int
+	var err error
+	switch {
+	case code <= def.FixIntMax, code >= 0xe0,
+		code == def.Nil, code == def.True, code == def.False:
+		return b[1:], nil
+
+	case def.FixMap <= code && code <= def.FixMap+0x0f,
+		code == def.Map16, code == def.Map32:
+		if n, b, err = ReadMapHeader(b); err != nil {
+			return nil, err
+		}
+		return skipN(b, n*2)
+	case def.FixArray <= code && code <= def.FixArray+0x0f,
+		code == def.Array16, code == def.Array32:
+		if n, b, err = ReadArrayHeader(b); err != nil {
+			return nil, err
+		}
+		return skipN(b, n)
+
+	case def.FixStr <= code && code <= def.FixStr+0x1f:
+		n, b = int(code-def.FixStr), b[1:]
+	case code == def.Str8, code == def.Bin8:
+		n, b, err = readLength(b, def.Byte1)
+	case code == def.Str16, code == def.Bin16:
+		n, b, err = readLength(b, def.Byte2)
+	case code == def.Str32, code == def.Bin32:
+		n, b, err = readLength(b, def.Byte4)
+	case code == def.Ext8:
+		n, b, err = readLength(b, def.Byte1)
+		n++
+	case code == def.Ext16:
+		n, b, err = readLength(b, def.Byte2)
+		n++
+	case code == def.Ext32:
+		n, b, err = readLength(b, def.Byte4)
+		n++
+	case def.FixExt1 <= code && code <= def.FixExt16:
+		n, b = def.Byte1+1<<(code-def.FixExt1), b[1:]
+
+	case code == def.Uint8, code == def.Int8:
+		n, b = def.Byte1, b[1:]
+	case code == def.Uint16, code == def.Int16:
+		n, b = def.Byte2, b[1:]
+	case code == def.Uint32, code == def.Int32, code == def.Float32:
+		n, b = def.Byte4, b[1:]
+	case code == def.Uint64, code == def.Int64, code == def.Float64:
+		n, b = def.Byte8, b[1:]
+	default:
+		return nil, invalid(code, "value")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < n {
+		return nil, errShort
+	}
+	return b[n:], nil
+}
+
+func skipN(b []byte, n int) ([]byte, error) {
+	var err error
+	for i := 0; i < n; i++ {
+		if b, err = Skip(b); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
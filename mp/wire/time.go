@@ -0,0 +1,57 @@
+package wire
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/romanzac/json-mp/mp/def"
+)
+
+// AppendTime appends t as timestamp ext in the smallest of the 32, 64 and 96
+// bit formats which holds it exactly
+func AppendTime(b []byte, t time.Time) []byte {
+	sec, nsec := t.Unix(), uint64(t.Nanosecond())
+	switch {
+	case sec>>34 == 0 && nsec == 0 && sec <= math.MaxUint32:
+		b = AppendExtHeader(b, def.TimestampExt, def.Byte4)
+		return binary.BigEndian.AppendUint32(b, uint32(sec))
+	case sec>>34 == 0:
+		b = AppendExtHeader(b, def.TimestampExt, def.Byte8)
+		return binary.BigEndian.AppendUint64(b, nsec<<34|uint64(sec))
+	}
+	b = AppendExtHeader(b, def.TimestampExt, 12)
+	b = binary.BigEndian.AppendUint32(b, uint32(nsec))
+	return binary.BigEndian.AppendUint64(b, uint64(sec))
+}
+
+// ReadTime reads a timestamp ext, the time is in UTC
+func ReadTime(b []byte) (time.Time, []byte, error) {
+	typ, data, rest, err := ReadExt(b)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	if typ != def.TimestampExt {
+		return time.Time{}, nil, fmt.Errorf("ext type %d is not a timestamp", typ)
+	}
+
+	var sec int64
+	var nsec uint64
+	switch len(data) {
+	case def.Byte4:
+		sec = int64(binary.BigEndian.Uint32(data))
+	case def.Byte8:
+		u := binary.BigEndian.Uint64(data)
+		sec, nsec = int64(u&(1<<34-1)), u>>34
+	case 12:
+		nsec = uint64(binary.BigEndian.Uint32(data))
+		sec = int64(binary.BigEndian.Uint64(data[4:]))
+	default:
+		return time.Time{}, nil, fmt.Errorf("timestamp of %d bytes", len(data))
+	}
+	if nsec > 999999999 {
+		return time.Time{}, nil, fmt.Errorf("timestamp nanoseconds %d out of range", nsec)
+	}
+	return time.Unix(sec, int64(nsec)).UTC(), rest, nil
+}
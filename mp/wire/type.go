@@ -0,0 +1,76 @@
+package wire
+
+import "github.com/romanzac/json-mp/mp/def"
+
+// Type is the family of a MessagePack format
+type Type byte
+
+// Format families
+const (
+	InvalidType Type = iota
+	NilType
+	BoolType
+	IntType // negative fixint and int formats
+	UintType
+	FloatType
+	StrType
+	BinType
+	ArrayType
+	MapType
+	ExtType
+)
+
+var typeNames = [...]string{"invalid", "nil", "bool", "int", "uint", "float", "str", "bin", "array", "map", "ext"}
+
+func (t Type) String() string {
+	if int(t) < len(typeNames) {
+		return typeNames[t]
+	}
+	return typeNames[InvalidType]
+}
+
+// NextType returns the family of the next value in b without consuming it
+func NextType(b []byte) Type {
+	if len(b) < def.Byte1 {
+		return InvalidType
+	}
+	code := b[0]
+	switch {
+	case code <= def.FixIntMax:
+		return UintType
+	case code >= 0xe0:
+		return IntType
+	case def.FixMap <= code && code <= def.FixMap+0x0f:
+		return MapType
+	case def.FixArray <= code && code <= def.FixArray+0x0f:
+		return ArrayType
+	case def.FixStr <= code && code <= def.FixStr+0x1f:
+		return StrType
+	case def.FixExt1 <= code && code <= def.FixExt16:
+		return ExtType
+	}
+
+	switch code {
+	case def.Nil:
+		return NilType
+	case def.True, def.False:
+		return BoolType
+	case def.Uint8, def.Uint16, def.Uint32, def.Uint64:
+		return UintType
+	case def.Int8, def.Int16, def.Int32, def.Int64:
+		return IntType
+	case def.Float32, def.Float64:
+		return FloatType
+	case def.Str8, def.Str16, def.Str32:
+		return StrType
+	case def.Bin8, def.Bin16, def.Bin32:
+		return BinType
+	case def.Array16, def.Array32:
+		return ArrayType
+	case def.Map16, def.Map32:
+		return MapType
+	case def.Ext8, def.Ext16, def.Ext32:
+		return ExtType
+	}
+	return InvalidType
+}
@@ -0,0 +1,263 @@
+// Package wire reads and writes single MessagePack values without reflection.
+// Append functions extend b and return it, Read functions return the value and
+// the remaining bytes. Neither allocates, except ReadString copying the str.
+//
+// AppendInt, AppendUint, AppendString and the header functions pick the
+// smallest format like mp.Marshal, the sized variants such as AppendInt16 or
+// AppendStringHeader8 always write the named format.
+package wire
+
+import (
+	"math"
+
+	"github.com/romanzac/json-mp/mp/def"
+)
+
+// AppendNil appends nil
+func AppendNil(b []byte) []byte {
+	return append(b, def.Nil)
+}
+
+// AppendBool appends true or false
+func AppendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, def.True)
+	}
+	return append(b, def.False)
+}
+
+// AppendInt appends v in the smallest int or uint format
+func AppendInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0:
+		return AppendUint(b, uint64(v))
+	case def.NegativeFixIntMin <= v && v <= def.NegativeFixIntMax:
+		return append(b, byte(v))
+	case v >= math.MinInt8:
+		return AppendInt8(b, int8(v))
+	case v >= math.MinInt16:
+		return AppendInt16(b, int16(v))
+	case v >= math.MinInt32:
+		return AppendInt32(b, int32(v))
+	}
+	return AppendInt64(b, v)
+}
+
+// AppendInt8 appends v as int 8
+func AppendInt8(b []byte, v int8) []byte {
+	return append(b, def.Int8, byte(v))
+}
+
+// AppendInt16 appends v as int 16
+func AppendInt16(b []byte, v int16) []byte {
+	return append(b, def.Int16, byte(v>>8), byte(v))
+}
+
+// AppendInt32 appends v as int 32
+func AppendInt32(b []byte, v int32) []byte {
+	return append(b, def.Int32, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// AppendInt64 appends v as int 64
+func AppendInt64(b []byte, v int64) []byte {
+	return append(b, def.Int64, byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32),
+		byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// AppendUint appends v in the smallest uint format
+func AppendUint(b []byte, v uint64) []byte {
+	switch {
+	case v <= math.MaxInt8:
+		return append(b, byte(v))
+	case v <= math.MaxUint8:
+		return AppendUint8(b, uint8(v))
+	case v <= math.MaxUint16:
+		return AppendUint16(b, uint16(v))
+	case v <= math.MaxUint32:
+		return AppendUint32(b, uint32(v))
+	}
+	return AppendUint64(b, v)
+}
+
+// AppendUint8 appends v as uint 8
+func AppendUint8(b []byte, v uint8) []byte {
+	return append(b, def.Uint8, v)
+}
+
+// AppendUint16 appends v as uint 16
+func AppendUint16(b []byte, v uint16) []byte {
+	return append(b, def.Uint16, byte(v>>8), byte(v))
+}
+
+// AppendUint32 appends v as uint 32
+func AppendUint32(b []byte, v uint32) []byte {
+	return append(b, def.Uint32, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// AppendUint64 appends v as uint 64
+func AppendUint64(b []byte, v uint64) []byte {
+	return append(b, def.Uint64, byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32),
+		byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// AppendFloat32 appends v as float 32
+func AppendFloat32(b []byte, v float32) []byte {
+	u := math.Float32bits(v)
+	return append(b, def.Float32, byte(u>>24), byte(u>>16), byte(u>>8), byte(u))
+}
+
+// AppendFloat64 appends v as float 64
+func AppendFloat64(b []byte, v float64) []byte {
+	u := math.Float64bits(v)
+	return append(b, def.Float64, byte(u>>56), byte(u>>48), byte(u>>40), byte(u>>32),
+		byte(u>>24), byte(u>>16), byte(u>>8), byte(u))
+}
+
+// AppendString appends s in the smallest str format
+func AppendString(b []byte, s string) []byte {
+	b = AppendStringHeader(b, len(s))
+	return append(b, s...)
+}
+
+// AppendStringBytes appends s as str, like AppendString
+func AppendStringBytes(b []byte, s []byte) []byte {
+	b = AppendStringHeader(b, len(s))
+	return append(b, s...)
+}
+
+// AppendStringHeader appends the header of a str with an l byte payload
+func AppendStringHeader(b []byte, l int) []byte {
+	return appendHeader(b, l, def.FixStr, 0x1f, def.Str16, def.Str32, def.Str8)
+}
+
+// AppendStringHeader8 appends the header of a str with an l byte payload as str 8
+func AppendStringHeader8(b []byte, l uint8) []byte {
+	return append(b, def.Str8, l)
+}
+
+// AppendStringHeader16 appends the header of a str with an l byte payload as str 16
+func AppendStringHeader16(b []byte, l uint16) []byte {
+	return append(b, def.Str16, byte(l>>8), byte(l))
+}
+
+// AppendStringHeader32 appends the header of a str with an l byte payload as str 32
+func AppendStringHeader32(b []byte, l uint32) []byte {
+	return append(b, def.Str32, byte(l>>24), byte(l>>16), byte(l>>8), byte(l))
+}
+
+// AppendBytes appends bs as bin
+func AppendBytes(b []byte, bs []byte) []byte {
+	b = AppendBytesHeader(b, len(bs))
+	return append(b, bs...)
+}
+
+// AppendBytesHeader appends the header of a bin with an l byte payload
+func AppendBytesHeader(b []byte, l int) []byte {
+	switch {
+	case l <= math.MaxUint8:
+		return AppendBytesHeader8(b, uint8(l))
+	case l <= math.MaxUint16:
+		return AppendBytesHeader16(b, uint16(l))
+	}
+	return AppendBytesHeader32(b, uint32(l))
+}
+
+// AppendBytesHeader8 appends the header of a bin with an l byte payload as bin 8
+func AppendBytesHeader8(b []byte, l uint8) []byte {
+	return append(b, def.Bin8, l)
+}
+
+// AppendBytesHeader16 appends the header of a bin with an l byte payload as bin 16
+func AppendBytesHeader16(b []byte, l uint16) []byte {
+	return append(b, def.Bin16, byte(l>>8), byte(l))
+}
+
+// AppendBytesHeader32 appends the header of a bin with an l byte payload as bin 32
+func AppendBytesHeader32(b []byte, l uint32) []byte {
+	return append(b, def.Bin32, byte(l>>24), byte(l>>16), byte(l>>8), byte(l))
+}
+
+// AppendExt appends data as ext of application type typ. Payloads of 1, 2, 4,
+// 8 and 16 bytes use the fixext formats.
+func AppendExt(b []byte, typ int8, data []byte) []byte {
+	b = AppendExtHeader(b, typ, len(data))
+	return append(b, data...)
+}
+
+// AppendExtHeader appends the header of an ext with an l byte payload
+func AppendExtHeader(b []byte, typ int8, l int) []byte {
+	switch {
+	case l == 1:
+		return append(b, def.FixExt1, byte(typ))
+	case l == 2:
+		return append(b, def.FixExt2, byte(typ))
+	case l == 4:
+		return append(b, def.FixExt4, byte(typ))
+	case l == 8:
+		return append(b, def.FixExt8, byte(typ))
+	case l == 16:
+		return append(b, def.FixExt16, byte(typ))
+	case l <= math.MaxUint8:
+		return append(b, def.Ext8, byte(l), byte(typ))
+	case l <= math.MaxUint16:
+		return append(b, def.Ext16, byte(l>>8), byte(l), byte(typ))
+	}
+	return append(b, def.Ext32, byte(l>>24), byte(l>>16), byte(l>>8), byte(l), byte(typ))
+}
+
+// AppendMapHeader appends the header of a map with l entries
+func AppendMapHeader(b []byte, l int) []byte {
+	return appendHeader(b, l, def.FixMap, 0x0f, def.Map16, def.Map32, 0)
+}
+
+// AppendMapHeader16 appends the header of a map with l entries as map 16
+func AppendMapHeader16(b []byte, l uint16) []byte {
+	return append(b, def.Map16, byte(l>>8), byte(l))
+}
+
+// AppendMapHeader32 appends the header of a map with l entries as map 32
+func AppendMapHeader32(b []byte, l uint32) []byte {
+	return append(b, def.Map32, byte(l>>24), byte(l>>16), byte(l>>8), byte(l))
+}
+
+// AppendArrayHeader appends the header of an array with l elements
+func AppendArrayHeader(b []byte, l int) []byte {
+	return appendHeader(b, l, def.FixArray, 0x0f, def.Array16, def.Array32, 0)
+}
+
+// AppendArrayHeader16 appends the header of an array with l elements as array 16
+func AppendArrayHeader16(b []byte, l uint16) []byte {
+	return append(b, def.Array16, byte(l>>8), byte(l))
+}
+
+// AppendArrayHeader32 appends the header of an array with l elements as array 32
+func AppendArrayHeader32(b []byte, l uint32) []byte {
+	return append(b, def.Array32, byte(l>>24), byte(l>>16), byte(l>>8), byte(l))
+}
+
+// appendHeader writes length l with the fix format when it fits, code8 is
+// skipped when 0. Lengths above MaxUint32 are truncated as the format allows no more.
+func appendHeader(b []byte, l int, fix byte, fixMax int, code16, code32, code8 byte) []byte {
+	switch {
+	case l <= fixMax:
+		return append(b, fix+byte(l))
+	case code8 != 0 && l <= math.MaxUint8:
+		return append(b, code8, byte(l))
+	case l <= math.MaxUint16:
+		return append(b, code16, byte(l>>8), byte(l))
+	}
+	return append(b, code32, byte(l>>24), byte(l>>16), byte(l>>8), byte(l))
+}
+
+// Upper bounds of encoded sizes, used by generated Msgsize methods
+const (
+	NilSize     = 1
+	BoolSize    = 1
+	IntSize     = 9
+	Float32Size = 5
+	Float64Size = 9
+	HeaderSize  = 5 // str, map and array headers
+
+	// UnknownSize is the guess for values which are encoded through reflection
+	UnknownSize = 64
+)
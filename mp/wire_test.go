@@ -0,0 +1,229 @@
+package mp
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/romanzac/json-mp/mp/def"
+	"github.com/romanzac/json-mp/mp/wire"
+)
+
+func TestWireMatchesMarshal(t *testing.T) {
+	values := []interface{}{
+		nil, true, false,
+		0, 127, 128, 255, 256, math.MaxUint16 + 1, uint64(math.MaxUint64),
+		-1, -31, -32, math.MinInt8, math.MinInt16, math.MinInt32, int64(math.MinInt64),
+		float32(1.5), -2.25,
+		"", "a", strings.Repeat("b", 32), strings.Repeat("c", math.MaxUint8+1), strings.Repeat("d", math.MaxUint16+1),
+	}
+	for i, v := range values {
+		want, err := Marshal(v)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		var got []byte
+		switch v := v.(type) {
+		case nil:
+			got = wire.AppendNil(nil)
+		case bool:
+			got = wire.AppendBool(nil, v)
+		case int:
+			got = wire.AppendInt(nil, int64(v))
+		case int64:
+			got = wire.AppendInt(nil, v)
+		case uint64:
+			got = wire.AppendUint(nil, v)
+		case float32:
+			got = wire.AppendFloat32(nil, v)
+		case float64:
+			got = wire.AppendFloat64(nil, v)
+		case string:
+			got = wire.AppendString(nil, v)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%d: %v\nwire\n%s\nmarshal\n%s", i, v, Dump(got), Dump(want))
+		}
+		if rest, err := wire.Skip(got); err != nil || len(rest) != 0 {
+			t.Error(i, "skip", err, rest)
+		}
+	}
+}
+
+func TestWireRoundTrip(t *testing.T) {
+	var b []byte
+	b = wire.AppendInt8(b, -5)
+	b = wire.AppendInt16(b, 1)
+	b = wire.AppendInt32(b, -70000)
+	b = wire.AppendInt64(b, 1<<40)
+	b = wire.AppendUint8(b, 1)
+	b = wire.AppendUint16(b, 65535)
+	b = wire.AppendUint32(b, 1<<31)
+	b = wire.AppendUint64(b, math.MaxUint64)
+	b = wire.AppendBytes(b, []byte{0xff, 0x00})
+	b = wire.AppendExt(b, -1, []byte{1, 2, 3, 4})
+	b = wire.AppendExt(b, 5, []byte{1, 2, 3})
+	if !Valid(append(wire.AppendArrayHeader(nil, 11), b...)) {
+		t.Error("appended values must be valid\n", Dump(b))
+	}
+	b = wire.AppendMapHeader(b, 1)
+	b = wire.AppendStringBytes(b, []byte("k"))
+	b = wire.AppendArrayHeader(b, 16)
+	if b[0] != def.Int8 || b[2] != def.Int16 {
+		t.Error("sized formats must be kept", b[:4])
+	}
+
+	i8, b, err := wire.ReadInt8(b)
+	if err != nil || i8 != -5 {
+		t.Fatal(i8, err)
+	}
+	i16, b, _ := wire.ReadInt16(b)
+	i32, b, _ := wire.ReadInt32(b)
+	i64, b, _ := wire.ReadInt64(b)
+	u8, b, _ := wire.ReadUint8(b)
+	u16, b, _ := wire.ReadUint16(b)
+	u32, b, _ := wire.ReadUint32(b)
+	u64, b, err := wire.ReadUint64(b)
+	if err != nil || i16 != 1 || i32 != -70000 || i64 != 1<<40 || u8 != 1 || u16 != 65535 || u32 != 1<<31 || u64 != math.MaxUint64 {
+		t.Fatal("value different", i16, i32, i64, u8, u16, u32, u64, err)
+	}
+
+	if wire.NextType(b) != wire.BinType {
+		t.Error("type different", wire.NextType(b))
+	}
+	bs, b, err := wire.ReadBytes(b)
+	if err != nil || !bytes.Equal(bs, []byte{0xff, 0x00}) {
+		t.Fatal(bs, err)
+	}
+	typ, data, b, err := wire.ReadExt(b)
+	if err != nil || typ != -1 || !bytes.Equal(data, []byte{1, 2, 3, 4}) {
+		t.Fatal(typ, data, err)
+	}
+	typ, data, b, err = wire.ReadExt(b)
+	if err != nil || typ != 5 || len(data) != 3 {
+		t.Fatal(typ, data, err)
+	}
+	n, b, err := wire.ReadMapHeader(b)
+	if err != nil || n != 1 {
+		t.Fatal(n, err)
+	}
+	s, b, err := wire.ReadString(b)
+	if err != nil || s != "k" {
+		t.Fatal(s, err)
+	}
+	if wire.NextType(b) != wire.ArrayType {
+		t.Error("type different", wire.NextType(b))
+	}
+	if _, _, err = wire.ReadArrayHeader(b); err == nil {
+		t.Error("error must occur for missing elements")
+	}
+}
+
+func TestWireSizedHeaders(t *testing.T) {
+	for _, c := range []struct {
+		b    []byte
+		code byte
+	}{
+		{append(wire.AppendStringHeader8(nil, 1), 'a'), def.Str8},
+		{append(wire.AppendStringHeader16(nil, 1), 'a'), def.Str16},
+		{append(wire.AppendStringHeader32(nil, 1), 'a'), def.Str32},
+		{append(wire.AppendBytesHeader8(nil, 1), 'a'), def.Bin8},
+		{append(wire.AppendBytesHeader16(nil, 1), 'a'), def.Bin16},
+		{append(wire.AppendBytesHeader32(nil, 1), 'a'), def.Bin32},
+		{append(wire.AppendMapHeader16(nil, 1), 'a', 'a'), def.Map16},
+		{append(wire.AppendMapHeader32(nil, 1), 'a', 'a'), def.Map32},
+		{append(wire.AppendArrayHeader16(nil, 1), 'a'), def.Array16},
+		{append(wire.AppendArrayHeader32(nil, 1), 'a'), def.Array32},
+	} {
+		if c.b[0] != c.code || !Valid(c.b) {
+			t.Errorf("%x: sized format must be kept and valid\n% x", c.code, c.b)
+		}
+		rest, err := wire.Skip(c.b)
+		if err != nil || len(rest) != 0 {
+			t.Errorf("%x: skip % x, %v", c.code, rest, err)
+		}
+	}
+}
+
+func TestWireErrors(t *testing.T) {
+	for _, b := range [][]byte{wire.AppendFloat64(nil, 1.5), wire.AppendFloat32(nil, 2)} {
+		if _, _, err := wire.ReadInt(b); err == nil || !strings.Contains(err.Error(), "decoding int") {
+			t.Error("error must occur for float as int", err)
+		}
+		if _, _, err := wire.ReadInt64(b); err == nil {
+			t.Error("error must occur for float as int64")
+		}
+		if _, _, err := wire.ReadInt32(b); err == nil {
+			t.Error("error must occur for float as int32")
+		}
+	}
+	if _, _, err := wire.ReadInt8(wire.AppendInt(nil, 128)); err == nil {
+		t.Error("error must occur for int8 overflow")
+	}
+	if _, _, err := wire.ReadUint16(wire.AppendInt(nil, -1)); err == nil {
+		t.Error("error must occur for negative uint")
+	}
+	if _, _, err := wire.ReadInt64(wire.AppendUint(nil, math.MaxUint64)); err == nil {
+		t.Error("error must occur for int64 overflow")
+	}
+	if _, _, err := wire.ReadBool(wire.AppendNil(nil)); err == nil || !strings.Contains(err.Error(), "invalid code c0") {
+		t.Error("error must occur", err)
+	}
+	if _, _, err := wire.ReadString(wire.AppendString(nil, "abc")[:2]); err == nil {
+		t.Error("error must occur for truncated str")
+	}
+	if _, _, _, err := wire.ReadExt(wire.AppendBytes(nil, nil)); err == nil {
+		t.Error("error must occur for bin as ext")
+	}
+}
+
+func TestWireTime(t *testing.T) {
+	for _, c := range []struct {
+		t    time.Time
+		size int
+	}{
+		{time.Unix(1363896240, 0), 6},
+		{time.Unix(1363896240, 500), 10},
+		{time.Unix(1<<34, 0), 15},
+		{time.Unix(-1, 999999999), 15},
+	} {
+		b := wire.AppendTime(nil, c.t)
+		if len(b) != c.size {
+			t.Errorf("%v: %d bytes, expected %d", c.t, len(b), c.size)
+		}
+		tm, rest, err := wire.ReadTime(b)
+		if err != nil || len(rest) != 0 || !tm.Equal(c.t) {
+			t.Errorf("%v: read %v, %v", c.t, tm, err)
+		}
+	}
+
+	if _, _, err := wire.ReadTime(wire.AppendExt(nil, 5, []byte{1, 2, 3, 4})); err == nil {
+		t.Error("error must occur for other ext types")
+	}
+	if _, _, err := wire.ReadTime(wire.AppendExt(nil, -1, []byte{1, 2, 3})); err == nil {
+		t.Error("error must occur for timestamp of 3 bytes")
+	}
+}
+
+func TestWireNoAllocs(t *testing.T) {
+	buf := make([]byte, 0, 256)
+	allocs := testing.AllocsPerRun(100, func() {
+		b := wire.AppendMapHeader(buf[:0], 2)
+		b = wire.AppendString(b, "id")
+		b = wire.AppendInt(b, -70000)
+		b = wire.AppendString(b, "tags")
+		b = wire.AppendArrayHeader(b, 1)
+		b = wire.AppendFloat64(b, 1.5)
+
+		n, b, _ := wire.ReadMapHeader(b)
+		for i := 0; i < n; i++ {
+			_, b, _ = wire.ReadStringBytes(b)
+			b, _ = wire.Skip(b)
+		}
+	})
+	if allocs != 0 {
+		t.Error("allocations", allocs)
+	}
+}
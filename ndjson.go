@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/romanzac/json-mp/mp"
+	"github.com/romanzac/json-mp/mp/stream"
+)
+
+// recordError is a conversion error of a single line or MessagePack value
+type recordError struct {
+	kind string
+	n    int
+	err  error
+}
+
+func (e *recordError) Error() string {
+	return fmt.Sprintf("%s %d: %v", e.kind, e.n, e.err)
+}
+
+func (e *recordError) Unwrap() error {
+	return e.err
+}
+
+// skipRecord reports err on stderr and returns true when invalid records are
+// skipped, conversion errors of records are the only ones skipped
+func skipRecord(err error) bool {
+	var re *recordError
+	if !skipInvalid || !errors.As(err, &re) || exitCode(err) != exitData {
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "Skipping invalid record: %v\n", err)
+	return true
+}
+
+// encodeNDJSON converts every line of JSON Lines input to one MessagePack
+// value, using the shape or, when streaming, no shape. Blank lines are ignored.
+// Skipped records are left out, any other error ends the conversion without
+// flushing, and closeOutput removes the incomplete output file.
+func encodeNDJSON(in io.Reader, out io.Writer) error {
+	r := bufio.NewReader(in)
+	w := bufio.NewWriter(out)
+	var buf bytes.Buffer
+	for n := 1; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			buf.Reset()
+			cerr := encodeRecord(&buf, line)
+			if cerr != nil {
+				cerr = &recordError{kind: "line", n: n, err: cerr}
+				if !skipRecord(cerr) {
+					return cerr
+				}
+			} else if _, werr := w.Write(buf.Bytes()); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			return w.Flush()
+		}
+	}
+}
+
+func encodeRecord(buf *bytes.Buffer, line []byte) error {
+	if !isStreaming {
+		d, err := encodeMessagePack(line)
+		buf.Write(d)
+		return err
+	}
+	// one value per line, FromJSON would accept several
+	if !json.Valid(line) {
+		return errors.New("invalid JSON input")
+	}
+	return mp.FromJSON(buf, bytes.NewReader(line))
+}
+
+// decodeNDJSON converts every MessagePack value of in to one line of JSON,
+// errors end the conversion as in encodeNDJSON
+func decodeNDJSON(in io.Reader, out io.Writer) error {
+	r := stream.NewReader(in)
+	w := bufio.NewWriter(out)
+	var buf bytes.Buffer
+	for n := 1; ; n++ {
+		value, err := r.Next()
+		if err == io.EOF {
+			return w.Flush()
+		}
+		if err != nil {
+			// a broken value cannot be skipped, the next one is not found
+			return &recordError{kind: "record", n: n, err: err}
+		}
+
+		buf.Reset()
+		if err = decodeRecord(&buf, value); err != nil {
+			err = &recordError{kind: "record", n: n, err: err}
+			if skipRecord(err) {
+				continue
+			}
+			return err
+		}
+		if _, err = w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+}
+
+func decodeRecord(buf *bytes.Buffer, value []byte) error {
+	if isStreaming {
+		return mp.ToJSON(buf, bytes.NewReader(value), mp.JSONOptions{})
+	}
+	d, err := decodeMessagePack(value)
+	if err != nil {
+		return err
+	}
+	buf.Write(d)
+	buf.WriteByte('\n')
+	return nil
+}
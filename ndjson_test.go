@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/romanzac/json-mp/mp/wire"
+)
+
+func TestNDJSON(t *testing.T) {
+	isStreaming = true
+	defer func() { isStreaming, skipInvalid = false, false }()
+	in := "{\"a\":1}\n\nnot json\n[true]\n"
+
+	skipInvalid = true
+	var out bytes.Buffer
+	if err := encodeNDJSON(strings.NewReader(in), &out); err != nil {
+		t.Fatal(err)
+	}
+	expected := wire.AppendUint(append(wire.AppendMapHeader(nil, 1), 0xa1, 'a'), 1)
+	expected = wire.AppendBool(wire.AppendArrayHeader(expected, 1), true)
+	if !bytes.Equal(out.Bytes(), expected) {
+		t.Errorf("% x, expected % x", out.Bytes(), expected)
+	}
+
+	var back bytes.Buffer
+	if err := decodeNDJSON(bytes.NewReader(out.Bytes()), &back); err != nil || back.String() != "{\"a\":1}\n[true]\n" {
+		t.Errorf("decoded %q, %v", back.String(), err)
+	}
+
+	// without skipping the error ends the conversion and the file is removed
+	skipInvalid = false
+	name := filepath.Join(t.TempDir(), "out.mp")
+	f, err := createOutput(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = closeOutput(f, encodeNDJSON(strings.NewReader(in), f))
+	var re *recordError
+	if !errors.As(err, &re) || re.n != 3 || exitCode(err) != exitData {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err = os.Stat(name); !os.IsNotExist(err) {
+		t.Error("partial output must be removed", err)
+	}
+
+	out.Reset()
+	if err = decodeNDJSON(bytes.NewReader(append(expected, 0xc1)), &out); !errors.As(err, &re) || re.n != 3 {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"github.com/romanzac/json-mp/mp"
+	"github.com/spf13/cobra"
+)
+
+var (
+	isMergePatch bool
+	patchFile    string
+
+	// PatchCmd applies JSON Patch or JSON Merge Patch to MessagePack file
+	PatchCmd = &cobra.Command{
+		Use:   "patch",
+		Short: "Applies JSON Patch (RFC 6902) or JSON Merge Patch (RFC 7396) to MessagePack file",
+		Run:   runPatch,
+	}
+
+	// MkPatchCmd creates JSON Patch or JSON Merge Patch from two MessagePack files
+	MkPatchCmd = &cobra.Command{
+		Use:   "mkpatch <from.mp> <to.mp>",
+		Short: "Creates JSON Patch (RFC 6902) or JSON Merge Patch (RFC 7396) between two MessagePack files",
+		Args:  cobra.ExactArgs(2),
+		Run:   runMkPatch,
+	}
+)
+
+func init() {
+	PatchCmd.Flags().BoolVarP(&isMergePatch, "merge", "m", false, "patch is JSON Merge Patch (RFC 7396)")
+	PatchCmd.Flags().StringVarP(&inputFile, "input", "i", stdio, "input MessagePack file path, - for stdin")
+	PatchCmd.Flags().StringVarP(&patchFile, "patch", "p", "", "JSON patch file path, - for stdin")
+	PatchCmd.Flags().StringVarP(&outputFile, "output", "o", stdio, "output MessagePack file path, - for stdout")
+	PatchCmd.MarkFlagRequired("patch")
+
+	MkPatchCmd.Flags().BoolVarP(&isMergePatch, "merge", "m", false, "creates JSON Merge Patch (RFC 7396)")
+	MkPatchCmd.Flags().StringVarP(&outputFile, "output", "o", stdio, "output JSON file path, - for stdout")
+
+	JsonMpCmd.AddCommand(PatchCmd, MkPatchCmd)
+}
+
+func runPatch(cmd *cobra.Command, args []string) {
+
+	if inputFile == stdio && patchFile == stdio {
+		fail(exitUsage, "Only one of input and patch can be read from stdin")
+	}
+	doc, err := readInput(inputFile)
+	if err != nil {
+		fail(exitIO, "Error during reading the MessagePack file: %v", err)
+	}
+	jsonPatch, err := readInput(patchFile)
+	if err != nil {
+		fail(exitIO, "Error during reading the patch file: %v", err)
+	}
+
+	apply := mp.ApplyPatch
+	if isMergePatch {
+		apply = mp.MergePatch
+	}
+	doc, err = apply(doc, jsonPatch)
+	if err != nil {
+		fail(exitData, "Error during patching: %v", err)
+	}
+
+	if err = writeOutput(outputFile, doc); err != nil {
+		fail(exitIO, "Error during writing the MessagePack file: %v", err)
+	}
+}
+
+func runMkPatch(cmd *cobra.Command, args []string) {
+
+	from, err := readInput(args[0])
+	if err != nil {
+		fail(exitIO, "Error during reading the MessagePack file: %v", err)
+	}
+	to, err := readInput(args[1])
+	if err != nil {
+		fail(exitIO, "Error during reading the MessagePack file: %v", err)
+	}
+
+	create := mp.CreatePatch
+	if isMergePatch {
+		create = mp.CreateMergePatch
+	}
+	jsonPatch, err := create(from, to)
+	if err != nil {
+		fail(exitData, "Error during creating the patch: %v", err)
+	}
+
+	if outputFile == stdio {
+		jsonPatch = append(jsonPatch, '\n')
+	}
+	if err = writeOutput(outputFile, jsonPatch); err != nil {
+		fail(exitIO, "Error during writing the JSON file: %v", err)
+	}
+}
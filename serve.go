@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/romanzac/json-mp/mp/httpmp"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveAddr    string
+	serveMaxSize int64
+
+	// ServeCmd runs the conversions as HTTP service
+	ServeCmd = &cobra.Command{
+		Use:   "serve",
+		Short: "Serves JSON <-> MessagePack conversion over HTTP",
+		Long: `Serves the conversions with the shape over HTTP:
+
+  POST /encode   JSON (application/json) to MessagePack (application/msgpack)
+  POST /decode   MessagePack to JSON
+  GET  /health   reports the service is up
+
+Requests with another Content-Type are answered with 415, Accept headers not
+allowing the output with 406, bodies above --max-size with 413 and invalid
+input with 400.`,
+		Args: cobra.NoArgs,
+		Run:  runServe,
+	}
+)
+
+func init() {
+	ServeCmd.Flags().StringVar(&serveAddr, "addr", "localhost:8080", "address to listen on")
+	ServeCmd.Flags().Int64Var(&serveMaxSize, "max-size", 10<<20, "maximum request body size in bytes")
+
+	JsonMpCmd.AddCommand(ServeCmd)
+}
+
+func runServe(cmd *cobra.Command, args []string) {
+
+	if serveMaxSize < 1 {
+		fail(exitUsage, "Max size must be at least 1")
+	}
+
+	server := &http.Server{
+		Addr:              serveAddr,
+		Handler:           newServeMux(serveMaxSize),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "Serving on %s\n", serveAddr)
+	if err := server.ListenAndServe(); err != nil {
+		fail(exitIO, "Error during serving: %v", err)
+	}
+}
+
+// newServeMux returns the handler of the service, bodies are limited to maxSize bytes
+func newServeMux(maxSize int64) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/encode", converter{
+		in: []string{httpmp.JSONType}, out: httpmp.ContentTypes,
+		convert: encodeMessagePack, maxSize: maxSize,
+	})
+	mux.Handle("/decode", converter{
+		in: httpmp.ContentTypes, out: []string{httpmp.JSONType},
+		convert: decodeMessagePack, maxSize: maxSize,
+	})
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			httpError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+			return
+		}
+		w.Header().Set("Content-Type", httpmp.JSONType)
+		io.WriteString(w, `{"status":"ok"}`+"\n")
+	})
+	return mux
+}
+
+// converter serves a conversion of a request body of media types in to media
+// types out, which are written as the first of them
+type converter struct {
+	in, out []string
+	convert func([]byte) ([]byte, error)
+	maxSize int64
+}
+
+func (c converter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		httpError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+		return
+	}
+	if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || !contains(c.in, mt) {
+		httpError(w, http.StatusUnsupportedMediaType, "Content-Type must be %s", c.in[0])
+		return
+	}
+	if httpmp.Quality(r, c.out...) == 0 {
+		httpError(w, http.StatusNotAcceptable, "response is %s", c.out[0])
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, c.maxSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			httpError(w, http.StatusRequestEntityTooLarge, "body exceeds %d bytes", c.maxSize)
+		} else {
+			httpError(w, http.StatusBadRequest, "reading body: %v", err)
+		}
+		return
+	}
+	converted, err := c.convert(data)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", c.out[0])
+	w.Write(converted)
+}
+
+// httpError writes the message as plain text response with status code
+func httpError(w http.ResponseWriter, code int, format string, a ...interface{}) {
+	http.Error(w, fmt.Sprintf(format, a...), code)
+}
+
+func contains(types []string, t string) bool {
+	for _, s := range types {
+		if s == t {
+			return true
+		}
+	}
+	return false
+}
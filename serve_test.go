@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestServe(t *testing.T) {
+	sample, err := os.ReadFile("data/sample.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(newServeMux(int64(len(sample))))
+	defer server.Close()
+
+	post := func(path, contentType, accept string, body []byte) (*http.Response, []byte) {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		out, _ := io.ReadAll(resp.Body)
+		return resp, out
+	}
+
+	resp, mpData := post("/encode", "application/json; charset=utf-8", "application/*", sample)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/msgpack" {
+		t.Fatalf("encode: %s %s", resp.Status, mpData)
+	}
+	expected, _ := encodeMessagePack(sample)
+	if !bytes.Equal(mpData, expected) {
+		t.Error("encode differs from the command line")
+	}
+
+	resp, jsonData := post("/decode", "application/x-msgpack", "text/html, application/json", mpData)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("decode: %s %s", resp.Status, jsonData)
+	}
+	var a, b interface{}
+	json.Unmarshal(sample, &a)
+	json.Unmarshal(jsonData, &b)
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("round trip differs\n%s", jsonData)
+	}
+
+	for _, c := range []struct {
+		path, contentType, accept string
+		body                      []byte
+		status                    int
+	}{
+		{"/encode", "text/plain", "", sample, http.StatusUnsupportedMediaType},
+		{"/encode", "", "", sample, http.StatusUnsupportedMediaType},
+		{"/encode", "application/json", "application/json", sample, http.StatusNotAcceptable},
+		{"/encode", "application/json", "application/msgpack;q=0", sample, http.StatusNotAcceptable},
+		{"/encode", "application/json", "application/msgpack;q=0, */*", sample, http.StatusNotAcceptable},
+		{"/decode", "application/msgpack", "*/*;q=0.1, application/json;q=0", mpData, http.StatusNotAcceptable},
+		{"/encode", "application/json", "", append(sample, ' '), http.StatusRequestEntityTooLarge},
+		{"/encode", "application/json", "", []byte("{"), http.StatusBadRequest},
+		{"/decode", "application/json", "", mpData, http.StatusUnsupportedMediaType},
+		{"/decode", "application/msgpack", "", []byte{0xc1}, http.StatusBadRequest},
+	} {
+		resp, body := post(c.path, c.contentType, c.accept, c.body)
+		if resp.StatusCode != c.status {
+			t.Errorf("%s %q %q: %s %s, expected %d", c.path, c.contentType, c.accept, resp.Status, body, c.status)
+		}
+	}
+
+	resp, err = http.Get(server.URL + "/encode")
+	if err != nil || resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != http.MethodPost {
+		t.Errorf("GET /encode: %v %v", resp.Status, err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(server.URL + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	health, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(health), `"ok"`) {
+		t.Errorf("health: %s %s", resp.Status, health)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/romanzac/json-mp/mp"
+	"github.com/romanzac/json-mp/mp/stats"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statsFormat string
+	statsTop    int
+
+	// StatsCmd reports the size and composition of MessagePack data
+	StatsCmd = &cobra.Command{
+		Use:   "stats <file>",
+		Short: "Reports MessagePack size against JSON and where the bytes go",
+		Long: `Reports MessagePack size against compact JSON, the bytes spent on keys, strings,
+numbers and headers, the most frequent keys, the format histogram and the deepest
+nesting. The file holds MessagePack or JSON, which is converted without a shape
+when the file is no single valid MessagePack value.`,
+		Args: cobra.ExactArgs(1),
+		Run:  runStats,
+	}
+)
+
+func init() {
+	StatsCmd.Flags().StringVarP(&statsFormat, "format", "f", "text", "output format: text or json")
+	StatsCmd.Flags().IntVar(&statsTop, "top", 10, "number of most frequent keys listed")
+
+	JsonMpCmd.AddCommand(StatsCmd)
+}
+
+func runStats(cmd *cobra.Command, args []string) {
+
+	if statsFormat != "text" && statsFormat != "json" {
+		fail(exitUsage, "Unknown output format: %s", statsFormat)
+	}
+
+	data, err := readInput(args[0])
+	if err != nil {
+		fail(exitIO, "Error during reading the input file: %v", err)
+	}
+
+	report, err := mp.Stats(statsInput(data), statsTop)
+	if err != nil {
+		fail(exitData, "Error during computing statistics: %v", err)
+	}
+
+	if statsFormat == "text" {
+		fmt.Print(stats.Text(report))
+		return
+	}
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		fail(exitData, "Error during encoding to JSON: %v", err)
+	}
+	fmt.Println(string(out))
+}
+
+// statsInput returns data when it is a valid MessagePack value, else its
+// conversion when it is JSON. Text like "1" is valid as both and read as
+// MessagePack, while JSON objects, arrays and strings are never one valid value.
+func statsInput(data []byte) []byte {
+	if mp.Validate(data) == nil {
+		return data
+	}
+	var buf bytes.Buffer
+	if err := mp.FromJSON(&buf, bytes.NewReader(data)); err == nil && buf.Len() > 0 {
+		return buf.Bytes()
+	}
+	return data
+}
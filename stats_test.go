@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/romanzac/json-mp/mp/wire"
+)
+
+func TestStatsInput(t *testing.T) {
+	for _, c := range []struct {
+		in, expected []byte
+	}{
+		// a positive fixint is also the JSON text 1
+		{[]byte{0x31}, []byte{0x31}},
+		{wire.AppendString(nil, "ab"), wire.AppendString(nil, "ab")},
+		{[]byte("12\n"), wire.AppendUint(nil, 12)},
+		{[]byte(`{"a":true}`), wire.AppendBool(append(wire.AppendMapHeader(nil, 1), 0xa1, 'a'), true)},
+		{[]byte{0xc1}, []byte{0xc1}},
+	} {
+		if got := statsInput(c.in); !bytes.Equal(got, c.expected) {
+			t.Errorf("% x: % x, expected % x", c.in, got, c.expected)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/romanzac/json-mp/mp/validate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	maxDepth int
+
+	// ValidateCmd checks MessagePack files for well-formedness
+	ValidateCmd = &cobra.Command{
+		Use:   "validate <file.mp>...",
+		Short: "Checks that MessagePack files are well-formed",
+		Long:  `Checks that MessagePack files are well-formed, exits with status 1 when any of them is not`,
+		Args:  cobra.MinimumNArgs(1),
+		Run:   runValidate,
+	}
+)
+
+func init() {
+	ValidateCmd.Flags().IntVar(&maxDepth, "max-depth", validate.DefaultLimits.MaxDepth, "maximum nesting of maps and arrays")
+
+	JsonMpCmd.AddCommand(ValidateCmd)
+}
+
+func runValidate(cmd *cobra.Command, args []string) {
+
+	limits := validate.DefaultLimits
+	limits.MaxDepth = maxDepth
+
+	failed := 0
+	for _, name := range args {
+		data, err := readInput(name)
+		if err != nil {
+			fail(exitIO, "Error during reading the input file: %v", err)
+		}
+		if err = validate.WithLimits(data, limits); err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s: ok\n", name)
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
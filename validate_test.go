@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	out, code := runCommand(t, "validate", "data/sample.mp")
+	if code != 0 || out != "data/sample.mp: ok\n" {
+		t.Errorf("status %d: %s", code, out)
+	}
+
+	name := filepath.Join(t.TempDir(), "short.mp")
+	if err := os.WriteFile(name, []byte{0x92, 0x01}, 0666); err != nil {
+		t.Fatal(err)
+	}
+	out, code = runCommand(t, "validate", "data/sample.mp", name)
+	if code != 1 || !strings.Contains(out, name+": container length 2 exceeds remaining") {
+		t.Errorf("status %d: %s", code, out)
+	}
+
+	if _, code = runCommand(t, "validate", filepath.Join(t.TempDir(), "missing.mp")); code != exitIO {
+		t.Errorf("status %d, expected %d", code, exitIO)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/romanzac/json-mp/mp"
+	"github.com/romanzac/json-mp/mp/diff"
+	"github.com/spf13/cobra"
+)
+
+var (
+	verifyInput  string
+	verifyFormat string
+
+	// VerifyCmd checks that JSON survives the round trip through MessagePack
+	VerifyCmd = &cobra.Command{
+		Use:   "verify",
+		Short: "Checks that JSON survives the round trip through MessagePack",
+		Long: `Encodes JSON to MessagePack with the shape, decodes it back to JSON and compares
+the result with the original by value, so key order, whitespace and number notation
+(1 == 1.0) are ignored. Exits with status 1 and prints the differing paths when
+data is changed, e.g. by fields missing in the shape or numbers truncated.`,
+		Args: cobra.NoArgs,
+		Run:  runVerify,
+	}
+)
+
+func init() {
+	VerifyCmd.Flags().StringVarP(&verifyInput, "input", "i", stdio, "JSON file path, - for stdin")
+	VerifyCmd.Flags().StringVarP(&verifyFormat, "format", "f", "text", "output format: text or json")
+
+	JsonMpCmd.AddCommand(VerifyCmd)
+}
+
+func runVerify(cmd *cobra.Command, args []string) {
+
+	if verifyFormat != "text" && verifyFormat != "json" {
+		fail(exitUsage, "Unknown output format: %s", verifyFormat)
+	}
+
+	original, err := readInput(verifyInput)
+	if err != nil {
+		fail(exitIO, "Error during reading the input file: %v", err)
+	}
+
+	mpData, err := encodeMessagePack(original)
+	if err != nil {
+		fail(exitData, "Error during encoding to MessagePack: %v", err)
+	}
+	roundTrip, err := decodeMessagePack(mpData)
+	if err != nil {
+		fail(exitData, "Error during decoding to JSON: %v", err)
+	}
+
+	ds, err := compareJSON(original, roundTrip)
+	if err != nil {
+		fail(exitData, "Error during comparing: %v", err)
+	}
+
+	if verifyFormat == "json" {
+		if ds == nil {
+			ds = []mp.Difference{}
+		}
+		out, err := json.MarshalIndent(ds, "", "  ")
+		if err != nil {
+			fail(exitData, "Error during encoding to JSON: %v", err)
+		}
+		fmt.Println(string(out))
+	} else if len(ds) > 0 {
+		fmt.Print(diff.Text(ds))
+	} else {
+		fmt.Printf("%s: ok\n", verifyInput)
+	}
+
+	if len(ds) > 0 {
+		os.Exit(1)
+	}
+}
+
+// compareJSON returns the differences between JSON documents a and b. Both are
+// transcoded without a shape, which keeps every number exact, and numbers are
+// compared by value as JSON has no integer widths.
+func compareJSON(a, b []byte) ([]mp.Difference, error) {
+	var ma, mb bytes.Buffer
+	if err := mp.FromJSON(&ma, bytes.NewReader(a)); err != nil {
+		return nil, err
+	}
+	if err := mp.FromJSON(&mb, bytes.NewReader(b)); err != nil {
+		return nil, err
+	}
+	return mp.Diff(ma.Bytes(), mb.Bytes(), mp.DiffOptions{IgnoreNumericWidth: true})
+}
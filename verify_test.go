@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain runs the command instead of the tests when runCommand executes
+// the test binary
+func TestMain(m *testing.M) {
+	if os.Getenv("JSON_MP_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runCommand runs json-mp with args and returns its stdout and exit status
+func runCommand(t *testing.T, args ...string) (string, int) {
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "JSON_MP_MAIN=1")
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return stdout.String(), exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stdout.String(), 0
+}
+
+func TestVerify(t *testing.T) {
+	out, code := runCommand(t, "verify", "-i", "data/sample.json")
+	if code != 0 || out != "data/sample.json: ok\n" {
+		t.Errorf("status %d: %s", code, out)
+	}
+	out, code = runCommand(t, "verify", "-i", "data/sample.json", "-f", "json")
+	if code != 0 || strings.TrimSpace(out) != "[]" {
+		t.Errorf("status %d: %s", code, out)
+	}
+
+	// a field missing in the shape is dropped by the round trip
+	sample, err := os.ReadFile("data/sample.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "extra.json")
+	extra := bytes.Replace(sample, []byte(`"debug": "on",`), []byte(`"debug": "on", "extra": 1,`), 1)
+	if err = os.WriteFile(name, extra, 0666); err != nil {
+		t.Fatal(err)
+	}
+	out, code = runCommand(t, "verify", "-i", name)
+	if code != 1 || !strings.Contains(out, "- /widget/extra") {
+		t.Errorf("status %d: %s", code, out)
+	}
+	out, code = runCommand(t, "verify", "-i", name, "-f", "json")
+	var ds []struct {
+		Path string `json:"path"`
+		Kind string `json:"kind"`
+	}
+	if code != 1 || json.Unmarshal([]byte(out), &ds) != nil || len(ds) != 1 || ds[0].Path != "/widget/extra" || ds[0].Kind != "removed" {
+		t.Errorf("status %d: %s", code, out)
+	}
+
+	if _, code = runCommand(t, "verify", "-i", "data/sample.json", "-f", "xml"); code != exitUsage {
+		t.Errorf("status %d, expected %d", code, exitUsage)
+	}
+	if _, code = runCommand(t, "verify", "-i", filepath.Join(t.TempDir(), "missing.json")); code != exitIO {
+		t.Errorf("status %d, expected %d", code, exitIO)
+	}
+}